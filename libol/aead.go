@@ -0,0 +1,389 @@
+package libol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"sync"
+	"time"
+)
+
+const (
+	AeadAesGcm = "aes-gcm"
+	AeadChacha = "chacha20-poly1305"
+	AeadHlSize = 9 // epoch(1) and sequence(8)
+	AeadTagLen = 16
+	AeadAction = "ecdh"
+)
+
+var ErrAeadAuth = NewErr("message authentication failed")
+
+// IsAead returns true if the cipher is supported by AeadSession.
+func IsAead(cipher string) bool {
+	return cipher == AeadAesGcm || cipher == AeadChacha
+}
+
+type AeadConfig struct {
+	Cipher string        // aes-gcm or chacha20-poly1305 with 256 bits key.
+	Secret string        // pre-shared secret mixed into session keys.
+	Rekey  time.Duration // ns to ratchet sending key, default 10 minutes.
+	Tls    bool          // transport is authenticated by tls.
+}
+
+func (c *AeadConfig) rekey() time.Duration {
+	if c.Rekey == 0 {
+		return 10 * time.Minute
+	}
+	return c.Rekey
+}
+
+type aeadHello struct {
+	Cipher string `json:"cipher"`
+	Public string `json:"public"`
+}
+
+// replayWindow records the latest 64 sequences received.
+type replayWindow struct {
+	last   uint64
+	bitmap uint64
+}
+
+func (w *replayWindow) Check(seq uint64) bool {
+	if seq > w.last {
+		return true
+	}
+	diff := w.last - seq
+	if diff >= 64 {
+		return false
+	}
+	return w.bitmap&(1<<diff) == 0
+}
+
+func (w *replayWindow) Update(seq uint64) {
+	if seq > w.last {
+		diff := seq - w.last
+		if diff >= 64 {
+			w.bitmap = 1
+		} else {
+			w.bitmap = w.bitmap<<diff | 1
+		}
+		w.last = seq
+	} else {
+		w.bitmap |= 1 << (w.last - seq)
+	}
+}
+
+type aeadKey struct {
+	cipher string
+	epoch  uint8
+	secret []byte
+	aead   cipher.AEAD
+	seq    uint64
+	born   time.Time
+	window replayWindow
+}
+
+func newAead(name string, secret []byte) (cipher.AEAD, error) {
+	if name == AeadChacha {
+		return chacha20poly1305.New(secret)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newAeadKey(name string, epoch uint8, secret []byte) (*aeadKey, error) {
+	aead, err := newAead(name, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &aeadKey{
+		cipher: name,
+		epoch:  epoch,
+		secret: secret,
+		aead:   aead,
+		born:   time.Now(),
+	}, nil
+}
+
+// next ratchets to a new key, and older one can not be derived from it.
+func (k *aeadKey) next() (*aeadKey, error) {
+	return newAeadKey(k.cipher, k.epoch+1, hmacSum(k.secret, []byte("openlan rekey")))
+}
+
+func (k *aeadKey) nonce(seq uint64) []byte {
+	nonce := make([]byte, k.aead.NonceSize())
+	nonce[0] = k.epoch
+	binary.BigEndian.PutUint64(nonce[4:12], seq)
+	return nonce
+}
+
+func hmacSum(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// newX25519 generates ephemeral private key, and returns its public.
+func newX25519() ([]byte, []byte, error) {
+	private := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(private); err != nil {
+		return nil, nil, err
+	}
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return private, public, nil
+}
+
+// AeadSession exchanges ephemeral X25519 keys after connected, and seals
+// frames by AEAD with the derived keys, which ratchet periodically. The
+// exchange is authenticated by the secret, or by tls of transport.
+type AeadSession struct {
+	lock    sync.RWMutex
+	cfg     *AeadConfig
+	private []byte
+	public  []byte
+	tx      *aeadKey
+	rx      *aeadKey
+	rxPrev  *aeadKey
+	pending [2]*aeadKey // tx and rx not committed.
+}
+
+func NewAeadSession(cfg *AeadConfig) *AeadSession {
+	if cfg == nil {
+		return nil
+	}
+	return &AeadSession{cfg: cfg}
+}
+
+func (s *AeadSession) Ready() bool {
+	if s == nil {
+		return false
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.tx != nil && s.rx != nil
+}
+
+func (s *AeadSession) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.private = nil
+	s.public = nil
+	s.tx = nil
+	s.rx = nil
+	s.rxPrev = nil
+	s.pending = [2]*aeadKey{}
+}
+
+// check refuses the exchange in the middle can be hijacked.
+func (s *AeadSession) check() error {
+	if s.cfg.Secret == "" && !s.cfg.Tls {
+		return NewErr("AeadSession: secret required without tls")
+	}
+	return nil
+}
+
+// Hello generates ephemeral key for initiator.
+func (s *AeadSession) Hello() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.check(); err != nil {
+		return "", err
+	}
+	private, public, err := newX25519()
+	if err != nil {
+		return "", err
+	}
+	s.private, s.public = private, public
+	return s.encodeHello(public)
+}
+
+// Respond accepts hello from initiator, and returns our hello. The keys
+// take effect by Commit after our hello sent.
+func (s *AeadSession) Respond(data string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.check(); err != nil {
+		return "", err
+	}
+	peer, err := s.decodeHello(data)
+	if err != nil {
+		return "", err
+	}
+	private, public, err := newX25519()
+	if err != nil {
+		return "", err
+	}
+	if err := s.install(private, peer, peer, public, false); err != nil {
+		return "", err
+	}
+	return s.encodeHello(public)
+}
+
+func (s *AeadSession) Commit() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.pending[0] != nil && s.pending[1] != nil {
+		s.tx, s.rx = s.pending[0], s.pending[1]
+		s.rxPrev = nil
+		s.pending = [2]*aeadKey{}
+	}
+}
+
+// Finish accepts hello from responder for initiator.
+func (s *AeadSession) Finish(data string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.private == nil {
+		return NewErr("AeadSession.Finish: no hello")
+	}
+	peer, err := s.decodeHello(data)
+	if err != nil {
+		return err
+	}
+	if err := s.install(s.private, peer, s.public, peer, true); err != nil {
+		return err
+	}
+	s.tx, s.rx = s.pending[0], s.pending[1]
+	s.rxPrev = nil
+	s.pending = [2]*aeadKey{}
+	return nil
+}
+
+func (s *AeadSession) encodeHello(public []byte) (string, error) {
+	data, err := json.Marshal(&aeadHello{
+		Cipher: s.cipher(),
+		Public: base64.StdEncoding.EncodeToString(public),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s *AeadSession) decodeHello(data string) ([]byte, error) {
+	hello := &aeadHello{}
+	if err := json.Unmarshal([]byte(data), hello); err != nil {
+		return nil, NewErr("AeadSession: invalid hello %s", err)
+	}
+	if hello.Cipher != s.cipher() {
+		return nil, NewErr("AeadSession: cipher %s not supported", hello.Cipher)
+	}
+	public, err := base64.StdEncoding.DecodeString(hello.Public)
+	if err != nil {
+		return nil, err
+	}
+	if len(public) != curve25519.PointSize {
+		return nil, NewErr("AeadSession: invalid public key")
+	}
+	return public, nil
+}
+
+func (s *AeadSession) cipher() string {
+	if s.cfg.Cipher == "" {
+		return AeadAesGcm
+	}
+	return s.cfg.Cipher
+}
+
+func (s *AeadSession) install(private, peer, client, server []byte, initiator bool) error {
+	// low order point of peer is refused.
+	shared, err := curve25519.X25519(private, peer)
+	if err != nil {
+		return err
+	}
+	salt := sha256.Sum256([]byte(s.cfg.Secret))
+	ikm := append(shared, client...)
+	ikm = append(ikm, server...)
+	prk := hmacSum(salt[:], ikm)
+	c2s, err := newAeadKey(s.cipher(), 0, hmacSum(prk, []byte("openlan c2s")))
+	if err != nil {
+		return err
+	}
+	s2c, err := newAeadKey(s.cipher(), 0, hmacSum(prk, []byte("openlan s2c")))
+	if err != nil {
+		return err
+	}
+	if initiator {
+		s.pending = [2]*aeadKey{c2s, s2c}
+	} else {
+		s.pending = [2]*aeadKey{s2c, c2s}
+	}
+	s.private = nil
+	s.public = nil
+	return nil
+}
+
+// Seal encrypts data with header as additional data, and returns
+// epoch, sequence and cipher text.
+func (s *AeadSession) Seal(header, data []byte) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.tx == nil {
+		return nil, NewErr("AeadSession.Seal: not ready")
+	}
+	if time.Since(s.tx.born) > s.cfg.rekey() || s.tx.seq >= 1<<32 {
+		next, err := s.tx.next()
+		if err != nil {
+			return nil, err
+		}
+		s.tx = next
+	}
+	s.tx.seq++
+	seq := s.tx.seq
+	out := make([]byte, AeadHlSize, AeadHlSize+len(data)+AeadTagLen)
+	out[0] = s.tx.epoch
+	binary.BigEndian.PutUint64(out[1:AeadHlSize], seq)
+	return s.tx.aead.Seal(out, s.tx.nonce(seq), data, header), nil
+}
+
+// Open decrypts data sealed by peer, and returns ErrAeadAuth if failed.
+func (s *AeadSession) Open(header, data []byte) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.rx == nil {
+		return nil, NewErr("AeadSession.Open: not ready")
+	}
+	if len(data) < AeadHlSize+AeadTagLen {
+		return nil, ErrAeadAuth
+	}
+	epoch := data[0]
+	seq := binary.BigEndian.Uint64(data[1:AeadHlSize])
+	key := s.rx
+	rotated := false
+	if epoch == s.rx.epoch+1 {
+		next, err := s.rx.next()
+		if err != nil {
+			return nil, err
+		}
+		key, rotated = next, true
+	} else if s.rxPrev != nil && epoch == s.rxPrev.epoch {
+		key = s.rxPrev
+	} else if epoch != s.rx.epoch {
+		return nil, ErrAeadAuth
+	}
+	if !key.window.Check(seq) {
+		return nil, ErrAeadAuth
+	}
+	plain, err := key.aead.Open(nil, key.nonce(seq), data[AeadHlSize:], header)
+	if err != nil {
+		return nil, ErrAeadAuth
+	}
+	key.window.Update(seq)
+	if rotated {
+		s.rxPrev, s.rx = s.rx, key
+	}
+	return plain, nil
+}
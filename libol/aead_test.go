@@ -0,0 +1,134 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newAeadPair(t *testing.T, cfg *AeadConfig) (*AeadSession, *AeadSession) {
+	client := NewAeadSession(cfg)
+	server := NewAeadSession(cfg)
+	hello, err := client.Hello()
+	assert.Nil(t, err, "client hello.")
+	reply, err := server.Respond(hello)
+	assert.Nil(t, err, "server respond.")
+	assert.False(t, server.Ready(), "not committed.")
+	server.Commit()
+	assert.Nil(t, client.Finish(reply), "client finish.")
+	assert.True(t, client.Ready(), "client ready.")
+	assert.True(t, server.Ready(), "server ready.")
+	return client, server
+}
+
+func TestAeadSession(t *testing.T) {
+	client, server := newAeadPair(t, &AeadConfig{Secret: "123456"})
+	header := []byte{0xff, 0xff, 0x00, 0x10}
+
+	data, err := client.Seal(header, []byte("hello openlan"))
+	assert.Nil(t, err, "seal.")
+	plain, err := server.Open(header, data)
+	assert.Nil(t, err, "open.")
+	assert.Equal(t, "hello openlan", string(plain), "be the same.")
+
+	// replayed
+	_, err = server.Open(header, data)
+	assert.Equal(t, ErrAeadAuth, err, "replay.")
+
+	// tampered
+	data, _ = client.Seal(header, []byte("hello openlan"))
+	data[len(data)-1] ^= 0x01
+	_, err = server.Open(header, data)
+	assert.Equal(t, ErrAeadAuth, err, "tamper.")
+
+	// reverse direction
+	data, _ = server.Seal(header, []byte("hi"))
+	plain, err = client.Open(header, data)
+	assert.Nil(t, err, "open.")
+	assert.Equal(t, "hi", string(plain), "be the same.")
+}
+
+func TestAeadSessionSecret(t *testing.T) {
+	client := NewAeadSession(&AeadConfig{Secret: "123456"})
+	server := NewAeadSession(&AeadConfig{Secret: "654321"})
+	hello, _ := client.Hello()
+	reply, _ := server.Respond(hello)
+	server.Commit()
+	_ = client.Finish(reply)
+
+	data, _ := client.Seal(nil, []byte("hello"))
+	_, err := server.Open(nil, data)
+	assert.Equal(t, ErrAeadAuth, err, "different secret.")
+}
+
+func TestAeadSessionChacha(t *testing.T) {
+	client, server := newAeadPair(t, &AeadConfig{Cipher: AeadChacha, Secret: "123456"})
+	data, err := client.Seal(nil, []byte("hello"))
+	assert.Nil(t, err, "seal.")
+	plain, err := server.Open(nil, data)
+	assert.Nil(t, err, "open.")
+	assert.Equal(t, "hello", string(plain), "be the same.")
+
+	other := NewAeadSession(&AeadConfig{Secret: "123456"})
+	hello, _ := NewAeadSession(&AeadConfig{Cipher: AeadChacha, Secret: "123456"}).Hello()
+	_, err = other.Respond(hello)
+	assert.NotNil(t, err, "different cipher.")
+	assert.True(t, IsAead(AeadChacha), "be aead.")
+	assert.False(t, IsAead("aes-128"), "not aead.")
+}
+
+func TestAeadSessionNoSecret(t *testing.T) {
+	_, err := NewAeadSession(&AeadConfig{}).Hello()
+	assert.NotNil(t, err, "refused without tls.")
+	client := NewAeadSession(&AeadConfig{Tls: true})
+	hello, err := client.Hello()
+	assert.Nil(t, err, "allowed with tls.")
+	_, err = NewAeadSession(&AeadConfig{}).Respond(hello)
+	assert.NotNil(t, err, "refused without tls.")
+}
+
+func TestAeadSessionRekey(t *testing.T) {
+	client, server := newAeadPair(t, &AeadConfig{Rekey: time.Millisecond, Tls: true})
+	old, _ := client.Seal(nil, []byte("old"))
+	time.Sleep(2 * time.Millisecond)
+	data, _ := client.Seal(nil, []byte("new"))
+	assert.Equal(t, uint8(1), data[0], "next epoch.")
+
+	plain, err := server.Open(nil, data)
+	assert.Nil(t, err, "open new.")
+	assert.Equal(t, "new", string(plain), "be the same.")
+	plain, err = server.Open(nil, old)
+	assert.Nil(t, err, "open previous.")
+	assert.Equal(t, "old", string(plain), "be the same.")
+}
+
+func TestAeadTcpClientAndServer(t *testing.T) {
+	cfg := &TcpConfig{Aead: &AeadConfig{Secret: "123456"}}
+	server := NewTcpServer("127.0.0.1:18083", cfg)
+	recv := make(chan *FrameMessage, 1)
+	go server.Accept()
+	go server.Loop(ServerListener{
+		OnClient: func(client SocketClient) error {
+			return nil
+		},
+		ReadAt: func(client SocketClient, f *FrameMessage) error {
+			recv <- f
+			return nil
+		},
+	})
+	defer server.Close()
+
+	client := NewTcpClient("127.0.0.1:18083", cfg)
+	assert.Nil(t, client.Connect(), "connect with key exchange.")
+	defer client.Close()
+
+	frame := NewFrameMessage()
+	frame.Append([]byte("0123456789abcdefghijklmnopqrstuvwxyz"))
+	assert.Nil(t, client.WriteMsg(frame), "write frame.")
+	select {
+	case f := <-recv:
+		assert.Equal(t, "0123456789abcdefghijklmnopqrstuvwxyz", string(f.Frame()), "be the same.")
+	case <-time.After(5 * time.Second):
+		t.Error("receive frame timeout")
+	}
+}
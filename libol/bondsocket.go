@@ -0,0 +1,657 @@
+package libol
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BondHash      = "hash"      // spread frames over paths by flow.
+	BondRedundant = "redundant" // duplicate frames to all paths.
+	BondSeqLen    = 8           // sequence stamped after frames of redundant.
+)
+
+// dedup remembers sequences of frames received in the current and last
+// window.
+type dedup struct {
+	window  int64 // ms
+	start   int64
+	current map[uint64]bool
+	last    map[uint64]bool
+}
+
+func newDedup(window int64) *dedup {
+	return &dedup{
+		window:  window,
+		current: make(map[uint64]bool, 1024),
+		last:    make(map[uint64]bool, 1024),
+	}
+}
+
+func (d *dedup) Seen(seq uint64, now int64) bool {
+	if dt := now - d.start; dt >= d.window {
+		d.last = d.current
+		if dt >= 2*d.window {
+			d.last = make(map[uint64]bool, 1024)
+		}
+		d.current = make(map[uint64]bool, 1024)
+		d.start = now
+	}
+	if d.current[seq] || d.last[seq] {
+		return true
+	}
+	d.current[seq] = true
+	return false
+}
+
+// FlowHash returns hash of addresses, protocol and ports, and frames
+// not ip are hashed by ethernet addresses.
+func FlowHash(frame *FrameMessage) uint32 {
+	h := fnv.New32a()
+	if proto, _ := frame.Proto(); proto != nil && proto.Family() != 0 {
+		_, _ = h.Write(proto.Source())
+		_, _ = h.Write(proto.Destination())
+		sport, dport := proto.Ports()
+		data := make([]byte, 5)
+		data[0] = proto.Protocol()
+		binary.BigEndian.PutUint16(data[1:3], sport)
+		binary.BigEndian.PutUint16(data[3:5], dport)
+		_, _ = h.Write(data)
+	} else if data := frame.Frame(); len(data) >= EtherLen {
+		_, _ = h.Write(data[:12]) // destination and source.
+	}
+	return h.Sum32()
+}
+
+// stampFrame returns a copy of frame with the sequence after it.
+func stampFrame(frame *FrameMessage, seq uint64) *FrameMessage {
+	stamp := make([]byte, BondSeqLen)
+	binary.BigEndian.PutUint64(stamp, seq)
+	c := NewFrameMessage()
+	c.Append(frame.frame[:frame.size])
+	c.Append(stamp)
+	return c
+}
+
+// plainPool holds buffers to restore frames encrypted by path failed.
+var plainPool = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, MaxBuf)
+	},
+}
+
+// Bond is paths to the same peer, and frames written are spread over
+// paths authenticated by hash of flow, or duplicated to all paths if
+// redundant. Frames of redundant are stamped by sequence, and receiver
+// drops ones of sequence received already.
+type Bond struct {
+	seq   uint64 // sequence of frame sent last.
+	lock  sync.RWMutex
+	mode  string
+	paths []SocketClient
+	seen  *dedup
+}
+
+func NewBond(mode string) *Bond {
+	if mode != BondRedundant {
+		mode = BondHash
+	}
+	// random start, and peer restarted isn't dropped in window.
+	seq := make([]byte, 8)
+	_, _ = rand.Read(seq)
+	return &Bond{
+		seq:   binary.BigEndian.Uint64(seq),
+		mode:  mode,
+		paths: make([]SocketClient, 0, 4),
+		seen:  newDedup(1000),
+	}
+}
+
+func (b *Bond) Mode() string {
+	return b.mode
+}
+
+// MaxSize returns max size of path to read frame of size, and it's
+// larger by sequence if redundant.
+func (b *Bond) MaxSize(size int) int {
+	if b.mode == BondRedundant {
+		return size + BondSeqLen
+	}
+	return size
+}
+
+func (b *Bond) Add(path SocketClient) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, p := range b.paths {
+		if p == path {
+			return
+		}
+	}
+	b.paths = append(b.paths, path)
+}
+
+// Remove deletes the path, and returns size of paths remained.
+func (b *Bond) Remove(path SocketClient) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i, p := range b.paths {
+		if p == path {
+			b.paths = append(b.paths[:i], b.paths[i+1:]...)
+			break
+		}
+	}
+	return len(b.paths)
+}
+
+func (b *Bond) Paths() []SocketClient {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return append([]SocketClient{}, b.paths...)
+}
+
+func (b *Bond) Size() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return len(b.paths)
+}
+
+// ready returns paths authenticated.
+func (b *Bond) ready() []SocketClient {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	paths := make([]SocketClient, 0, len(b.paths))
+	for _, p := range b.paths {
+		if p.Have(ClAuth) && p.IsOk() {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// WriteMsg writes frame to the path of its flow, and others are tried if
+// failed. Frame is encrypted in place by path, so it's restored from the
+// plain copied before written again.
+func (b *Bond) WriteMsg(frame *FrameMessage) error {
+	paths := b.ready()
+	size := len(paths)
+	if size == 0 {
+		return NewErr("no path ready")
+	}
+	var err error
+	if b.mode == BondRedundant {
+		okay := 0
+		seq := atomic.AddUint64(&b.seq, 1)
+		for _, p := range paths {
+			f := stampFrame(frame, seq)
+			if err = p.WriteMsg(f); err != nil {
+				Warn("Bond.WriteMsg: %s %s", p, err)
+				continue
+			}
+			okay++
+		}
+		if okay == 0 {
+			return err
+		}
+		return nil
+	}
+	start := int(FlowHash(frame) % uint32(size))
+	if size == 1 {
+		return paths[start].WriteMsg(frame)
+	}
+	plain := plainPool.Get().([]byte)
+	defer plainPool.Put(plain)
+	n := copy(plain, frame.frame[:frame.size])
+	for i := 0; i < size; i++ {
+		if i > 0 {
+			copy(frame.frame, plain[:n])
+			frame.size = n
+		}
+		p := paths[(start+i)%size]
+		if err = p.WriteMsg(frame); err == nil {
+			return nil
+		}
+		Warn("Bond.WriteMsg: %s %s", p, err)
+	}
+	return err
+}
+
+// Accept returns false if frame of the sequence is received by other path
+// already, and only frames of redundant are checked. The sequence is
+// removed from frame accepted.
+func (b *Bond) Accept(frame *FrameMessage) bool {
+	if b.mode != BondRedundant {
+		return true
+	}
+	if frame.size < BondSeqLen {
+		return false
+	}
+	frame.size -= BondSeqLen
+	seq := binary.BigEndian.Uint64(frame.frame[frame.size : frame.size+BondSeqLen])
+	frame.frame = frame.frame[:frame.size]
+	frame.proto = nil
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return !b.seen.Seen(seq, now)
+}
+
+// Client Implement
+
+// BondClient holds paths to the same switch as one client, and switch
+// groups paths by uuid and session of point. It is okay if any path is
+// connected, and a path failed is dialed again in background.
+type BondClient struct {
+	socketClient
+	bond    *Bond
+	paths   []SocketClient
+	queue   chan *FrameMessage
+	session string
+	login   string // request replayed to path connected again.
+	epoch   int    // increased by closing to stop reading and dialing.
+}
+
+func NewBondClient(mode string, paths []SocketClient) *BondClient {
+	addrs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		addrs = append(addrs, p.Addr())
+	}
+	c := &BondClient{
+		bond:    NewBond(mode),
+		paths:   paths,
+		queue:   make(chan *FrameMessage, 1024),
+		session: GenToken(16),
+		socketClient: socketClient{
+			address: strings.Join(addrs, ","),
+			newTime: time.Now().Unix(),
+			dataStream: dataStream{
+				maxSize: 1514,
+				minSize: 15,
+			},
+			status: ClInit,
+		},
+	}
+	for _, p := range paths {
+		path := p
+		path.SetMaxSize(c.bond.MaxSize(c.maxSize))
+		path.SetListener(ClientListener{
+			OnConnected: func(client SocketClient) error {
+				c.onConnected(path)
+				return nil
+			},
+			OnClose: func(client SocketClient) error {
+				c.bond.Remove(path)
+				return nil
+			},
+		})
+	}
+	return c
+}
+
+func (c *BondClient) Mode() string {
+	return c.bond.Mode()
+}
+
+func (c *BondClient) Session() string {
+	return c.session
+}
+
+func (c *BondClient) Paths() []SocketClient {
+	return c.paths
+}
+
+func (c *BondClient) getEpoch() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.epoch
+}
+
+// getQueue returns queue of the current epoch, and it's replaced by
+// closing.
+func (c *BondClient) getQueue() (int, chan *FrameMessage) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.epoch, c.queue
+}
+
+func (c *BondClient) getLogin() string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.login
+}
+
+func (c *BondClient) onConnected(path SocketClient) {
+	Info("BondClient.onConnected: %s", path)
+	c.bond.Add(path)
+	epoch, queue := c.getQueue()
+	Go(func() { c.read(path, epoch, queue) })
+	// path recovered logins again.
+	if login := c.getLogin(); login != "" && c.Have(ClAuth) {
+		if err := path.WriteReq("login", login); err != nil {
+			Warn("BondClient.onConnected: %s %s", path, err)
+		}
+	}
+}
+
+func (c *BondClient) read(path SocketClient, epoch int, queue chan *FrameMessage) {
+	for {
+		frame, err := path.ReadMsg()
+		if err != nil {
+			Warn("BondClient.read: %s %s", path, err)
+			break
+		}
+		if c.filter(path, frame) {
+			queue <- frame
+		}
+	}
+	path.Close()
+	c.bond.Remove(path)
+	if epoch != c.getEpoch() || c.Have(ClTerminal) {
+		return
+	}
+	if c.IsOk() {
+		c.redial(path, epoch)
+	} else {
+		queue <- nil // wakeup reader.
+	}
+}
+
+// filter returns false if frame is duplicated, or control of path which
+// is processed by bond.
+func (c *BondClient) filter(path SocketClient, frame *FrameMessage) bool {
+	if !frame.Decode() {
+		return c.bond.Accept(frame)
+	}
+	action, params := frame.CmdAndParams()
+	switch action {
+	case "logi:":
+		if !strings.HasPrefix(params, "okay") {
+			return !c.Have(ClAuth)
+		}
+		path.SetStatus(ClAuth)
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.status == ClAuth {
+			return false
+		}
+		c.status = ClAuth
+	case "sign=":
+		if login := c.getLogin(); login != "" && c.Have(ClAuth) {
+			path.SetStatus(ClConnected)
+			_ = path.WriteReq("login", login)
+			return false
+		}
+	}
+	return true
+}
+
+// redial connects path again until success or bond is closed.
+func (c *BondClient) redial(path SocketClient, epoch int) {
+	for i := 1; ; i++ {
+		delay := 2 * i
+		if delay > 30 {
+			delay = 30
+		}
+		time.Sleep(time.Duration(delay) * time.Second)
+		if epoch != c.getEpoch() || c.Have(ClTerminal) {
+			return
+		}
+		if path.IsOk() {
+			return
+		}
+		if err := path.Connect(); err != nil {
+			Warn("BondClient.redial: %s %s", path, err)
+			continue
+		}
+		return
+	}
+}
+
+func (c *BondClient) Connect() error {
+	c.lock.Lock()
+	if c.status == ClTerminal || c.status == ClUnAuth {
+		c.lock.Unlock()
+		return nil
+	}
+	c.status = ClConnecting
+	epoch := c.epoch
+	c.lock.Unlock()
+
+	var err error
+	failed := make([]SocketClient, 0, len(c.paths))
+	for _, p := range c.paths {
+		if p.IsOk() {
+			continue
+		}
+		if p.Have(ClTerminal) {
+			p.SetStatus(ClInit)
+		}
+		if err = p.Connect(); err != nil {
+			Warn("BondClient.Connect: %s %s", p, err)
+			failed = append(failed, p)
+		}
+	}
+	if !c.IsOk() {
+		return err
+	}
+	for _, p := range failed {
+		path := p
+		Go(func() { c.redial(path, epoch) })
+	}
+	c.lock.Lock()
+	c.status = ClConnected
+	c.lock.Unlock()
+	if c.listener.OnConnected != nil {
+		_ = c.listener.OnConnected(c)
+	}
+	return nil
+}
+
+func (c *BondClient) Close() {
+	opened := c.IsOk()
+	c.lock.Lock()
+	c.epoch++
+	if opened && c.status != ClTerminal {
+		c.status = ClClosed
+	}
+	c.private = nil
+	queue := c.queue
+	c.queue = make(chan *FrameMessage, 1024)
+	c.lock.Unlock()
+	for _, p := range c.paths {
+		p.Close()
+	}
+	for len(queue) > 0 {
+		<-queue
+	}
+	// wakeup reader waiting on queue closed.
+	select {
+	case queue <- nil:
+	default:
+	}
+	if opened {
+		Info("BondClient.Close: %s", c.address)
+		if c.listener.OnClose != nil {
+			_ = c.listener.OnClose(c)
+		}
+	}
+}
+
+func (c *BondClient) Terminal() {
+	c.SetStatus(ClTerminal)
+	for _, p := range c.paths {
+		p.SetStatus(ClTerminal)
+	}
+	c.Close()
+}
+
+func (c *BondClient) SetStatus(v uint8) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.status != v {
+		if c.listener.OnStatus != nil {
+			c.listener.OnStatus(c, c.status, v)
+		}
+		c.status = v
+	}
+}
+
+func (c *BondClient) IsOk() bool {
+	for _, p := range c.paths {
+		if p.IsOk() {
+			return true
+		}
+	}
+	return false
+}
+
+// primary returns the first path authenticated, or connected.
+func (c *BondClient) primary() SocketClient {
+	var conn SocketClient
+	for _, p := range c.paths {
+		if !p.IsOk() {
+			continue
+		}
+		if p.Have(ClAuth) {
+			return p
+		}
+		if conn == nil {
+			conn = p
+		}
+	}
+	return conn
+}
+
+func (c *BondClient) WriteMsg(frame *FrameMessage) error {
+	return c.bond.WriteMsg(frame)
+}
+
+func (c *BondClient) ReadMsg() (*FrameMessage, error) {
+	if !c.IsOk() {
+		return nil, NewErr("%s: not okay", c)
+	}
+	_, queue := c.getQueue()
+	frame := <-queue
+	if frame == nil {
+		return nil, NewErr("%s: no path", c)
+	}
+	return frame, nil
+}
+
+// WriteReq sends request of login, ping and left by all paths, and others
+// by the primary path.
+func (c *BondClient) WriteReq(action string, body string) error {
+	switch action {
+	case "login":
+		c.lock.Lock()
+		c.login = body
+		c.lock.Unlock()
+		fallthrough
+	case "ping", "left":
+		var err error
+		okay := 0
+		for _, p := range c.paths {
+			if !p.IsOk() {
+				continue
+			}
+			if err = p.WriteReq(action, body); err == nil {
+				okay++
+			}
+		}
+		if okay == 0 {
+			if err == nil {
+				err = NewErr("%s: no path", c)
+			}
+			return err
+		}
+		return nil
+	}
+	if p := c.primary(); p != nil {
+		return p.WriteReq(action, body)
+	}
+	return NewErr("%s: no path", c)
+}
+
+func (c *BondClient) WriteResp(action string, body string) error {
+	if p := c.primary(); p != nil {
+		return p.WriteResp(action, body)
+	}
+	return NewErr("%s: no path", c)
+}
+
+func (c *BondClient) LocalAddr() string {
+	if p := c.primary(); p != nil {
+		return p.LocalAddr()
+	}
+	return c.address
+}
+
+func (c *BondClient) RemoteAddr() string {
+	if p := c.primary(); p != nil {
+		return p.RemoteAddr()
+	}
+	return c.address
+}
+
+func (c *BondClient) SetMaxSize(value int) {
+	c.maxSize = value
+	for _, p := range c.paths {
+		p.SetMaxSize(c.bond.MaxSize(value))
+	}
+}
+
+func (c *BondClient) SetTimeout(v int64) {
+	c.timeout = v
+	for _, p := range c.paths {
+		p.SetTimeout(v)
+	}
+}
+
+func (c *BondClient) Sts() ClientSts {
+	sts := ClientSts{}
+	for _, p := range c.paths {
+		s := p.Sts()
+		sts.SendOkay += s.SendOkay
+		sts.RecvOkay += s.RecvOkay
+		sts.SendFrames += s.SendFrames
+		sts.RecvFrames += s.RecvFrames
+		sts.SendError += s.SendError
+		sts.Dropped += s.Dropped
+		sts.AuthError += s.AuthError
+	}
+	return sts
+}
+
+func (c *BondClient) PeerCert() *x509.Certificate {
+	if p := c.primary(); p != nil {
+		return p.PeerCert()
+	}
+	return nil
+}
+
+// localIP returns address of local, which is address or name of interface.
+func localIP(local string) net.IP {
+	if ip := net.ParseIP(local); ip != nil {
+		return ip
+	}
+	ifi, err := net.InterfaceByName(local)
+	if err != nil {
+		Warn("localIP: %s", err)
+		return nil
+	}
+	addrs, _ := ifi.Addrs()
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+	}
+	Warn("localIP: %s has no address", local)
+	return nil
+}
@@ -0,0 +1,165 @@
+package libol
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestFrame(name string) *FrameMessage {
+	for _, c := range protoFrames {
+		if c.name == name {
+			data, _ := hex.DecodeString(c.frame)
+			frame := NewFrameMessage()
+			frame.Append(data)
+			return frame
+		}
+	}
+	return nil
+}
+
+func TestFlowHash(t *testing.T) {
+	assert.Equal(t, FlowHash(newTestFrame("udp4")), FlowHash(newTestFrame("udp4")), "be the same.")
+	assert.Equal(t, FlowHash(newTestFrame("udp4")), FlowHash(newTestFrame("vlan4")), "be the same.")
+	assert.NotEqual(t, FlowHash(newTestFrame("udp4")), FlowHash(newTestFrame("tcp4")), "not same.")
+}
+
+func TestBondAccept(t *testing.T) {
+	b := NewBond(BondRedundant)
+	frame := stampFrame(newTestFrame("udp4"), 1)
+	size := frame.Size()
+	assert.True(t, b.Accept(frame), "first.")
+	assert.Equal(t, size-BondSeqLen, frame.Size(), "sequence removed.")
+	assert.Equal(t, newTestFrame("udp4").Frame()[:frame.Size()], frame.Frame(), "be the same.")
+	assert.False(t, b.Accept(stampFrame(newTestFrame("udp4"), 1)), "duplicated.")
+	assert.True(t, b.Accept(stampFrame(newTestFrame("udp4"), 2)), "same frame again.")
+	assert.True(t, b.Accept(stampFrame(newTestFrame("tcp4"), 3)), "other.")
+	assert.Equal(t, 1514+BondSeqLen, b.MaxSize(1514), "be the same.")
+
+	d := newDedup(1000)
+	assert.False(t, d.Seen(1, 1000), "first.")
+	assert.True(t, d.Seen(1, 1500), "current.")
+	assert.True(t, d.Seen(1, 2500), "last.")
+	assert.False(t, d.Seen(1, 5000), "expired.")
+
+	b = NewBond("")
+	assert.Equal(t, BondHash, b.Mode(), "be the same.")
+	assert.Equal(t, 1514, b.MaxSize(1514), "be the same.")
+	assert.True(t, b.Accept(newTestFrame("udp4")), "first.")
+	assert.True(t, b.Accept(newTestFrame("udp4")), "not checked.")
+}
+
+func TestBondClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen.")
+	defer listener.Close()
+	conns := make(chan net.Conn, 8)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	accept := func() *TcpClient {
+		select {
+		case conn := <-conns:
+			return NewTcpClientFromConn(conn, &TcpConfig{})
+		case <-time.After(2 * time.Second):
+			return nil
+		}
+	}
+	action := func(frame *FrameMessage) string {
+		frame.Decode()
+		action, _ := frame.CmdAndParams()
+		return action
+	}
+
+	addr := listener.Addr().String()
+	paths := []SocketClient{
+		NewTcpClient(addr, &TcpConfig{}),
+		NewTcpClient(addr, &TcpConfig{}),
+	}
+	c := NewBondClient(BondRedundant, paths)
+	defer c.Terminal()
+	assert.Nil(t, c.Connect(), "connect.")
+	assert.True(t, c.IsOk(), "okay.")
+	servers := []*TcpClient{accept(), accept()}
+	assert.NotNil(t, servers[0], "accepted.")
+	assert.NotNil(t, servers[1], "accepted.")
+
+	// login by all paths, and response once.
+	assert.Nil(t, c.WriteReq("login", `{"name":"hi"}`), "login.")
+	for _, s := range servers {
+		frame, err := s.ReadMsg()
+		assert.Nil(t, err, "read.")
+		assert.Equal(t, "logi=", action(frame), "be the same.")
+		assert.Nil(t, s.WriteResp("login", "okay."), "write back.")
+	}
+	frame, err := c.ReadMsg()
+	assert.Nil(t, err, "read.")
+	assert.Equal(t, "logi:", action(frame), "be the same.")
+	assert.True(t, c.Have(ClAuth), "auth.")
+	for i := 0; i < 20 && len(c.bond.ready()) < 2; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, 2, len(c.bond.ready()), "all paths auth.")
+
+	// duplicated to all paths by the same sequence.
+	assert.Nil(t, c.WriteMsg(newTestFrame("udp4")), "write.")
+	seqs := make(map[string]bool, 2)
+	for _, s := range servers {
+		frame, err := s.ReadMsg()
+		assert.Nil(t, err, "read.")
+		size := frame.Size() - BondSeqLen
+		assert.Equal(t, newTestFrame("udp4").Frame()[:size], frame.Frame()[:size], "be the same.")
+		seqs[string(frame.Frame()[size:])] = true
+	}
+	assert.Equal(t, 1, len(seqs), "same sequence.")
+
+	// duplicated received once.
+	for _, s := range servers {
+		s.SetMaxSize(1514 + BondSeqLen)
+		assert.Nil(t, s.WriteMsg(stampFrame(newTestFrame("udp4"), 7)), "write.")
+	}
+	assert.Nil(t, servers[0].WriteResp("pong", "okay."), "write.")
+	actions := make(map[string]int, 2)
+	for i := 0; i < 2; i++ {
+		frame, err := c.ReadMsg()
+		assert.Nil(t, err, "read.")
+		actions[action(frame)]++
+	}
+	assert.Equal(t, map[string]int{"": 1, "pong:": 1}, actions, "be the same.")
+
+	// path failed is removed.
+	servers[0].Close()
+	for i := 0; i < 20 && c.bond.Size() > 1; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, 1, c.bond.Size(), "removed.")
+	assert.True(t, c.IsOk(), "okay.")
+	assert.Nil(t, c.WriteMsg(newTestFrame("tcp4")), "write.")
+	frame, err = servers[1].ReadMsg()
+	assert.Nil(t, err, "read.")
+	size := frame.Size() - BondSeqLen
+	assert.Equal(t, newTestFrame("tcp4").Frame()[:size], frame.Frame()[:size], "be the same.")
+
+	// reader waiting is waked up by closing.
+	read := make(chan error, 1)
+	go func() {
+		_, err := c.ReadMsg()
+		read <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-read:
+		assert.NotNil(t, err, "closed.")
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "reader not waked up.")
+	}
+}
@@ -44,3 +44,21 @@ func (b *BrCtl) Stp(on bool) error {
 	}
 	return nil
 }
+
+// Isolate sets the port not to forward frames to other isolated ports
+// of its bridge.
+func (b *BrCtl) Isolate(port string, on bool) error {
+	file := fmt.Sprintf("/sys/class/net/%s/brport/isolated", port)
+	fp, err := os.OpenFile(file, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	if on {
+		_, err = fp.Write([]byte("1"))
+	} else {
+		_, err = fp.Write([]byte("0"))
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package libol
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+const (
+	DhcpBootRequest = 1
+	DhcpBootReply   = 2
+	DhcpMagic       = 0x63825363
+	DhcpServerPort  = 67
+	DhcpClientPort  = 68
+	DhcpLen         = 240 // fixed header and magic cookie.
+	DhcpBroadcast   = 0x8000
+)
+
+// message types in option 53.
+const (
+	DhcpDiscover = 1
+	DhcpOffer    = 2
+	DhcpRequest  = 3
+	DhcpDecline  = 4
+	DhcpAck      = 5
+	DhcpNak      = 6
+	DhcpRelease  = 7
+	DhcpInform   = 8
+)
+
+const (
+	DhcpOptPad       = 0
+	DhcpOptNetmask   = 1
+	DhcpOptRouter    = 3
+	DhcpOptDns       = 6
+	DhcpOptMtu       = 26
+	DhcpOptRequestIp = 50
+	DhcpOptLease     = 51
+	DhcpOptType      = 53
+	DhcpOptServer    = 54
+	DhcpOptParams    = 55
+	DhcpOptRenewal   = 58
+	DhcpOptRebind    = 59
+	DhcpOptRoutes    = 121 // classless static routes.
+	DhcpOptEnd       = 255
+)
+
+type DhcpOption struct {
+	Code  uint8
+	Value []byte
+}
+
+type Dhcp struct {
+	Op      uint8
+	HType   uint8
+	HLen    uint8
+	Hops    uint8
+	Xid     uint32
+	Secs    uint16
+	Flags   uint16
+	CIAddr  []byte // client address.
+	YIAddr  []byte // your address.
+	SIAddr  []byte // next server address.
+	GIAddr  []byte // relay agent address.
+	CHAddr  []byte // client hardware address.
+	Options []DhcpOption
+	Len     int
+}
+
+func NewDhcp() (d *Dhcp) {
+	d = &Dhcp{
+		HType:   ArpHrdEther,
+		HLen:    6,
+		CIAddr:  make([]byte, 4),
+		YIAddr:  make([]byte, 4),
+		SIAddr:  make([]byte, 4),
+		GIAddr:  make([]byte, 4),
+		CHAddr:  make([]byte, 16),
+		Options: make([]DhcpOption, 0, 16),
+	}
+	return
+}
+
+func NewDhcpFromFrame(frame []byte) (d *Dhcp, err error) {
+	d = NewDhcp()
+	err = d.Decode(frame)
+	return
+}
+
+func (d *Dhcp) Decode(frame []byte) error {
+	if len(frame) < DhcpLen {
+		return NewErr("Dhcp.Decode: too small header: %d", len(frame))
+	}
+
+	d.Op = frame[0]
+	d.HType = frame[1]
+	d.HLen = frame[2]
+	d.Hops = frame[3]
+	if d.HLen > 16 {
+		return NewErr("Dhcp.Decode: HLen: %d", d.HLen)
+	}
+	d.Xid = binary.BigEndian.Uint32(frame[4:8])
+	d.Secs = binary.BigEndian.Uint16(frame[8:10])
+	d.Flags = binary.BigEndian.Uint16(frame[10:12])
+	copy(d.CIAddr[:4], frame[12:16])
+	copy(d.YIAddr[:4], frame[16:20])
+	copy(d.SIAddr[:4], frame[20:24])
+	copy(d.GIAddr[:4], frame[24:28])
+	copy(d.CHAddr[:16], frame[28:44])
+	if binary.BigEndian.Uint32(frame[236:240]) != DhcpMagic {
+		return NewErr("Dhcp.Decode: not magic cookie")
+	}
+
+	d.Options = d.Options[:0]
+	p := DhcpLen
+	for p < len(frame) {
+		code := frame[p]
+		p++
+		if code == DhcpOptEnd {
+			break
+		}
+		if code == DhcpOptPad {
+			continue
+		}
+		if p >= len(frame) {
+			return NewErr("Dhcp.Decode: option %d without length", code)
+		}
+		size := int(frame[p])
+		p++
+		if p+size > len(frame) {
+			return NewErr("Dhcp.Decode: option %d too long: %d", code, size)
+		}
+		value := make([]byte, size)
+		copy(value, frame[p:p+size])
+		d.Options = append(d.Options, DhcpOption{Code: code, Value: value})
+		p += size
+	}
+	d.Len = p
+
+	return nil
+}
+
+func (d *Dhcp) Encode() []byte {
+	size := DhcpLen + 1
+	for _, opt := range d.Options {
+		size += 2 + len(opt.Value)
+	}
+	if size < 300 { // minimum of BOOTP.
+		size = 300
+	}
+	buffer := make([]byte, size)
+
+	buffer[0] = d.Op
+	buffer[1] = d.HType
+	buffer[2] = d.HLen
+	buffer[3] = d.Hops
+	binary.BigEndian.PutUint32(buffer[4:8], d.Xid)
+	binary.BigEndian.PutUint16(buffer[8:10], d.Secs)
+	binary.BigEndian.PutUint16(buffer[10:12], d.Flags)
+	copy(buffer[12:16], d.CIAddr[:4])
+	copy(buffer[16:20], d.YIAddr[:4])
+	copy(buffer[20:24], d.SIAddr[:4])
+	copy(buffer[24:28], d.GIAddr[:4])
+	copy(buffer[28:44], d.CHAddr[:16])
+	binary.BigEndian.PutUint32(buffer[236:240], DhcpMagic)
+
+	p := DhcpLen
+	for _, opt := range d.Options {
+		buffer[p] = opt.Code
+		buffer[p+1] = uint8(len(opt.Value))
+		copy(buffer[p+2:], opt.Value)
+		p += 2 + len(opt.Value)
+	}
+	buffer[p] = DhcpOptEnd
+	d.Len = size
+
+	return buffer
+}
+
+func (d *Dhcp) Option(code uint8) []byte {
+	for _, opt := range d.Options {
+		if opt.Code == code {
+			return opt.Value
+		}
+	}
+	return nil
+}
+
+func (d *Dhcp) SetOption(code uint8, value []byte) {
+	for i, opt := range d.Options {
+		if opt.Code == code {
+			d.Options[i].Value = value
+			return
+		}
+	}
+	d.Options = append(d.Options, DhcpOption{Code: code, Value: value})
+}
+
+// Type returns the message type in option 53, or zero for BOOTP.
+func (d *Dhcp) Type() uint8 {
+	if value := d.Option(DhcpOptType); len(value) == 1 {
+		return value[0]
+	}
+	return 0
+}
+
+func (d *Dhcp) HwAddr() net.HardwareAddr {
+	return net.HardwareAddr(d.CHAddr[:d.HLen])
+}
+
+// DhcpRoute encodes a route in option 121 by RFC3442.
+func DhcpRoute(prefix *net.IPNet, nexthop net.IP) []byte {
+	ones, _ := prefix.Mask.Size()
+	size := (ones + 7) / 8
+	buffer := make([]byte, 0, 1+size+4)
+	buffer = append(buffer, uint8(ones))
+	buffer = append(buffer, prefix.IP.To4()[:size]...)
+	return append(buffer, nexthop.To4()...)
+}
+
+// IpChecksum returns the checksum of ipv4 header.
+func IpChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	if len(header)%2 == 1 {
+		sum += uint32(header[len(header)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
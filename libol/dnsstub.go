@@ -0,0 +1,241 @@
+package libol
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	DnsPort    = 53
+	DnsHdrLen  = 12
+	DnsMaxSize = 65535
+)
+
+// DnsName returns name of the first question in message.
+func DnsName(msg []byte) (string, error) {
+	if len(msg) < DnsHdrLen {
+		return "", NewErr("dns message too short")
+	}
+	if binary.BigEndian.Uint16(msg[4:6]) == 0 {
+		return "", NewErr("dns message has no question")
+	}
+	labels := make([]string, 0, 8)
+	for i := DnsHdrLen; i < len(msg); {
+		size := int(msg[i])
+		if size == 0 {
+			return strings.Join(labels, "."), nil
+		}
+		if size&0xc0 != 0 || i+1+size > len(msg) {
+			break
+		}
+		labels = append(labels, string(msg[i+1:i+1+size]))
+		i += 1 + size
+	}
+	return "", NewErr("dns question invalid")
+}
+
+// InDomains returns true if name is one of domains, or a subdomain of them.
+func InDomains(name string, domains []string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, d := range domains {
+		d = strings.ToLower(strings.Trim(d, "."))
+		if d == "" {
+			continue
+		}
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// DnsStub is a local resolver, and it forwards queries in domains to
+// servers, and others to fallback.
+type DnsStub struct {
+	address  string
+	domains  []string
+	servers  []string
+	fallback []string
+	timeout  time.Duration
+	conn     net.PacketConn
+	listener net.Listener
+}
+
+func NewDnsStub(address string, domains, servers, fallback []string) *DnsStub {
+	return &DnsStub{
+		address:  address,
+		domains:  domains,
+		servers:  DnsServers(servers),
+		fallback: DnsServers(fallback),
+		timeout:  2 * time.Second,
+	}
+}
+
+// DnsServers returns addresses with port 53 if not given.
+func DnsServers(servers []string) []string {
+	addrs := make([]string, 0, len(servers))
+	for _, s := range servers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(s, "53")
+		}
+		addrs = append(addrs, s)
+	}
+	return addrs
+}
+
+func (d *DnsStub) String() string {
+	return d.Addr()
+}
+
+func (d *DnsStub) Addr() string {
+	if d.conn != nil {
+		return d.conn.LocalAddr().String()
+	}
+	return d.address
+}
+
+// Listen listens on udp and tcp of the same address, and port of tcp may
+// be taken if ephemeral, so tries again.
+func (d *DnsStub) Listen() error {
+	var conn net.PacketConn
+	var listener net.Listener
+	var err error
+	for i := 0; i < 8; i++ {
+		conn, err = net.ListenPacket("udp", d.address)
+		if err != nil {
+			return err
+		}
+		listener, err = net.Listen("tcp", conn.LocalAddr().String())
+		if err == nil {
+			break
+		}
+		_ = conn.Close()
+		if _, port, _ := net.SplitHostPort(d.address); port != "0" {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+	d.listener = listener
+	Info("DnsStub.Listen: %s %s", d, d.domains)
+	return nil
+}
+
+func (d *DnsStub) Close() {
+	if d.conn != nil {
+		_ = d.conn.Close()
+		_ = d.listener.Close()
+		Info("DnsStub.Close: %s", d)
+	}
+}
+
+// upstream returns servers to forward query by its name.
+func (d *DnsStub) upstream(msg []byte) []string {
+	name, err := DnsName(msg)
+	if err != nil {
+		return nil
+	}
+	if InDomains(name, d.domains) {
+		return d.servers
+	}
+	return d.fallback
+}
+
+func (d *DnsStub) Accept() {
+	defer Info("DnsStub.Accept: %s exit", d)
+	go d.acceptTcp()
+	for {
+		buf := make([]byte, DnsMaxSize)
+		n, addr, err := d.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		go func() {
+			if resp := d.exchange("udp", buf[:n]); resp != nil {
+				_, _ = d.conn.WriteTo(resp, addr)
+			}
+		}()
+	}
+}
+
+func (d *DnsStub) acceptTcp() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.serveTcp(conn)
+	}
+}
+
+func (d *DnsStub) serveTcp(conn net.Conn) {
+	defer conn.Close()
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		msg, err := readDnsTcp(conn)
+		if err != nil {
+			return
+		}
+		resp := d.exchange("tcp", msg)
+		if resp == nil {
+			return
+		}
+		if err := writeDnsTcp(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+func readDnsTcp(conn net.Conn) ([]byte, error) {
+	size := make([]byte, 2)
+	if _, err := io.ReadFull(conn, size); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(size))
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeDnsTcp(conn net.Conn, msg []byte) error {
+	buf := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	_, err := conn.Write(append(buf, msg...))
+	return err
+}
+
+// exchange forwards query to servers in turn, and returns the first
+// response.
+func (d *DnsStub) exchange(network string, msg []byte) []byte {
+	for _, server := range d.upstream(msg) {
+		conn, err := net.DialTimeout(network, server, d.timeout)
+		if err != nil {
+			Debug("DnsStub.exchange: %s", err)
+			continue
+		}
+		_ = conn.SetDeadline(time.Now().Add(d.timeout))
+		var resp []byte
+		if network == "tcp" {
+			if err = writeDnsTcp(conn, msg); err == nil {
+				resp, err = readDnsTcp(conn)
+			}
+		} else if _, err = conn.Write(msg); err == nil {
+			buf := make([]byte, DnsMaxSize)
+			n, rErr := conn.Read(buf)
+			resp, err = buf[:n], rErr
+		}
+		_ = conn.Close()
+		if err != nil {
+			Debug("DnsStub.exchange: %s %s", server, err)
+			continue
+		}
+		return resp
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package libol
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newDnsQuery(name string) []byte {
+	msg := make([]byte, DnsHdrLen, 64)
+	binary.BigEndian.PutUint16(msg[0:2], 0x1234)
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0x00, 0x00, 0x01, 0x00, 0x01)
+}
+
+// newDnsServer answers queries with the query and mark appended.
+func newDnsServer(mark byte) net.PacketConn {
+	conn, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	go func() {
+		for {
+			buf := make([]byte, 512)
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(append(buf[:n], mark), addr)
+		}
+	}()
+	return conn
+}
+
+func TestDnsName(t *testing.T) {
+	name, err := DnsName(newDnsQuery("www.example.com"))
+	assert.Nil(t, err, "parse.")
+	assert.Equal(t, "www.example.com", name, "be the same.")
+	_, err = DnsName([]byte{0x12, 0x34})
+	assert.NotNil(t, err, "too short.")
+
+	assert.True(t, InDomains("db.corp.lan.", []string{"corp.lan"}), "subdomain.")
+	assert.True(t, InDomains("Corp.LAN", []string{".corp.lan."}), "same.")
+	assert.False(t, InDomains("xcorp.lan", []string{"corp.lan"}), "not in.")
+}
+
+func TestDnsStub(t *testing.T) {
+	overlay := newDnsServer('o')
+	defer overlay.Close()
+	public := newDnsServer('p')
+	defer public.Close()
+
+	stub := NewDnsStub("127.0.0.1:0", []string{"corp.lan"},
+		[]string{"127.0.0.1:1", overlay.LocalAddr().String()},
+		[]string{public.LocalAddr().String()})
+	stub.timeout = 200 * time.Millisecond
+	assert.Nil(t, stub.Listen(), "listen.")
+	defer stub.Close()
+	go stub.Accept()
+
+	for name, mark := range map[string]byte{"db.corp.lan": 'o', "www.example.com": 'p'} {
+		conn, err := net.Dial("udp", stub.Addr())
+		assert.Nil(t, err, "dial.")
+		query := newDnsQuery(name)
+		_, _ = conn.Write(query)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		buf := make([]byte, 512)
+		n, err := conn.Read(buf)
+		assert.Nil(t, err, name)
+		assert.Equal(t, append(query, mark), buf[:n], name)
+		_ = conn.Close()
+	}
+	assert.Equal(t, []string{"10.0.0.2:53", "10.0.0.3:5353"},
+		DnsServers([]string{"10.0.0.2", "10.0.0.3:5353"}), "be the same.")
+}
@@ -0,0 +1,126 @@
+package libol
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// ListenFunc listens on addr, such as net.Listen or a userspace stack.
+type ListenFunc func(network, addr string) (net.Listener, error)
+
+// Relay copies in both directions, and closes both if either is done.
+func Relay(conn, target net.Conn) {
+	go func() {
+		_, _ = io.Copy(target, conn)
+		_ = target.Close()
+	}()
+	_, _ = io.Copy(conn, target)
+	_ = conn.Close()
+}
+
+// Forwarder accepts connections on address, and forwards each of them to
+// target connected by dial.
+type Forwarder struct {
+	lock     sync.Mutex
+	address  string
+	target   string
+	listen   ListenFunc
+	dial     DialFunc
+	listener net.Listener
+	conns    map[net.Conn]bool // accepted and forwarding.
+	total    uint64
+}
+
+func NewForwarder(address, target string, listen ListenFunc, dial DialFunc) *Forwarder {
+	return &Forwarder{
+		address: address,
+		target:  target,
+		listen:  listen,
+		dial:    dial,
+		conns:   make(map[net.Conn]bool, 32),
+	}
+}
+
+func (f *Forwarder) String() string {
+	return f.Addr() + "->" + f.target
+}
+
+// Addr returns address listened on, and port is known if listened on 0.
+func (f *Forwarder) Addr() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.listener != nil {
+		return f.listener.Addr().String()
+	}
+	return f.address
+}
+
+func (f *Forwarder) Listen() error {
+	listener, err := f.listen("tcp", f.address)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	f.listener = listener
+	f.lock.Unlock()
+	Info("Forwarder.Listen: %s", f)
+	return nil
+}
+
+// Close stops listening, and closes connections forwarding.
+func (f *Forwarder) Close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.listener != nil {
+		_ = f.listener.Close()
+		Info("Forwarder.Close: %s", f.listener.Addr())
+	}
+	for conn := range f.conns {
+		_ = conn.Close()
+	}
+}
+
+func (f *Forwarder) Accept() {
+	defer Info("Forwarder.Accept: %s exit", f.address)
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.lock.Lock()
+		f.conns[conn] = true
+		f.total++
+		f.lock.Unlock()
+		go f.forward(conn)
+	}
+}
+
+func (f *Forwarder) forward(conn net.Conn) {
+	defer func() {
+		f.lock.Lock()
+		delete(f.conns, conn)
+		f.lock.Unlock()
+	}()
+	target, err := f.dial("tcp", f.target)
+	if err != nil {
+		Warn("Forwarder.forward: %s", err)
+		_ = conn.Close()
+		return
+	}
+	Relay(conn, target)
+}
+
+// Active returns number of connections forwarding.
+func (f *Forwarder) Active() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.conns)
+}
+
+// Total returns number of connections accepted.
+func (f *Forwarder) Total() uint64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.total
+}
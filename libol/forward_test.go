@@ -0,0 +1,45 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestForwarder(t *testing.T) {
+	echo, err := newEchoServer()
+	assert.Nil(t, err, "listen.")
+	defer echo.Close()
+
+	f := NewForwarder("127.0.0.1:0", echo.Addr().String(), net.Listen, net.Dial)
+	assert.Nil(t, f.Listen(), "listen.")
+	go f.Accept()
+	conn, err := net.Dial("tcp", f.Addr())
+	assert.Nil(t, err, "dial.")
+	_, err = conn.Write([]byte("hello"))
+	assert.Nil(t, err, "write.")
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	assert.Nil(t, err, "read.")
+	assert.Equal(t, "hello", string(buf), "be the same.")
+	assert.Equal(t, 1, f.Active(), "forwarding.")
+	assert.Equal(t, uint64(1), f.Total(), "be the same.")
+
+	_ = conn.Close()
+	for i := 0; i < 20 && f.Active() > 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, 0, f.Active(), "closed.")
+
+	// closed with forwarding.
+	conn, _ = net.Dial("tcp", f.Addr())
+	_, _ = conn.Write([]byte("hello"))
+	_, _ = io.ReadFull(conn, buf)
+	f.Close()
+	_, err = conn.Read(buf)
+	assert.Equal(t, io.EOF, err, "closed.")
+	_, err = net.Dial("tcp", f.Addr())
+	assert.NotNil(t, err, "not listen.")
+}
@@ -28,6 +28,12 @@ func (t *gos) Del(call interface{}) {
 	Debug("gos.Del %d %p", t.total, call)
 }
 
+func (t *gos) Total() uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.total
+}
+
 func Go(call func()) {
 	name := "Go"
 	pc, _, line, ok := runtime.Caller(1)
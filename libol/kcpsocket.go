@@ -7,6 +7,7 @@ import (
 )
 
 type KcpConfig struct {
+	Aead         *AeadConfig
 	Block        kcp.BlockCrypt
 	DataShards   int           // default 1024
 	ParityShards int           // default 3
@@ -93,7 +94,11 @@ func (k *KcpServer) Accept() {
 		conn.SetStreamMode(true)
 		conn.SetWriteDelay(false)
 		conn.SetACKNoDelay(false)
-		k.onClients <- NewKcpClientFromConn(conn, k.kcpCfg)
+		Go(func() {
+			acceptMux(conn, func(conn net.Conn) {
+				k.onClients <- NewKcpClientFromConn(conn, k.kcpCfg)
+			})
+		})
 	}
 }
 
@@ -108,6 +113,7 @@ func NewKcpClient(addr string, cfg *KcpConfig) *KcpClient {
 	if cfg == nil {
 		cfg = &defaultKcpConfig
 	}
+	session := NewAeadSession(cfg.Aead)
 	c := &KcpClient{
 		kcpCfg: cfg,
 		socketClient: socketClient{
@@ -118,7 +124,9 @@ func NewKcpClient(addr string, cfg *KcpConfig) *KcpClient {
 				minSize: 15,
 				message: &StreamMessage{
 					timeout: cfg.Timeout,
+					session: session,
 				},
+				session: session,
 			},
 			status: ClInit,
 		},
@@ -131,6 +139,7 @@ func NewKcpClientFromConn(conn net.Conn, cfg *KcpConfig) *KcpClient {
 	if cfg == nil {
 		cfg = &defaultKcpConfig
 	}
+	session := NewAeadSession(cfg.Aead)
 	c := &KcpClient{
 		socketClient: socketClient{
 			address: conn.RemoteAddr().String(),
@@ -140,7 +149,9 @@ func NewKcpClientFromConn(conn net.Conn, cfg *KcpConfig) *KcpClient {
 				minSize:    15,
 				message: &StreamMessage{
 					timeout: cfg.Timeout,
+					session: session,
 				},
+				session: session,
 			},
 			newTime: time.Now().Unix(),
 		},
@@ -149,22 +160,38 @@ func NewKcpClientFromConn(conn net.Conn, cfg *KcpConfig) *KcpClient {
 	return c
 }
 
-func (c *KcpClient) Connect() error {
-	if !c.retry() {
-		return nil
+// DialKcp returns connection of kcp in stream mode.
+func DialKcp(addr string, cfg *KcpConfig) (net.Conn, error) {
+	if cfg == nil {
+		cfg = &defaultKcpConfig
 	}
-	Info("KcpClient.Connect: kcp://%s", c.address)
+	Info("DialKcp: kcp://%s", addr)
 	conn, err := kcp.DialWithOptions(
-		c.address,
-		c.kcpCfg.Block,
-		c.kcpCfg.DataShards,
-		c.kcpCfg.DataShards)
+		addr,
+		cfg.Block,
+		cfg.DataShards,
+		cfg.DataShards)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	conn.SetStreamMode(true)
 	conn.SetWriteDelay(false)
 	conn.SetACKNoDelay(false)
+	return conn, nil
+}
+
+func (c *KcpClient) Connect() error {
+	if !c.retry() {
+		return nil
+	}
+	conn, err := DialKcp(c.address, c.kcpCfg)
+	if err != nil {
+		return err
+	}
+	if err := c.handshake(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
 	c.lock.Lock()
 	c.connection = conn
 	c.status = ClConnected
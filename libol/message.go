@@ -26,54 +26,140 @@ func isControl(data []byte) bool {
 	return false
 }
 
-type Ip4Proto struct {
+// FrameProto is protocols decoded from an ethernet frame, which exposes
+// layer 3 and 4 regardless of ipv4 or ipv6.
+type FrameProto struct {
 	// public
-	Eth  *Ether
-	Vlan *Vlan
-	Arp  *Arp
-	Ip4  *Ipv4
-	Udp  *Udp
-	Tcp  *Tcp
+	Eth   *Ether
+	Vlan  *Vlan
+	Arp   *Arp
+	Ip4   *Ipv4
+	Ip6   *Ipv6
+	Exts  []*Ipv6Ext
+	Icmp  *Icmp
+	Icmp6 *Icmp6
+	Ndp   *Ndp
+	Udp   *Udp
+	Tcp   *Tcp
 	// private
 	err   error
 	frame []byte
 }
 
-func (i *Ip4Proto) Decode() error {
+func NewFrameProto(frame []byte) *FrameProto {
+	return &FrameProto{frame: frame}
+}
+
+func (i *FrameProto) Decode() error {
 	data := i.frame
 	if i.Eth, i.err = NewEtherFromFrame(data); i.err != nil {
 		return i.err
 	}
 	data = data[i.Eth.Len:]
+	ethType := i.Eth.Type
 	if i.Eth.IsVlan() {
 		if i.Vlan, i.err = NewVlanFromFrame(data); i.err != nil {
 			return i.err
 		}
 		data = data[i.Vlan.Len:]
+		ethType = i.Vlan.Pro
 	}
-	if i.Eth.IsIP4() {
+	switch ethType {
+	case EthIp4:
 		if i.Ip4, i.err = NewIpv4FromFrame(data); i.err != nil {
 			return i.err
 		}
-		data = data[i.Ip4.Len:]
-		switch i.Ip4.Protocol {
-		case IpTcp:
-			if i.Tcp, i.err = NewTcpFromFrame(data); i.err != nil {
-				return i.err
-			}
-		case IpUdp:
-			if i.Udp, i.err = NewUdpFromFrame(data); i.err != nil {
-				return i.err
-			}
+		i.err = i.decodeL4(i.Ip4.Protocol, data[i.Ip4.Len:])
+	case EthIp6:
+		if i.Ip6, i.err = NewIpv6FromFrame(data); i.err != nil {
+			return i.err
 		}
-	} else if i.Eth.IsArp() {
-		if i.Arp, i.err = NewArpFromFrame(data); i.err != nil {
+		data = data[i.Ip6.Len:]
+		var next uint8
+		if i.Exts, next, i.err = Ipv6Walk(i.Ip6.NextHeader, data); i.err != nil {
 			return i.err
 		}
+		for _, e := range i.Exts {
+			data = data[e.Len:]
+		}
+		i.err = i.decodeL4(next, data)
+	case EthArp:
+		i.Arp, i.err = NewArpFromFrame(data)
+	}
+	return i.err
+}
+
+func (i *FrameProto) decodeL4(proto uint8, data []byte) (err error) {
+	switch proto {
+	case IpTcp:
+		i.Tcp, err = NewTcpFromFrame(data)
+	case IpUdp:
+		i.Udp, err = NewUdpFromFrame(data)
+	case IpIcmp:
+		i.Icmp, err = NewIcmpFromFrame(data)
+	case IpIcmp6:
+		if i.Icmp6, err = NewIcmp6FromFrame(data); err != nil {
+			return err
+		}
+		switch i.Icmp6.Type {
+		case Icmp6NeighborSolicit, Icmp6NeighborAdvert:
+			i.Ndp, err = NewNdpFromFrame(data[i.Icmp6.Len:])
+		}
+	}
+	return err
+}
+
+// Family returns ethernet type of layer 3, and 0 if not ip.
+func (i *FrameProto) Family() uint16 {
+	if i.Ip4 != nil {
+		return EthIp4
+	} else if i.Ip6 != nil {
+		return EthIp6
+	}
+	return 0
+}
+
+func (i *FrameProto) Source() net.IP {
+	if i.Ip4 != nil {
+		return i.Ip4.Source
+	} else if i.Ip6 != nil {
+		return i.Ip6.Source
 	}
 	return nil
 }
 
+func (i *FrameProto) Destination() net.IP {
+	if i.Ip4 != nil {
+		return i.Ip4.Destination
+	} else if i.Ip6 != nil {
+		return i.Ip6.Destination
+	}
+	return nil
+}
+
+// Protocol returns upper protocol of ip, after extension headers of ipv6.
+func (i *FrameProto) Protocol() uint8 {
+	if i.Ip4 != nil {
+		return i.Ip4.Protocol
+	} else if i.Ip6 != nil {
+		if n := len(i.Exts); n > 0 {
+			return i.Exts[n-1].NextHeader
+		}
+		return i.Ip6.NextHeader
+	}
+	return 0
+}
+
+// Ports returns source and destination port of tcp or udp.
+func (i *FrameProto) Ports() (uint16, uint16) {
+	if i.Tcp != nil {
+		return i.Tcp.Source, i.Tcp.Destination
+	} else if i.Udp != nil {
+		return i.Udp.Source, i.Udp.Destination
+	}
+	return 0, 0
+}
+
 type FrameMessage struct {
 	control bool
 	action  string
@@ -82,7 +168,7 @@ type FrameMessage struct {
 	size    int
 	total   int
 	frame   []byte
-	proto   *Ip4Proto
+	proto   *FrameProto
 }
 
 func NewFrameMessage() *FrameMessage {
@@ -143,11 +229,15 @@ func (m *FrameMessage) SetSize(v int) {
 	m.size = v
 }
 
-func (m *FrameMessage) Proto() (*Ip4Proto, error) {
+func (m *FrameMessage) Proto() (*FrameProto, error) {
 	if m.proto != nil {
 		return m.proto, m.proto.err
 	}
-	m.proto = &Ip4Proto{frame: m.frame}
+	frame := m.frame
+	if m.size > 0 {
+		frame = m.frame[:m.size]
+	}
+	m.proto = NewFrameProto(frame)
 	err := m.proto.Decode()
 	return m.proto, err
 }
@@ -185,9 +275,41 @@ type Messager interface {
 	Receive(conn net.Conn, max, min int) (*FrameMessage, error)
 }
 
+// aeadSeal returns the message with header and frame sealed by session.
+func aeadSeal(session *AeadSession, frame *FrameMessage) ([]byte, error) {
+	size := AeadHlSize + frame.size + AeadTagLen
+	header := make([]byte, HlSize, HlSize+size)
+	copy(header[:2], MAGIC[:2])
+	binary.BigEndian.PutUint16(header[2:4], uint16(size))
+	data, err := session.Seal(header, frame.frame[:frame.size])
+	if err != nil {
+		return nil, err
+	}
+	return append(header, data...), nil
+}
+
+// aeadOpen decrypts data into frame.
+func aeadOpen(session *AeadSession, frame *FrameMessage, header, data []byte) error {
+	plain, err := session.Open(header, data)
+	if err != nil {
+		return err
+	}
+	frame.size = copy(frame.buffer[HlSize:], plain)
+	frame.frame = frame.buffer[HlSize : HlSize+frame.size]
+	return nil
+}
+
+func aeadOverhead(session *AeadSession) int {
+	if session.Ready() {
+		return AeadHlSize + AeadTagLen
+	}
+	return 0
+}
+
 type StreamMessage struct {
 	timeout time.Duration // ns for read and write deadline.
 	block   kcp.BlockCrypt
+	session *AeadSession
 }
 
 func (s *StreamMessage) write(conn net.Conn, tmp []byte) (int, error) {
@@ -240,7 +362,14 @@ func (s *StreamMessage) Send(conn net.Conn, frame *FrameMessage) (int, error) {
 	if s.block != nil {
 		s.block.Encrypt(frame.frame, frame.frame)
 	}
-	if err := s.writeFull(conn, frame.buffer[:frame.size+4]); err != nil {
+	data := frame.buffer[:frame.size+4]
+	if s.session.Ready() {
+		var err error
+		if data, err = aeadSeal(s.session, frame); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.writeFull(conn, data); err != nil {
 		return 0, err
 	}
 	return frame.size, nil
@@ -295,24 +424,31 @@ func (s *StreamMessage) Receive(conn net.Conn, max, min int) (*FrameMessage, err
 		return nil, NewErr("%s: wrong magic", conn.RemoteAddr())
 	}
 	size := int(binary.BigEndian.Uint16(h[2:4]))
-	if size > max || size < min {
+	over := aeadOverhead(s.session)
+	if size > max+over || size < min+over {
 		return nil, NewErr("%s: wrong size %d", conn.RemoteAddr(), size)
 	}
 	tmp := frame.buffer[4 : 4+size]
 	if err := s.readFull(conn, tmp); err != nil {
 		return nil, err
 	}
-	if s.block != nil {
-		s.block.Decrypt(tmp, tmp)
-	}
 	frame.size = size
 	frame.frame = tmp
+	if over > 0 {
+		if err := aeadOpen(s.session, frame, h, tmp); err != nil {
+			return nil, err
+		}
+	}
+	if s.block != nil {
+		s.block.Decrypt(frame.frame, frame.frame)
+	}
 	return frame, nil
 }
 
 type DataGramMessage struct {
 	timeout time.Duration // ns for read and write deadline
 	block   kcp.BlockCrypt
+	session *AeadSession
 }
 
 func (s *DataGramMessage) Send(conn net.Conn, frame *FrameMessage) (int, error) {
@@ -331,7 +467,14 @@ func (s *DataGramMessage) Send(conn net.Conn, frame *FrameMessage) (int, error)
 			return 0, err
 		}
 	}
-	if _, err := conn.Write(frame.buffer[:4+frame.size]); err != nil {
+	data := frame.buffer[:4+frame.size]
+	if s.session.Ready() {
+		var err error
+		if data, err = aeadSeal(s.session, frame); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := conn.Write(data); err != nil {
 		return 0, err
 	}
 	return frame.size, nil
@@ -362,14 +505,22 @@ func (s *DataGramMessage) Receive(conn net.Conn, max, min int) (*FrameMessage, e
 		return nil, NewErr("%s: wrong magic", conn.RemoteAddr())
 	}
 	size := int(binary.BigEndian.Uint16(frame.buffer[2:4]))
-	if size > max || size < min {
+	over := aeadOverhead(s.session)
+	if size > max+over || size < min+over || size > n-4 {
 		return nil, NewErr("%s: wrong size %d", conn.RemoteAddr(), size)
 	}
 	tmp := frame.buffer[4 : 4+size]
-	if s.block != nil {
-		s.block.Decrypt(tmp, tmp)
-	}
 	frame.size = size
 	frame.frame = tmp
+	if over > 0 {
+		header := make([]byte, HlSize)
+		copy(header, frame.buffer[:HlSize])
+		if err := aeadOpen(s.session, frame, header, tmp); err != nil {
+			return nil, err
+		}
+	}
+	if s.block != nil {
+		s.block.Decrypt(frame.frame, frame.frame)
+	}
 	return frame, nil
 }
@@ -0,0 +1,100 @@
+package libol
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	MetricCounter = "counter"
+	MetricGauge   = "gauge"
+)
+
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	samples []string
+}
+
+// Metrics writes samples in text exposition format of prometheus, and
+// groups samples by name.
+type Metrics struct {
+	names    map[string]*metricFamily
+	families []*metricFamily
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		names:    make(map[string]*metricFamily, 32),
+		families: make([]*metricFamily, 0, 32),
+	}
+}
+
+var metricEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Add adds a sample with labels given in pairs of name and value.
+func (m *Metrics) Add(name, help, typ string, value float64, labels ...string) {
+	f, ok := m.names[name]
+	if !ok {
+		f = &metricFamily{name: name, help: help, typ: typ}
+		m.names[name] = f
+		m.families = append(m.families, f)
+	}
+	sample := name
+	if len(labels) > 1 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+"=\""+metricEscape.Replace(labels[i+1])+"\"")
+		}
+		sample += "{" + strings.Join(pairs, ",") + "}"
+	}
+	sample += " " + strconv.FormatFloat(value, 'g', -1, 64)
+	f.samples = append(f.samples, sample)
+}
+
+func (m *Metrics) Counter(name, help string, value uint64, labels ...string) {
+	m.Add(name, help, MetricCounter, float64(value), labels...)
+}
+
+func (m *Metrics) Gauge(name, help string, value float64, labels ...string) {
+	m.Add(name, help, MetricGauge, value, labels...)
+}
+
+// AddClient adds statistics of the socket client.
+func (m *Metrics) AddClient(prefix string, sts ClientSts, labels ...string) {
+	m.Counter(prefix+"_recv_bytes_total", "Bytes received.", sts.RecvOkay, labels...)
+	m.Counter(prefix+"_send_bytes_total", "Bytes sent.", sts.SendOkay, labels...)
+	m.Counter(prefix+"_recv_frames_total", "Frames received.", sts.RecvFrames, labels...)
+	m.Counter(prefix+"_send_frames_total", "Frames sent.", sts.SendFrames, labels...)
+	m.Counter(prefix+"_dropped_total", "Frames dropped for not connected.", sts.Dropped, labels...)
+	m.Counter(prefix+"_send_errors_total", "Frames failed to send.", sts.SendError, labels...)
+	m.Counter(prefix+"_auth_errors_total", "Frames failed to authenticate.", sts.AuthError, labels...)
+}
+
+func (m *Metrics) AddGoroutines() {
+	m.Gauge("openlan_goroutines", "Goroutines in runtime.", float64(runtime.NumGoroutine()))
+	m.Gauge("openlan_goroutines_tracked", "Goroutines started by libol.Go.", float64(Gos.Total()))
+}
+
+func (m *Metrics) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, f := range m.families {
+		if f.help != "" {
+			_, _ = fmt.Fprintf(&buf, "# HELP %s %s\n", f.name, f.help)
+		}
+		_, _ = fmt.Fprintf(&buf, "# TYPE %s %s\n", f.name, f.typ)
+		for _, s := range f.samples {
+			buf.WriteString(s)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func (m *Metrics) String() string {
+	return string(m.Bytes())
+}
@@ -0,0 +1,30 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	m.Counter("openlan_recv_total", "Bytes received.", 10, "network", "a")
+	m.Gauge("openlan_up", "Up.", 1)
+	m.Counter("openlan_recv_total", "Bytes received.", 20, "network", "b\"\\\n")
+
+	expected := "# HELP openlan_recv_total Bytes received.\n" +
+		"# TYPE openlan_recv_total counter\n" +
+		"openlan_recv_total{network=\"a\"} 10\n" +
+		"openlan_recv_total{network=\"b\\\"\\\\\\n\"} 20\n" +
+		"# HELP openlan_up Up.\n" +
+		"# TYPE openlan_up gauge\n" +
+		"openlan_up 1\n"
+	assert.Equal(t, expected, m.String(), "be the same.")
+
+	m = NewMetrics()
+	m.AddClient("openlan_point", ClientSts{RecvOkay: 1500, RecvFrames: 1, Dropped: 2}, "uuid", "x")
+	s := m.String()
+	assert.True(t, strings.Contains(s, "openlan_point_recv_bytes_total{uuid=\"x\"} 1500\n"), "bytes.")
+	assert.True(t, strings.Contains(s, "openlan_point_recv_frames_total{uuid=\"x\"} 1\n"), "frames.")
+	assert.True(t, strings.Contains(s, "openlan_point_dropped_total{uuid=\"x\"} 2\n"), "dropped.")
+}
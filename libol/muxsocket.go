@@ -0,0 +1,292 @@
+package libol
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/xtaci/kcp-go/v5"
+	"github.com/xtaci/smux"
+	"net"
+	"sync"
+	"time"
+)
+
+// muxAddr is address of stream, and connection is shared by streams.
+type muxAddr struct {
+	network string
+	address string
+}
+
+func (a *muxAddr) Network() string {
+	return a.network
+}
+
+func (a *muxAddr) String() string {
+	return a.address
+}
+
+// muxConn is a stream of multiplexed connection, and remote address has
+// stream id to identify clients over the same connection.
+type muxConn struct {
+	*smux.Stream
+	conn   net.Conn
+	remote net.Addr
+}
+
+func newMuxConn(stream *smux.Stream, conn net.Conn) *muxConn {
+	return &muxConn{
+		Stream: stream,
+		conn:   conn,
+		remote: &muxAddr{
+			network: conn.RemoteAddr().Network(),
+			address: fmt.Sprintf("%s/%d", conn.RemoteAddr(), stream.ID()),
+		},
+	}
+}
+
+func (m *muxConn) RemoteAddr() net.Addr {
+	return m.remote
+}
+
+// ConnectionState returns state of tls shared by streams.
+func (m *muxConn) ConnectionState() tls.ConnectionState {
+	if conn, ok := m.conn.(interface {
+		ConnectionState() tls.ConnectionState
+	}); ok {
+		return conn.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
+
+// peekConn returns the first byte read again.
+type peekConn struct {
+	net.Conn
+	first []byte
+}
+
+func (p *peekConn) Read(b []byte) (int, error) {
+	if len(p.first) > 0 {
+		n := copy(b, p.first)
+		p.first = p.first[n:]
+		return n, nil
+	}
+	return p.Conn.Read(b)
+}
+
+func (p *peekConn) ConnectionState() tls.ConnectionState {
+	if conn, ok := p.Conn.(interface {
+		ConnectionState() tls.ConnectionState
+	}); ok {
+		return conn.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
+
+func muxConfig() *smux.Config {
+	cfg := smux.DefaultConfig()
+	cfg.KeepAliveInterval = 10 * time.Second
+	cfg.KeepAliveTimeout = 30 * time.Second
+	return cfg
+}
+
+// acceptMux calls onConn with the connection, or each stream if it is
+// multiplexed. Frames start with magic, and multiplexed not.
+func acceptMux(conn net.Conn, onConn func(conn net.Conn)) {
+	first := make([]byte, 1)
+	if _, err := conn.Read(first); err != nil {
+		Warn("acceptMux: %s %s", conn.RemoteAddr(), err)
+		_ = conn.Close()
+		return
+	}
+	pConn := &peekConn{Conn: conn, first: first}
+	if first[0] == MAGIC[0] {
+		onConn(pConn)
+		return
+	}
+	session, err := smux.Server(pConn, muxConfig())
+	if err != nil {
+		Warn("acceptMux: %s %s", conn.RemoteAddr(), err)
+		_ = conn.Close()
+		return
+	}
+	Info("acceptMux: %s multiplexed", conn.RemoteAddr())
+	defer session.Close()
+	for {
+		stream, err := session.AcceptStream()
+		if err != nil {
+			Info("acceptMux: %s %s", conn.RemoteAddr(), err)
+			return
+		}
+		onConn(newMuxConn(stream, pConn))
+	}
+}
+
+// MuxSession shares one connection between clients, and each client
+// opens its own stream. The connection is dialed again if closed.
+type MuxSession struct {
+	lock    sync.Mutex
+	address string
+	dialer  func() (net.Conn, error)
+	session *smux.Session
+}
+
+func NewMuxSession(addr string, dialer func() (net.Conn, error)) *MuxSession {
+	return &MuxSession{
+		address: addr,
+		dialer:  dialer,
+	}
+}
+
+func (m *MuxSession) String() string {
+	return m.address
+}
+
+func (m *MuxSession) Open() (net.Conn, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.session == nil || m.session.IsClosed() {
+		conn, err := m.dialer()
+		if err != nil {
+			return nil, err
+		}
+		session, err := smux.Client(conn, muxConfig())
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		Info("MuxSession.Open: %s", m.address)
+		m.session = session
+	}
+	stream, err := m.session.OpenStream()
+	if err != nil {
+		_ = m.session.Close()
+		m.session = nil
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (m *MuxSession) Close() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.session != nil {
+		Info("MuxSession.Close: %s", m.address)
+		_ = m.session.Close()
+		m.session = nil
+	}
+}
+
+// NewMuxDialer returns dialer by protocol, and only protocols of stream
+// can be multiplexed.
+func NewMuxDialer(protocol, addr string, tcpCfg *TcpConfig, block kcp.BlockCrypt) func() (net.Conn, error) {
+	switch protocol {
+	case "kcp":
+		return func() (net.Conn, error) {
+			return DialKcp(addr, &KcpConfig{
+				Block:        block,
+				DataShards:   defaultKcpConfig.DataShards,
+				ParityShards: defaultKcpConfig.ParityShards,
+			})
+		}
+	case "tcp", "tls":
+		return func() (net.Conn, error) {
+			return DialTcp(addr, tcpCfg)
+		}
+	}
+	return nil
+}
+
+type MuxConfig struct {
+	Aead    *AeadConfig
+	Block   kcp.BlockCrypt
+	Timeout time.Duration // ns
+}
+
+// Client Implement
+
+type MuxClient struct {
+	socketClient
+	mux *MuxSession
+}
+
+func NewMuxClient(mux *MuxSession, cfg *MuxConfig) *MuxClient {
+	session := NewAeadSession(cfg.Aead)
+	c := &MuxClient{
+		mux: mux,
+		socketClient: socketClient{
+			address: mux.address,
+			newTime: time.Now().Unix(),
+			dataStream: dataStream{
+				maxSize: 1514,
+				minSize: 15,
+				message: &StreamMessage{
+					timeout: cfg.Timeout,
+					block:   cfg.Block,
+					session: session,
+				},
+				session: session,
+			},
+			status: ClInit,
+		},
+	}
+	c.connecter = c.Connect
+	return c
+}
+
+func (c *MuxClient) Connect() error {
+	if !c.retry() {
+		return nil
+	}
+	conn, err := c.mux.Open()
+	if err != nil {
+		return err
+	}
+	if err := c.handshake(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	c.lock.Lock()
+	c.connection = conn
+	c.status = ClConnected
+	c.lock.Unlock()
+	if c.listener.OnConnected != nil {
+		_ = c.listener.OnConnected(c)
+	}
+	return nil
+}
+
+func (c *MuxClient) Close() {
+	c.lock.Lock()
+	if c.connection != nil {
+		if c.status != ClTerminal {
+			c.status = ClClosed
+		}
+		Info("MuxClient.Close: %s", c.address)
+		_ = c.connection.Close()
+		c.connection = nil
+		c.private = nil
+		c.lock.Unlock()
+		if c.listener.OnClose != nil {
+			_ = c.listener.OnClose(c)
+		}
+	} else {
+		c.lock.Unlock()
+	}
+}
+
+func (c *MuxClient) Terminal() {
+	c.SetStatus(ClTerminal)
+	c.Close()
+}
+
+func (c *MuxClient) SetStatus(v uint8) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.status != v {
+		if c.listener.OnStatus != nil {
+			c.listener.OnStatus(c, c.status, v)
+		}
+		c.status = v
+	}
+}
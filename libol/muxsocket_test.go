@@ -0,0 +1,85 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMuxClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen.")
+	defer listener.Close()
+	conns := make(chan net.Conn, 8)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go acceptMux(conn, func(conn net.Conn) {
+				conns <- conn
+			})
+		}
+	}()
+	accept := func() net.Conn {
+		select {
+		case conn := <-conns:
+			return conn
+		case <-time.After(2 * time.Second):
+			return nil
+		}
+	}
+
+	addr := listener.Addr().String()
+	mux := NewMuxSession(addr, func() (net.Conn, error) {
+		return net.Dial("tcp", addr)
+	})
+	defer mux.Close()
+	clients := make([]*MuxClient, 0, 2)
+	servers := make([]*TcpClient, 0, 2)
+	for i := 0; i < 2; i++ {
+		c := NewMuxClient(mux, &MuxConfig{})
+		assert.Nil(t, c.Connect(), "connect.")
+		assert.Nil(t, c.WriteReq("ping", "hello"), "write.")
+		conn := accept()
+		assert.NotNil(t, conn, "accepted.")
+		clients = append(clients, c)
+		servers = append(servers, NewTcpClientFromConn(conn, &TcpConfig{}))
+	}
+	assert.NotEqual(t, servers[0].RemoteAddr(), servers[1].RemoteAddr(), "different streams.")
+	for i, s := range servers {
+		frame, err := s.ReadMsg()
+		assert.Nil(t, err, "read.")
+		frame.Decode()
+		action, params := frame.CmdAndParams()
+		assert.Equal(t, "ping=", action, "be the same.")
+		assert.Equal(t, "hello", params, "be the same.")
+		assert.Nil(t, s.WriteResp("pong", "okay"), "write back.")
+		frame, err = clients[i].ReadMsg()
+		assert.Nil(t, err, "read back.")
+		frame.Decode()
+		action, _ = frame.CmdAndParams()
+		assert.Equal(t, "pong:", action, "be the same.")
+	}
+
+	// not multiplexed.
+	c := NewTcpClient(addr, &TcpConfig{})
+	assert.Nil(t, c.Connect(), "connect.")
+	defer c.Close()
+	assert.Nil(t, c.WriteReq("ping", "hello"), "write.")
+	conn := accept()
+	assert.NotNil(t, conn, "accepted.")
+	frame, err := NewTcpClientFromConn(conn, &TcpConfig{}).ReadMsg()
+	assert.Nil(t, err, "read.")
+	frame.Decode()
+	action, _ := frame.CmdAndParams()
+	assert.Equal(t, "ping=", action, "be the same.")
+
+	// closing one stream keeps others.
+	clients[0].Close()
+	assert.Nil(t, clients[1].WriteReq("ping", "again"), "write.")
+	frame, err = servers[1].ReadMsg()
+	assert.Nil(t, err, "read.")
+}
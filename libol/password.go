@@ -0,0 +1,36 @@
+package libol
+
+import (
+	"crypto/hmac"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	PassCost = bcrypt.DefaultCost
+)
+
+// HashPassword returns password hashed by bcrypt with random salt.
+func HashPassword(password string) string {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), PassCost)
+	if err != nil {
+		Error("HashPassword: %s", err)
+		return ""
+	}
+	return string(hashed)
+}
+
+func IsHashed(password string) bool {
+	return strings.HasPrefix(password, "$2a$") ||
+		strings.HasPrefix(password, "$2b$") ||
+		strings.HasPrefix(password, "$2y$")
+}
+
+// CheckPassword compares password with the hashed, and plaintext stored
+// by older versions is also compared.
+func CheckPassword(hashed, password string) bool {
+	if IsHashed(hashed) {
+		return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+	}
+	return hmac.Equal([]byte(hashed), []byte(password))
+}
@@ -30,7 +30,10 @@ const (
 	VlanLen  = 4
 	TcpLen   = 20
 	Ipv4Len  = 20
+	Ipv6Len  = 40
 	UdpLen   = 8
+	IcmpLen  = 8 // type, code, checksum and rest of header.
+	Icmp6Len = 4 // type, code and checksum.
 )
 
 func NewEther(t uint16) (e *Ether) {
@@ -51,6 +54,10 @@ func NewEtherIP4() (e *Ether) {
 	return NewEther(EthIp4)
 }
 
+func NewEtherIP6() (e *Ether) {
+	return NewEther(EthIp6)
+}
+
 func NewEtherFromFrame(frame []byte) (e *Ether, err error) {
 	e = NewEther(0)
 	err = e.Decode(frame)
@@ -92,6 +99,10 @@ func (e *Ether) IsIP4() bool {
 	return e.Type == EthIp4
 }
 
+func (e *Ether) IsIP6() bool {
+	return e.Type == EthIp6
+}
+
 type Vlan struct {
 	Tci uint16
 	Vid uint16
@@ -257,17 +268,24 @@ const (
 )
 
 const (
-	IpIcmp = 0x01
-	IpIgmp = 0x02
-	IpIpIp = 0x04
-	IpTcp  = 0x06
-	IpUdp  = 0x11
-	IpEsp  = 0x32
-	IpAh   = 0x33
-	IpOspf = 0x59
-	IpPim  = 0x67
-	IpVrrp = 0x70
-	IpIsis = 0x7c
+	IpHopByHop = 0x00
+	IpIcmp     = 0x01
+	IpIgmp     = 0x02
+	IpIpIp     = 0x04
+	IpTcp      = 0x06
+	IpUdp      = 0x11
+	IpIpv6     = 0x29
+	IpRouting  = 0x2b
+	IpFragment = 0x2c
+	IpEsp      = 0x32
+	IpAh       = 0x33
+	IpIcmp6    = 0x3a
+	IpNoNext   = 0x3b
+	IpDstOpts  = 0x3c
+	IpOspf     = 0x59
+	IpPim      = 0x67
+	IpVrrp     = 0x70
+	IpIsis     = 0x7c
 )
 
 func IpProto2Str(proto uint8) string {
@@ -294,6 +312,10 @@ func IpProto2Str(proto uint8) string {
 		return "pim"
 	case IpVrrp:
 		return "vrrp"
+	case IpIcmp6:
+		return "icmpv6"
+	case IpIpv6:
+		return "ipv6"
 	default:
 		return fmt.Sprintf("%02x", proto)
 	}
@@ -523,3 +545,387 @@ func (u *Udp) Encode() []byte {
 
 	return buffer[:u.Len]
 }
+
+type Ipv6 struct {
+	Version      uint8 //4bit v6: 0110
+	TrafficClass uint8
+	FlowLabel    uint32 //20bit
+	PayloadLen   uint16
+	NextHeader   uint8
+	HopLimit     uint8
+	Source       []byte
+	Destination  []byte
+	Len          int
+}
+
+func NewIpv6() (i *Ipv6) {
+	i = &Ipv6{
+		Version:     Ipv6Ver,
+		HopLimit:    0xff,
+		Len:         Ipv6Len,
+		Source:      make([]byte, 16),
+		Destination: make([]byte, 16),
+	}
+	return
+}
+
+func NewIpv6FromFrame(frame []byte) (i *Ipv6, err error) {
+	i = NewIpv6()
+	err = i.Decode(frame)
+	return
+}
+
+func (i *Ipv6) Decode(frame []byte) error {
+	if len(frame) < Ipv6Len {
+		return NewErr("Ipv6.Decode: too small header: %d", len(frame))
+	}
+
+	h := binary.BigEndian.Uint32(frame[0:4])
+	i.Version = uint8(h >> 28)
+	i.TrafficClass = uint8(h >> 20)
+	i.FlowLabel = h & 0x000fffff
+	if !i.IsIP6() {
+		return NewErr("Ipv6.Decode: not right ipv6 version: 0x%x", i.Version)
+	}
+	i.PayloadLen = binary.BigEndian.Uint16(frame[4:6])
+	i.NextHeader = frame[6]
+	i.HopLimit = frame[7]
+	copy(i.Source[:16], frame[8:24])
+	copy(i.Destination[:16], frame[24:40])
+
+	return nil
+}
+
+func (i *Ipv6) Encode() []byte {
+	buffer := make([]byte, Ipv6Len)
+
+	h := uint32(i.Version)<<28 | uint32(i.TrafficClass)<<20 | i.FlowLabel&0x000fffff
+	binary.BigEndian.PutUint32(buffer[0:4], h)
+	binary.BigEndian.PutUint16(buffer[4:6], i.PayloadLen)
+	buffer[6] = i.NextHeader
+	buffer[7] = i.HopLimit
+	copy(buffer[8:24], i.Source[:16])
+	copy(buffer[24:40], i.Destination[:16])
+
+	return buffer
+}
+
+func (i *Ipv6) IsIP6() bool {
+	return i.Version == Ipv6Ver
+}
+
+// Ip6LinkLocal returns link-local address by modified EUI-64 of hardware.
+func Ip6LinkLocal(hwAddr []byte) []byte {
+	addr := make([]byte, 16)
+	addr[0], addr[1] = 0xfe, 0x80
+	copy(addr[8:11], hwAddr[0:3])
+	addr[8] ^= 0x02
+	addr[11], addr[12] = 0xff, 0xfe
+	copy(addr[13:16], hwAddr[3:6])
+	return addr
+}
+
+// Ip6SolicitedNode returns solicited-node multicast address of the target.
+func Ip6SolicitedNode(target []byte) []byte {
+	addr := []byte{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, 0, 0, 0}
+	copy(addr[13:16], target[13:16])
+	return addr
+}
+
+// Ip6MulticastHw returns ethernet address mapped from multicast address.
+func Ip6MulticastHw(addr []byte) []byte {
+	hwAddr := []byte{0x33, 0x33, 0, 0, 0, 0}
+	copy(hwAddr[2:6], addr[12:16])
+	return hwAddr
+}
+
+// IsIpv6Ext returns true if the next header is an extension header, which
+// can be walked through.
+func IsIpv6Ext(proto uint8) bool {
+	switch proto {
+	case IpHopByHop, IpRouting, IpFragment, IpDstOpts, IpAh:
+		return true
+	}
+	return false
+}
+
+// Ipv6Ext is the extension header of ipv6.
+type Ipv6Ext struct {
+	Type       uint8
+	NextHeader uint8
+	Len        int
+}
+
+func NewIpv6ExtFromFrame(t uint8, frame []byte) (e *Ipv6Ext, err error) {
+	e = &Ipv6Ext{Type: t}
+	err = e.Decode(frame)
+	return
+}
+
+func (e *Ipv6Ext) Decode(frame []byte) error {
+	if len(frame) < 8 {
+		return NewErr("Ipv6Ext.Decode: too small header: %d", len(frame))
+	}
+
+	e.NextHeader = frame[0]
+	switch e.Type {
+	case IpFragment:
+		e.Len = 8
+	case IpAh: // in 4-octet units, not including the first 8 octets.
+		e.Len = (int(frame[1]) + 2) * 4
+	default: // in 8-octet units, not including the first 8 octets.
+		e.Len = (int(frame[1]) + 1) * 8
+	}
+	if len(frame) < e.Len {
+		return NewErr("Ipv6Ext.Decode: too small frame: %d", len(frame))
+	}
+
+	return nil
+}
+
+// Ipv6Walk walks through extension headers from the next header, and
+// returns them and the upper protocol.
+func Ipv6Walk(next uint8, frame []byte) ([]*Ipv6Ext, uint8, error) {
+	exts := make([]*Ipv6Ext, 0, 4)
+	for IsIpv6Ext(next) {
+		e, err := NewIpv6ExtFromFrame(next, frame)
+		if err != nil {
+			return exts, next, err
+		}
+		exts = append(exts, e)
+		frame = frame[e.Len:]
+		next = e.NextHeader
+	}
+	return exts, next, nil
+}
+
+// Ipv4Checksum returns the checksum of upper protocol with pseudo header.
+func Ipv4Checksum(src, dst []byte, proto uint8, payload []byte) uint16 {
+	pseudo := make([]byte, 12, 12+len(payload))
+	copy(pseudo[0:4], src[:4])
+	copy(pseudo[4:8], dst[:4])
+	pseudo[9] = proto
+	binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(payload)))
+	return IpChecksum(append(pseudo, payload...))
+}
+
+// Ipv6Checksum returns the checksum of upper protocol with pseudo header.
+func Ipv6Checksum(src, dst []byte, proto uint8, payload []byte) uint16 {
+	pseudo := make([]byte, 40, 40+len(payload))
+	copy(pseudo[0:16], src[:16])
+	copy(pseudo[16:32], dst[:16])
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(payload)))
+	pseudo[39] = proto
+	return IpChecksum(append(pseudo, payload...))
+}
+
+const (
+	IcmpEchoReply    = 0
+	IcmpUnreachable  = 3
+	IcmpEchoRequest  = 8
+	IcmpTimeExceeded = 11
+)
+
+type Icmp struct {
+	Type     uint8
+	Code     uint8
+	Checksum uint16
+	Rest     uint32 // identifier and sequence for echo.
+	Len      int
+}
+
+func NewIcmp() (i *Icmp) {
+	i = &Icmp{
+		Len: IcmpLen,
+	}
+	return
+}
+
+func NewIcmpFromFrame(frame []byte) (i *Icmp, err error) {
+	i = NewIcmp()
+	err = i.Decode(frame)
+	return
+}
+
+func (i *Icmp) Decode(frame []byte) error {
+	if len(frame) < IcmpLen {
+		return NewErr("Icmp.Decode: too small header: %d", len(frame))
+	}
+
+	i.Type = frame[0]
+	i.Code = frame[1]
+	i.Checksum = binary.BigEndian.Uint16(frame[2:4])
+	i.Rest = binary.BigEndian.Uint32(frame[4:8])
+
+	return nil
+}
+
+func (i *Icmp) Encode() []byte {
+	buffer := make([]byte, IcmpLen)
+
+	buffer[0] = i.Type
+	buffer[1] = i.Code
+	binary.BigEndian.PutUint16(buffer[2:4], i.Checksum)
+	binary.BigEndian.PutUint32(buffer[4:8], i.Rest)
+
+	return buffer
+}
+
+const (
+	Icmp6Unreachable     = 1
+	Icmp6TooBig          = 2
+	Icmp6TimeExceeded    = 3
+	Icmp6EchoRequest     = 128
+	Icmp6EchoReply       = 129
+	Icmp6RouterSolicit   = 133
+	Icmp6RouterAdvert    = 134
+	Icmp6NeighborSolicit = 135
+	Icmp6NeighborAdvert  = 136
+	Icmp6Redirect        = 137
+)
+
+type Icmp6 struct {
+	Type     uint8
+	Code     uint8
+	Checksum uint16
+	Len      int
+}
+
+func NewIcmp6() (i *Icmp6) {
+	i = &Icmp6{
+		Len: Icmp6Len,
+	}
+	return
+}
+
+func NewIcmp6FromFrame(frame []byte) (i *Icmp6, err error) {
+	i = NewIcmp6()
+	err = i.Decode(frame)
+	return
+}
+
+func (i *Icmp6) Decode(frame []byte) error {
+	if len(frame) < Icmp6Len {
+		return NewErr("Icmp6.Decode: too small header: %d", len(frame))
+	}
+
+	i.Type = frame[0]
+	i.Code = frame[1]
+	i.Checksum = binary.BigEndian.Uint16(frame[2:4])
+
+	return nil
+}
+
+func (i *Icmp6) Encode() []byte {
+	buffer := make([]byte, Icmp6Len)
+
+	buffer[0] = i.Type
+	buffer[1] = i.Code
+	binary.BigEndian.PutUint16(buffer[2:4], i.Checksum)
+
+	return buffer
+}
+
+func (i *Icmp6) IsNdp() bool {
+	return i.Type >= Icmp6RouterSolicit && i.Type <= Icmp6Redirect
+}
+
+const (
+	NdpOptSrcLink = 1
+	NdpOptTgtLink = 2
+	NdpOptPrefix  = 3
+	NdpOptMtu     = 5
+)
+
+const (
+	NdpRouter    = 0x80000000
+	NdpSolicited = 0x40000000
+	NdpOverride  = 0x20000000
+)
+
+type NdpOption struct {
+	Type  uint8
+	Value []byte
+}
+
+// Ndp is the neighbor solicitation or advertisement after icmpv6 header.
+type Ndp struct {
+	Flags   uint32 // router, solicited and override for advertisement.
+	Target  []byte
+	Options []NdpOption
+	Len     int
+}
+
+func NewNdp() (n *Ndp) {
+	n = &Ndp{
+		Target:  make([]byte, 16),
+		Options: make([]NdpOption, 0, 2),
+	}
+	return
+}
+
+func NewNdpFromFrame(frame []byte) (n *Ndp, err error) {
+	n = NewNdp()
+	err = n.Decode(frame)
+	return
+}
+
+func (n *Ndp) Decode(frame []byte) error {
+	if len(frame) < 20 {
+		return NewErr("Ndp.Decode: too small header: %d", len(frame))
+	}
+
+	n.Flags = binary.BigEndian.Uint32(frame[0:4])
+	copy(n.Target[:16], frame[4:20])
+	n.Options = n.Options[:0]
+	p := 20
+	for p+2 <= len(frame) {
+		size := int(frame[p+1]) * 8
+		if size == 0 || p+size > len(frame) {
+			return NewErr("Ndp.Decode: option %d too long: %d", frame[p], size)
+		}
+		value := make([]byte, size-2)
+		copy(value, frame[p+2:p+size])
+		n.Options = append(n.Options, NdpOption{Type: frame[p], Value: value})
+		p += size
+	}
+	n.Len = p
+
+	return nil
+}
+
+func (n *Ndp) Encode() []byte {
+	size := 20
+	for _, opt := range n.Options {
+		size += (len(opt.Value) + 2 + 7) / 8 * 8
+	}
+	buffer := make([]byte, size)
+
+	binary.BigEndian.PutUint32(buffer[0:4], n.Flags)
+	copy(buffer[4:20], n.Target[:16])
+	p := 20
+	for _, opt := range n.Options {
+		length := (len(opt.Value) + 2 + 7) / 8
+		buffer[p] = opt.Type
+		buffer[p+1] = uint8(length)
+		copy(buffer[p+2:], opt.Value)
+		p += length * 8
+	}
+	n.Len = size
+
+	return buffer
+}
+
+// LinkAddr returns the link-layer address in source or target option.
+func (n *Ndp) LinkAddr(t uint8) []byte {
+	for _, opt := range n.Options {
+		if opt.Type == t && len(opt.Value) >= 6 {
+			return opt.Value[:6]
+		}
+	}
+	return nil
+}
+
+func (n *Ndp) HasFlag(flag uint32) bool {
+	return n.Flags&flag == flag
+}
@@ -0,0 +1,145 @@
+package libol
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// frames captured from the wire, and checksums of them are valid.
+var protoFrames = []struct {
+	name   string
+	frame  string
+	family uint16
+	src    string
+	dst    string
+	proto  uint8
+	sport  uint16
+	dport  uint16
+}{
+	{
+		name:  "arp",
+		frame: "ffffffffffff0a0000000001080600010800060400010a0000000001c0a80101000000000000c0a80102",
+	},
+	{
+		name:   "tcp4",
+		frame:  "0a00000000020a0000000001080045000028000100004006f77bc0a80101c0a801029c40005000000001000000005002ffff00000000",
+		family: EthIp4, src: "192.168.1.1", dst: "192.168.1.2", proto: IpTcp, sport: 40000, dport: 80,
+	},
+	{
+		name:   "udp4",
+		frame:  "0a00000000020a0000000001080045000020000100004011f778c0a80101c0a8010214e90035000c000061626364",
+		family: EthIp4, src: "192.168.1.1", dst: "192.168.1.2", proto: IpUdp, sport: 5353, dport: 53,
+	},
+	{
+		name:   "icmp4",
+		frame:  "0a00000000020a0000000001080045000020000100004001f788c0a80101c0a80102080006fa1234000170696e67",
+		family: EthIp4, src: "192.168.1.1", dst: "192.168.1.2", proto: IpIcmp,
+	},
+	{
+		name:   "vlan4",
+		frame:  "0a00000000020a000000000181000064080045000020000100004011f778c0a80101c0a8010214e90035000c000061626364",
+		family: EthIp4, src: "192.168.1.1", dst: "192.168.1.2", proto: IpUdp, sport: 5353, dport: 53,
+	},
+	{
+		name: "ns6",
+		frame: "3333ff0000020a000000000186dd6000000000203afffd000000000000000000000000000001ff0200000000000000000001ff000002" +
+			"8700759700000000fd00000000000000000000000000000201010a0000000001",
+		family: EthIp6, src: "fd00::1", dst: "ff02::1:ff00:2", proto: IpIcmp6,
+	},
+	{
+		name: "na6",
+		frame: "0a00000000010a000000000286dd6000000000203afffd000000000000000000000000000002fd000000000000000000000000000001" +
+			"8800149a60000000fd00000000000000000000000000000202010a0000000002",
+		family: EthIp6, src: "fd00::2", dst: "fd00::1", proto: IpIcmp6,
+	},
+	{
+		name: "hbh6",
+		frame: "3333000100020a000000000186dd60000000001000fffe800000000000000000000000000001ff020000000000000000000000010002" +
+			"1100050200000100022202230008fe11",
+		family: EthIp6, src: "fe80::1", dst: "ff02::1:2", proto: IpUdp, sport: 546, dport: 547,
+	},
+}
+
+func TestFrameProto(t *testing.T) {
+	for _, c := range protoFrames {
+		frame, err := hex.DecodeString(c.frame)
+		assert.Nil(t, err, c.name)
+		proto := NewFrameProto(frame)
+		assert.Nil(t, proto.Decode(), c.name)
+		assert.Equal(t, c.family, proto.Family(), c.name)
+		assert.Equal(t, c.proto, proto.Protocol(), c.name)
+		if c.family != 0 {
+			assert.Equal(t, c.src, net.IP(proto.Source()).String(), c.name)
+			assert.Equal(t, c.dst, net.IP(proto.Destination()).String(), c.name)
+		}
+		sport, dport := proto.Ports()
+		assert.Equal(t, c.sport, sport, c.name)
+		assert.Equal(t, c.dport, dport, c.name)
+	}
+}
+
+func TestFrameProtoNdp(t *testing.T) {
+	frame, _ := hex.DecodeString(protoFrames[5].frame)
+	proto := NewFrameProto(frame)
+	assert.Nil(t, proto.Decode(), "decode ns.")
+	assert.Equal(t, uint8(Icmp6NeighborSolicit), proto.Icmp6.Type, "be the same.")
+	assert.True(t, proto.Icmp6.IsNdp(), "be ndp.")
+	assert.Equal(t, "fd00::2", net.IP(proto.Ndp.Target).String(), "be the same.")
+	assert.Equal(t, "0a:00:00:00:00:01", net.HardwareAddr(proto.Ndp.LinkAddr(NdpOptSrcLink)).String(), "be the same.")
+	assert.Nil(t, proto.Ndp.LinkAddr(NdpOptTgtLink), "no target.")
+	payload := frame[EtherLen+Ipv6Len:]
+	assert.Equal(t, uint16(0), Ipv6Checksum(proto.Ip6.Source, proto.Ip6.Destination, IpIcmp6, payload), "checksum.")
+
+	frame, _ = hex.DecodeString(protoFrames[6].frame)
+	proto = NewFrameProto(frame)
+	assert.Nil(t, proto.Decode(), "decode na.")
+	assert.Equal(t, uint8(Icmp6NeighborAdvert), proto.Icmp6.Type, "be the same.")
+	assert.True(t, proto.Ndp.HasFlag(NdpSolicited|NdpOverride), "flags.")
+	assert.False(t, proto.Ndp.HasFlag(NdpRouter), "flags.")
+	assert.Equal(t, "0a:00:00:00:00:02", net.HardwareAddr(proto.Ndp.LinkAddr(NdpOptTgtLink)).String(), "be the same.")
+
+	// encode again.
+	data := append(proto.Icmp6.Encode(), proto.Ndp.Encode()...)
+	assert.Equal(t, frame[EtherLen+Ipv6Len:], data, "be the same.")
+	assert.Equal(t, frame[EtherLen:EtherLen+Ipv6Len], proto.Ip6.Encode(), "be the same.")
+}
+
+func TestFrameProtoExt(t *testing.T) {
+	frame, _ := hex.DecodeString(protoFrames[7].frame)
+	proto := NewFrameProto(frame)
+	assert.Nil(t, proto.Decode(), "decode.")
+	assert.Equal(t, 1, len(proto.Exts), "be the same.")
+	assert.Equal(t, uint8(IpHopByHop), proto.Exts[0].Type, "be the same.")
+	assert.Equal(t, 8, proto.Exts[0].Len, "be the same.")
+	payload := frame[EtherLen+Ipv6Len+8:]
+	assert.Equal(t, uint16(0), Ipv6Checksum(proto.Ip6.Source, proto.Ip6.Destination, IpUdp, payload), "checksum.")
+
+	// truncated extension header.
+	proto = NewFrameProto(frame[:EtherLen+Ipv6Len+4])
+	assert.NotNil(t, proto.Decode(), "too small.")
+}
+
+func TestFrameProtoIcmp(t *testing.T) {
+	frame, _ := hex.DecodeString(protoFrames[3].frame)
+	proto := NewFrameProto(frame)
+	assert.Nil(t, proto.Decode(), "decode.")
+	assert.Equal(t, uint8(IcmpEchoRequest), proto.Icmp.Type, "be the same.")
+	assert.Equal(t, uint32(0x12340001), proto.Icmp.Rest, "be the same.")
+	data := frame[EtherLen+Ipv4Len:]
+	assert.Equal(t, data[:IcmpLen], proto.Icmp.Encode(), "be the same.")
+	assert.Equal(t, uint16(0), IpChecksum(data), "checksum.")
+}
+
+func TestIpv4Checksum(t *testing.T) {
+	frame, _ := hex.DecodeString(protoFrames[1].frame)
+	proto := NewFrameProto(frame)
+	assert.Nil(t, proto.Decode(), "decode.")
+	payload := frame[EtherLen+Ipv4Len:]
+	sum := Ipv4Checksum(proto.Ip4.Source, proto.Ip4.Destination, IpTcp, payload)
+	assert.NotEqual(t, uint16(0), sum, "not filled.")
+	binary.BigEndian.PutUint16(payload[16:18], sum)
+	assert.Equal(t, uint16(0), Ipv4Checksum(proto.Ip4.Source, proto.Ip4.Destination, IpTcp, payload), "checksum.")
+}
@@ -0,0 +1,203 @@
+package libol
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DialFunc connects to addr, such as net.Dial or a userspace stack.
+type DialFunc func(network, addr string) (net.Conn, error)
+
+// ProxyServer accepts socks5 or http CONNECT requests, and tunnels them
+// to targets connected by dial.
+type ProxyServer struct {
+	address  string
+	socks    bool
+	dial     DialFunc
+	listener net.Listener
+}
+
+func NewSocks5Server(listen string, dial DialFunc) *ProxyServer {
+	return &ProxyServer{address: listen, socks: true, dial: dial}
+}
+
+func NewHttpProxy(listen string, dial DialFunc) *ProxyServer {
+	return &ProxyServer{address: listen, dial: dial}
+}
+
+func (p *ProxyServer) String() string {
+	if p.socks {
+		return "socks5://" + p.Addr()
+	}
+	return "http://" + p.Addr()
+}
+
+func (p *ProxyServer) Addr() string {
+	if p.listener != nil {
+		return p.listener.Addr().String()
+	}
+	return p.address
+}
+
+func (p *ProxyServer) Listen() (err error) {
+	p.listener, err = net.Listen("tcp", p.address)
+	if err != nil {
+		p.listener = nil
+		return err
+	}
+	Info("ProxyServer.Listen: %s", p)
+	return nil
+}
+
+func (p *ProxyServer) Close() {
+	if p.listener != nil {
+		_ = p.listener.Close()
+		Info("ProxyServer.Close: %s", p)
+	}
+}
+
+func (p *ProxyServer) Accept() {
+	defer Info("ProxyServer.Accept: %s exit", p)
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		if p.socks {
+			go p.serveSocks5(conn)
+		} else {
+			go p.serveConnect(conn)
+		}
+	}
+}
+
+func (p *ProxyServer) serveConnect(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	if req.Method != "CONNECT" {
+		_, _ = conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\n\r\n"))
+		_ = conn.Close()
+		return
+	}
+	target, err := p.dial("tcp", req.Host)
+	if err != nil {
+		Warn("ProxyServer.serveConnect: %s", err)
+		_, _ = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	if n := reader.Buffered(); n > 0 {
+		data, _ := reader.Peek(n)
+		_, _ = target.Write(data)
+	}
+	Relay(conn, target)
+}
+
+// socks5Reply returns reply code of socks5 by error of dial.
+func socks5Reply(err error) byte {
+	if nErr, ok := err.(net.Error); ok && nErr.Timeout() {
+		return 0x04
+	}
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	switch err {
+	case syscall.ENETUNREACH:
+		return 0x03
+	case syscall.EHOSTUNREACH:
+		return 0x04
+	case syscall.ECONNREFUSED:
+		return 0x05
+	}
+	return 0x01
+}
+
+// serveSocks5 accepts command CONNECT without authentication.
+func (p *ProxyServer) serveSocks5(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil || buf[0] != 0x05 {
+		_ = conn.Close()
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		_ = conn.Close()
+		return
+	}
+	method := byte(0xff)
+	for _, m := range buf[:buf[1]] {
+		if m == 0x00 {
+			method = m
+		}
+	}
+	if _, err := conn.Write([]byte{0x05, method}); err != nil || method == 0xff {
+		_ = conn.Close()
+		return
+	}
+	reply := func(rep byte) {
+		_, _ = conn.Write([]byte{0x05, rep, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	}
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		_ = conn.Close()
+		return
+	}
+	if buf[1] != 0x01 {
+		reply(0x07)
+		_ = conn.Close()
+		return
+	}
+	host := ""
+	switch buf[3] {
+	case 0x01:
+		if _, err := io.ReadFull(conn, buf[:net.IPv4len]); err == nil {
+			host = net.IP(buf[:net.IPv4len]).String()
+		}
+	case 0x04:
+		if _, err := io.ReadFull(conn, buf[:net.IPv6len]); err == nil {
+			host = net.IP(buf[:net.IPv6len]).String()
+		}
+	case 0x03:
+		if _, err := io.ReadFull(conn, buf[:1]); err == nil {
+			size := int(buf[0])
+			if _, err := io.ReadFull(conn, buf[:size]); err == nil {
+				host = string(buf[:size])
+			}
+		}
+	default:
+		reply(0x08)
+		_ = conn.Close()
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil || host == "" {
+		_ = conn.Close()
+		return
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+	target, err := p.dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		Warn("ProxyServer.serveSocks5: %s", err)
+		reply(socks5Reply(err))
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	reply(0x00)
+	Relay(conn, target)
+}
@@ -0,0 +1,55 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestProxyServer(t *testing.T) {
+	echo, err := newEchoServer()
+	assert.Nil(t, err, "listen.")
+	defer echo.Close()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	refused := closed.Addr().String()
+	_ = closed.Close()
+
+	dial := func(network, addr string) (net.Conn, error) {
+		return net.Dial(network, addr)
+	}
+	servers := []*ProxyServer{
+		NewSocks5Server("127.0.0.1:0", dial),
+		NewHttpProxy("127.0.0.1:0", dial),
+	}
+	for _, s := range servers {
+		assert.Nil(t, s.Listen(), "listen.")
+		go s.Accept()
+
+		conn, err := DialProxy(s.String(), echo.Addr().String(), &net.Dialer{})
+		assert.Nil(t, err, s.String())
+		if conn != nil {
+			_, err = conn.Write([]byte("hello"))
+			assert.Nil(t, err, "write.")
+			buf := make([]byte, 5)
+			_, err = io.ReadFull(conn, buf)
+			assert.Nil(t, err, "read.")
+			assert.Equal(t, "hello", string(buf), "be the same.")
+			_ = conn.Close()
+		}
+
+		_, err = DialProxy(s.String(), refused, &net.Dialer{})
+		assert.IsType(t, &ProxyError{}, err, "proxy error.")
+		assert.Equal(t, "connect", err.(*ProxyError).Op, s.String())
+		s.Close()
+	}
+}
+
+func TestSocks5Reply(t *testing.T) {
+	_, err := net.Dial("tcp", "127.0.0.1:1")
+	assert.Equal(t, byte(0x05), socks5Reply(err), "refused.")
+	err = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ENETUNREACH}
+	assert.Equal(t, byte(0x03), socks5Reply(err), "no route.")
+	assert.Equal(t, byte(0x01), socks5Reply(NewErr("unknown")), "general.")
+}
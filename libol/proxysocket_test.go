@@ -0,0 +1,237 @@
+package libol
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// proxyServer is a stand-in of http and socks5 proxy, and credentials
+// are checked if user given.
+type proxyServer struct {
+	listener net.Listener
+	socks    bool
+	user     string
+	pass     string
+}
+
+func newProxyServer(socks bool, user, pass string) (*proxyServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &proxyServer{listener: listener, socks: socks, user: user, pass: pass}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if p.socks {
+				go p.serveSocks5(conn)
+			} else {
+				go p.serveConnect(conn)
+			}
+		}
+	}()
+	return p, nil
+}
+
+func (p *proxyServer) Url() string {
+	scheme := "http"
+	if p.socks {
+		scheme = "socks5"
+	}
+	if p.user != "" {
+		return fmt.Sprintf("%s://%s:%s@%s", scheme, p.user, p.pass, p.listener.Addr())
+	}
+	return fmt.Sprintf("%s://%s", scheme, p.listener.Addr())
+}
+
+func (p *proxyServer) Close() {
+	_ = p.listener.Close()
+}
+
+func (p *proxyServer) relay(conn net.Conn, target net.Conn) {
+	go func() {
+		_, _ = io.Copy(target, conn)
+		_ = target.Close()
+	}()
+	_, _ = io.Copy(conn, target)
+	_ = conn.Close()
+}
+
+func (p *proxyServer) serveConnect(conn net.Conn) {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || req.Method != "CONNECT" {
+		_ = conn.Close()
+		return
+	}
+	if p.user != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(p.user + ":" + p.pass))
+		if req.Header.Get("Proxy-Authorization") != "Basic "+auth {
+			_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+			_ = conn.Close()
+			return
+		}
+	}
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		_, _ = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		_ = conn.Close()
+		return
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	p.relay(conn, target)
+}
+
+func (p *proxyServer) serveSocks5(conn net.Conn) {
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		_ = conn.Close()
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		_ = conn.Close()
+		return
+	}
+	if p.user == "" {
+		_, _ = conn.Write([]byte{0x05, 0x00})
+	} else {
+		_, _ = conn.Write([]byte{0x05, 0x02})
+		_, _ = io.ReadFull(conn, buf[:2])
+		user := make([]byte, buf[1])
+		_, _ = io.ReadFull(conn, user)
+		_, _ = io.ReadFull(conn, buf[:1])
+		pass := make([]byte, buf[0])
+		_, _ = io.ReadFull(conn, pass)
+		if string(user) != p.user || string(pass) != p.pass {
+			_, _ = conn.Write([]byte{0x01, 0x01})
+			_ = conn.Close()
+			return
+		}
+		_, _ = conn.Write([]byte{0x01, 0x00})
+	}
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		_ = conn.Close()
+		return
+	}
+	host := ""
+	switch buf[3] {
+	case 0x01:
+		_, _ = io.ReadFull(conn, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case 0x03:
+		_, _ = io.ReadFull(conn, buf[:1])
+		size := int(buf[0])
+		_, _ = io.ReadFull(conn, buf[:size])
+		host = string(buf[:size])
+	}
+	_, _ = io.ReadFull(conn, buf[:2])
+	port := binary.BigEndian.Uint16(buf[:2])
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		_ = conn.Close()
+		return
+	}
+	_, _ = conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	p.relay(conn, target)
+}
+
+func newEchoServer() (net.Listener, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return listener, nil
+}
+
+func TestDialProxy(t *testing.T) {
+	echo, err := newEchoServer()
+	assert.Nil(t, err, "listen.")
+	defer echo.Close()
+	// unused port to refuse.
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	refused := closed.Addr().String()
+	_ = closed.Close()
+
+	for _, socks := range []bool{false, true} {
+		p, err := newProxyServer(socks, "hi", "pass")
+		assert.Nil(t, err, "listen.")
+
+		conn, err := DialProxy(p.Url(), echo.Addr().String(), &net.Dialer{})
+		assert.Nil(t, err, p.Url())
+		if conn != nil {
+			_, err = conn.Write([]byte("hello"))
+			assert.Nil(t, err, "write.")
+			buf := make([]byte, 5)
+			_, err = io.ReadFull(conn, buf)
+			assert.Nil(t, err, "read.")
+			assert.Equal(t, "hello", string(buf), "be the same.")
+			_ = conn.Close()
+		}
+
+		// wrong password.
+		wrong := *p
+		wrong.pass = "wrong"
+		_, err = DialProxy(wrong.Url(), echo.Addr().String(), &net.Dialer{})
+		assert.IsType(t, &ProxyError{}, err, "proxy error.")
+		assert.Equal(t, "auth", err.(*ProxyError).Op, p.Url())
+		assert.NotContains(t, err.Error(), "wrong", "credentials not shown.")
+
+		// target refused.
+		_, err = DialProxy(p.Url(), refused, &net.Dialer{})
+		assert.IsType(t, &ProxyError{}, err, "proxy error.")
+		assert.Equal(t, "connect", err.(*ProxyError).Op, p.Url())
+		p.Close()
+	}
+
+	_, err = DialProxy("socks5://"+refused, echo.Addr().String(), &net.Dialer{})
+	assert.IsType(t, &ProxyError{}, err, "proxy error.")
+	assert.Equal(t, "dial", err.(*ProxyError).Op, "be the same.")
+	_, err = DialProxy("ftp://"+refused, echo.Addr().String(), &net.Dialer{})
+	assert.Equal(t, "dial", err.(*ProxyError).Op, "not supported.")
+}
+
+func TestTcpClientProxy(t *testing.T) {
+	echo, err := newEchoServer()
+	assert.Nil(t, err, "listen.")
+	defer echo.Close()
+	p, err := newProxyServer(true, "", "")
+	assert.Nil(t, err, "listen.")
+	defer p.Close()
+
+	c := NewTcpClient(echo.Addr().String(), &TcpConfig{Proxy: p.Url()})
+	assert.Nil(t, c.Connect(), "connect.")
+	defer c.Close()
+	assert.Nil(t, c.WriteReq("ping", "hello"), "write.")
+	frame, err := c.ReadMsg()
+	assert.Nil(t, err, "read.")
+	frame.Decode()
+	action, params := frame.CmdAndParams()
+	assert.Equal(t, "ping=", action, "be the same.")
+	assert.Equal(t, "hello", params, "be the same.")
+
+	c = NewTcpClient(echo.Addr().String(), &TcpConfig{Proxy: "http://127.0.0.1:1"})
+	assert.IsType(t, &ProxyError{}, c.Connect(), "proxy error.")
+}
@@ -0,0 +1,118 @@
+package libol
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+)
+
+// ResolvAbsent is saved in backup if file not existed before applied.
+const ResolvAbsent = "# absent before applied by openlan\n"
+
+// ResolvConf manages file of resolv.conf, and the original is saved in
+// backup of the instance until restored. The backup left by previous is
+// restored before applying.
+type ResolvConf struct {
+	file   string
+	backup string
+	stub   *DnsStub
+}
+
+// NewResolvConf returns resolv.conf of file, and the backup is keyed by
+// name of instance, such as network.
+func NewResolvConf(file, name string) *ResolvConf {
+	return &ResolvConf{file: file, backup: file + ".openlan-" + name}
+}
+
+// ParseResolvConf returns nameservers, search domains and other lines.
+func ParseResolvConf(data []byte) (servers, search, others []string) {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			if len(fields) > 1 {
+				servers = append(servers, fields[1])
+			}
+		case "search", "domain":
+			search = append(search, fields[1:]...)
+		default:
+			others = append(others, line)
+		}
+	}
+	return servers, search, others
+}
+
+// Apply writes servers and domains, and names in domains are resolved by
+// a stub listening on stub if given, which forwards others to original.
+func (r *ResolvConf) Apply(servers, domains []string, stub string) error {
+	if err := r.Restore(); err != nil {
+		return err
+	}
+	orig, err := ioutil.ReadFile(r.file)
+	backup := orig
+	if os.IsNotExist(err) {
+		backup = []byte(ResolvAbsent)
+	} else if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(r.backup, backup, 0644); err != nil {
+		return err
+	}
+	origServers, origSearch, others := ParseResolvConf(orig)
+	nameservers := append(append([]string{}, servers...), origServers...)
+	if len(domains) > 0 && stub != "" {
+		s := NewDnsStub(stub, domains, servers, origServers)
+		if err := s.Listen(); err != nil {
+			Warn("ResolvConf.Apply: %s", err)
+		} else {
+			go s.Accept()
+			r.stub = s
+			host, _, _ := net.SplitHostPort(s.Addr())
+			nameservers = []string{host}
+		}
+	}
+	buf := bytes.NewBufferString("# generated by openlan, original in " + r.backup + "\n")
+	for _, server := range nameservers {
+		buf.WriteString("nameserver " + server + "\n")
+	}
+	if search := append(append([]string{}, domains...), origSearch...); len(search) > 0 {
+		buf.WriteString("search " + strings.Join(search, " ") + "\n")
+	}
+	for _, line := range others {
+		buf.WriteString(line + "\n")
+	}
+	if err := ioutil.WriteFile(r.file, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	Info("ResolvConf.Apply: %s %s on %s", servers, domains, r.file)
+	return nil
+}
+
+// Restore writes the original back, or removes file if absent before, and
+// it's nothing if not applied.
+func (r *ResolvConf) Restore() error {
+	if r.stub != nil {
+		r.stub.Close()
+		r.stub = nil
+	}
+	orig, err := ioutil.ReadFile(r.backup)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if string(orig) == ResolvAbsent {
+		if err := os.Remove(r.file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := ioutil.WriteFile(r.file, orig, 0644); err != nil {
+		return err
+	}
+	Info("ResolvConf.Restore: %s", r.file)
+	return os.Remove(r.backup)
+}
@@ -0,0 +1,55 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvConf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolv")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "resolv.conf")
+	orig := "# by dhcp\nnameserver 192.168.0.1\nsearch home\noptions edns0\n"
+	_ = ioutil.WriteFile(file, []byte(orig), 0644)
+
+	r := NewResolvConf(file, "default")
+	assert.Nil(t, r.Apply([]string{"10.0.0.2"}, nil, ""), "apply.")
+	data, _ := ioutil.ReadFile(file)
+	servers, search, others := ParseResolvConf(data)
+	assert.Equal(t, []string{"10.0.0.2", "192.168.0.1"}, servers, "be the same.")
+	assert.Equal(t, []string{"home"}, search, "be the same.")
+	assert.Equal(t, []string{"options edns0"}, others, "be the same.")
+
+	// left by previous is restored firstly.
+	r = NewResolvConf(file, "default")
+	assert.Nil(t, r.Apply([]string{"10.0.0.2"}, []string{"corp.lan"}, "127.0.0.1:0"), "apply.")
+	assert.NotNil(t, r.stub, "stub.")
+	data, _ = ioutil.ReadFile(file)
+	servers, search, _ = ParseResolvConf(data)
+	assert.Equal(t, []string{"127.0.0.1"}, servers, "by stub.")
+	assert.Equal(t, []string{"corp.lan", "home"}, search, "be the same.")
+	assert.Equal(t, []string{"10.0.0.2:53"}, r.stub.servers, "be the same.")
+	assert.Equal(t, []string{"192.168.0.1:53"}, r.stub.fallback, "be the same.")
+
+	assert.Nil(t, r.Restore(), "restore.")
+	assert.Nil(t, r.stub, "stub closed.")
+	data, _ = ioutil.ReadFile(file)
+	assert.Equal(t, orig, string(data), "restored.")
+	assert.NotNil(t, FileExist(file+".openlan-default"), "backup removed.")
+	assert.Nil(t, r.Restore(), "not applied.")
+
+	// removed if absent before.
+	absent := filepath.Join(dir, "absent.conf")
+	r = NewResolvConf(absent, "default")
+	assert.Nil(t, r.Apply([]string{"10.0.0.2"}, nil, ""), "apply.")
+	assert.Nil(t, FileExist(absent), "written.")
+	assert.Nil(t, NewResolvConf(absent, "other").Restore(), "not applied by other.")
+	assert.Nil(t, FileExist(absent), "not restored by other.")
+	assert.Nil(t, r.Restore(), "restore.")
+	assert.NotNil(t, FileExist(absent), "removed.")
+	assert.NotNil(t, FileExist(absent+".openlan-default"), "backup removed.")
+}
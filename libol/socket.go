@@ -1,6 +1,8 @@
 package libol
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"sync"
 	"time"
@@ -17,10 +19,13 @@ const (
 )
 
 type ClientSts struct {
-	SendOkay  uint64 `json:"send"`
-	RecvOkay  uint64 `json:"recv"`
-	SendError uint64 `json:"error"`
-	Dropped   uint64 `json:"dropped"`
+	SendOkay   uint64 `json:"send"`
+	RecvOkay   uint64 `json:"recv"`
+	SendFrames uint64 `json:"sendFrames"`
+	RecvFrames uint64 `json:"recvFrames"`
+	SendError  uint64 `json:"error"`
+	Dropped    uint64 `json:"dropped"`
+	AuthError  uint64 `json:"authError"`
 }
 
 type ClientListener struct {
@@ -56,6 +61,7 @@ type SocketClient interface {
 	Sts() ClientSts
 	SetListener(listener ClientListener)
 	SetTimeout(v int64)
+	PeerCert() *x509.Certificate
 }
 
 type dataStream struct {
@@ -65,6 +71,7 @@ type dataStream struct {
 	maxSize    int
 	minSize    int
 	connecter  func() error
+	session    *AeadSession
 }
 
 func (t *dataStream) String() string {
@@ -92,6 +99,7 @@ func (t *dataStream) WriteMsg(frame *FrameMessage) error {
 		return err
 	}
 	t.sts.SendOkay += uint64(n)
+	t.sts.SendFrames++
 	return nil
 }
 
@@ -105,13 +113,73 @@ func (t *dataStream) ReadMsg() (*FrameMessage, error) {
 	if t.message == nil { // default is stream message
 		t.message = &StreamMessage{}
 	}
-	frame, err := t.message.Receive(t.connection, t.maxSize, t.minSize)
+	for {
+		frame, err := t.message.Receive(t.connection, t.maxSize, t.minSize)
+		if err == ErrAeadAuth {
+			t.sts.AuthError++
+			Warn("dataStream.ReadMsg: %s %s", t, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.session != nil && !t.session.Ready() {
+			if err := t.respond(frame); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t.sts.RecvOkay += uint64(len(frame.frame))
+		t.sts.RecvFrames++
+		return frame, nil
+	}
+}
+
+// handshake exchanges session keys as initiator after connected.
+func (t *dataStream) handshake(conn net.Conn) error {
+	if t.session == nil {
+		return nil
+	}
+	t.session.Reset()
+	hello, err := t.session.Hello()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	req := NewControlMessage(AeadAction, "= ", hello).Encode()
+	if _, err := t.message.Send(conn, req); err != nil {
+		return err
+	}
+	resp, err := t.message.Receive(conn, t.maxSize, t.minSize)
+	if err != nil {
+		return err
+	}
+	resp.Decode()
+	action, params := resp.CmdAndParams()
+	if action != AeadAction+":" {
+		return NewErr("%s: key exchange not supported", t)
 	}
-	t.sts.RecvOkay += uint64(len(frame.frame))
+	return t.session.Finish(params)
+}
 
-	return frame, nil
+// respond answers key exchange from initiator.
+func (t *dataStream) respond(frame *FrameMessage) error {
+	frame.Decode()
+	action, params := frame.CmdAndParams()
+	if action != AeadAction+"=" {
+		return NewErr("%s: key not exchanged", t)
+	}
+	hello, err := t.session.Respond(params)
+	if err != nil {
+		return err
+	}
+	resp := NewControlMessage(AeadAction, ": ", hello).Encode()
+	if _, err := t.message.Send(t.connection, resp); err != nil {
+		return err
+	}
+	t.session.Commit()
+	return nil
 }
 
 func (t *dataStream) WriteReq(action string, body string) error {
@@ -177,6 +245,14 @@ func (s *socketClient) Status() uint8 {
 	return s.status
 }
 
+// IsOk returns true if connected, and it's checked with lock as the
+// connection is closed by others.
+func (s *socketClient) IsOk() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.connection != nil
+}
+
 func (s *socketClient) UpTime() int64 {
 	return time.Now().Unix() - s.newTime
 }
@@ -247,6 +323,24 @@ func (s *socketClient) SetTimeout(v int64) {
 	s.timeout = v
 }
 
+// PeerCert returns the verified certificate of peer, or nil if
+// the connection is not tls or peer has no certificate.
+func (s *socketClient) PeerCert() *x509.Certificate {
+	s.lock.RLock()
+	conn, ok := s.connection.(interface {
+		ConnectionState() tls.ConnectionState
+	})
+	s.lock.RUnlock()
+	if !ok {
+		return nil
+	}
+	state := conn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
 // Socket Server
 
 type ServerSts struct {
@@ -8,9 +8,12 @@ import (
 )
 
 type TcpConfig struct {
+	Aead    *AeadConfig
 	Tls     *tls.Config
 	Block   kcp.BlockCrypt
 	Timeout time.Duration // ns
+	Local   string        // address or interface to dial from.
+	Proxy   string        // url of proxy to tunnel.
 }
 
 // Server Implement
@@ -86,7 +89,11 @@ func (t *TcpServer) Accept() {
 			return
 		}
 		t.sts.AcceptCount++
-		t.onClients <- NewTcpClientFromConn(conn, t.tcpCfg)
+		Go(func() {
+			acceptMux(conn, func(conn net.Conn) {
+				t.onClients <- NewTcpClientFromConn(conn, t.tcpCfg)
+			})
+		})
 	}
 }
 
@@ -98,6 +105,7 @@ type TcpClient struct {
 }
 
 func NewTcpClient(addr string, cfg *TcpConfig) *TcpClient {
+	session := NewAeadSession(cfg.Aead)
 	t := &TcpClient{
 		tcpCfg: cfg,
 		socketClient: socketClient{
@@ -107,8 +115,10 @@ func NewTcpClient(addr string, cfg *TcpConfig) *TcpClient {
 				maxSize: 1514,
 				minSize: 15,
 				message: &StreamMessage{
-					block: cfg.Block,
+					block:   cfg.Block,
+					session: session,
 				},
+				session: session,
 			},
 			status: ClInit,
 		},
@@ -118,6 +128,7 @@ func NewTcpClient(addr string, cfg *TcpConfig) *TcpClient {
 }
 
 func NewTcpClientFromConn(conn net.Conn, cfg *TcpConfig) *TcpClient {
+	session := NewAeadSession(cfg.Aead)
 	t := &TcpClient{
 		tcpCfg: cfg,
 		socketClient: socketClient{
@@ -127,8 +138,10 @@ func NewTcpClientFromConn(conn net.Conn, cfg *TcpConfig) *TcpClient {
 				maxSize:    1514,
 				minSize:    15,
 				message: &StreamMessage{
-					block: cfg.Block,
+					block:   cfg.Block,
+					session: session,
 				},
+				session: session,
 			},
 			newTime: time.Now().Unix(),
 		},
@@ -137,22 +150,64 @@ func NewTcpClientFromConn(conn net.Conn, cfg *TcpConfig) *TcpClient {
 	return t
 }
 
+// DialTcp returns connection of tcp, or tls if configured.
+func DialTcp(addr string, cfg *TcpConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if cfg.Local != "" {
+		if ip := localIP(cfg.Local); ip != nil {
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+	if cfg.Proxy != "" {
+		return dialTcpProxy(addr, cfg, dialer)
+	}
+	if cfg.Tls != nil {
+		Info("DialTcp: tls://%s", addr)
+		return tls.DialWithDialer(dialer, "tcp", addr, cfg.Tls)
+	}
+	Info("DialTcp: tcp://%s", addr)
+	return dialer.Dial("tcp", addr)
+}
+
+// dialTcpProxy returns connection tunneled by proxy, and tls is handshake
+// over the tunnel.
+func dialTcpProxy(addr string, cfg *TcpConfig, dialer *net.Dialer) (net.Conn, error) {
+	conn, err := DialProxy(cfg.Proxy, addr, dialer)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Tls == nil {
+		Info("DialTcp: tcp://%s by proxy", addr)
+		return conn, nil
+	}
+	Info("DialTcp: tls://%s by proxy", addr)
+	tlsCfg := cfg.Tls
+	if tlsCfg.ServerName == "" {
+		tlsCfg = tlsCfg.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			tlsCfg.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, tlsCfg)
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
 func (t *TcpClient) Connect() error {
 	if !t.retry() {
 		return nil
 	}
-	var err error
-	var conn net.Conn
-	if t.tcpCfg.Tls != nil {
-		Info("TcpClient.Connect: tls://%s", t.address)
-		conn, err = tls.Dial("tcp", t.address, t.tcpCfg.Tls)
-	} else {
-		Info("TcpClient.Connect: tcp://%s", t.address)
-		conn, err = net.Dial("tcp", t.address)
-	}
+	conn, err := DialTcp(t.address, t.tcpCfg)
 	if err != nil {
 		return err
 	}
+	if err := t.handshake(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
 	t.lock.Lock()
 	t.connection = conn
 	t.status = ClConnected
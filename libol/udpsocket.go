@@ -7,6 +7,7 @@ import (
 )
 
 type UdpConfig struct {
+	Aead    *AeadConfig
 	Block   kcp.BlockCrypt
 	Timeout time.Duration // ns
 }
@@ -94,6 +95,7 @@ func NewUdpClient(addr string, cfg *UdpConfig) *UdpClient {
 	if cfg == nil {
 		cfg = &defaultUdpConfig
 	}
+	session := NewAeadSession(cfg.Aead)
 	c := &UdpClient{
 		udpCfg: cfg,
 		socketClient: socketClient{
@@ -105,7 +107,9 @@ func NewUdpClient(addr string, cfg *UdpConfig) *UdpClient {
 				message: &DataGramMessage{
 					timeout: cfg.Timeout,
 					block:   cfg.Block,
+					session: session,
 				},
+				session: session,
 			},
 			status: ClInit,
 		},
@@ -118,6 +122,7 @@ func NewUdpClientFromConn(conn net.Conn, cfg *UdpConfig) *UdpClient {
 	if cfg == nil {
 		cfg = &defaultUdpConfig
 	}
+	session := NewAeadSession(cfg.Aead)
 	c := &UdpClient{
 		socketClient: socketClient{
 			address: conn.RemoteAddr().String(),
@@ -128,7 +133,9 @@ func NewUdpClientFromConn(conn net.Conn, cfg *UdpConfig) *UdpClient {
 				message: &DataGramMessage{
 					timeout: cfg.Timeout,
 					block:   cfg.Block,
+					session: session,
 				},
+				session: session,
 			},
 			newTime: time.Now().Unix(),
 		},
@@ -146,6 +153,10 @@ func (c *UdpClient) Connect() error {
 	if err != nil {
 		return err
 	}
+	if err := c.handshake(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
 	c.lock.Lock()
 	c.connection = conn
 	c.status = ClConnected
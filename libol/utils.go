@@ -194,3 +194,15 @@ func Wait() {
 	n := <-x
 	Warn("Wait: ... Signal %d received ...", n)
 }
+
+// OnHangup calls the function each time SIGHUP received.
+func OnHangup(call func()) {
+	x := make(chan os.Signal, 1)
+	signal.Notify(x, syscall.SIGHUP)
+	Go(func() {
+		for n := range x {
+			Info("OnHangup: ... Signal %d received ...", n)
+			call()
+		}
+	})
+}
@@ -3,9 +3,14 @@ package libol
 import (
 	"crypto/tls"
 	"github.com/danieldin95/lightstar/libstar"
+	"github.com/xtaci/kcp-go/v5"
 	"golang.org/x/net/websocket"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
 type WsClient struct {
@@ -42,3 +47,335 @@ func (w *WsClient) Dial() (ws *websocket.Conn, err error) {
 	}
 	return websocket.DialConfig(config)
 }
+
+const WsPath = "/openlan/ws"
+
+type WsConfig struct {
+	Aead    *AeadConfig
+	Tls     *tls.Config
+	Block   kcp.BlockCrypt
+	Timeout time.Duration // ns
+	Path    string        // default is WsPath
+	Shared  bool          // served by an external http server, no listener.
+}
+
+func (c *WsConfig) path() string {
+	if c.Path == "" {
+		return WsPath
+	}
+	return c.Path
+}
+
+// wsConn reports the addresses of the underlying tcp connection,
+// websocket.Conn returns the location and origin instead.
+type wsConn struct {
+	*websocket.Conn
+	local  net.Addr
+	remote net.Addr
+	state  *tls.ConnectionState
+}
+
+// ConnectionState returns tls state of the upgraded request.
+func (w *wsConn) ConnectionState() tls.ConnectionState {
+	if w.state != nil {
+		return *w.state
+	}
+	return tls.ConnectionState{}
+}
+
+func (w *wsConn) LocalAddr() net.Addr {
+	return w.local
+}
+
+func (w *wsConn) RemoteAddr() net.Addr {
+	return w.remote
+}
+
+// Server Implement
+
+type WsServer struct {
+	socketServer
+	wsCfg    *WsConfig
+	listener *http.Server
+}
+
+func NewWsServer(listen string, cfg *WsConfig) *WsServer {
+	t := &WsServer{
+		wsCfg: cfg,
+		socketServer: socketServer{
+			address:    listen,
+			sts:        ServerSts{},
+			maxClient:  1024,
+			clients:    NewSafeStrMap(1024),
+			onClients:  make(chan SocketClient, 4),
+			offClients: make(chan SocketClient, 8),
+		},
+	}
+	t.close = t.Close
+	return t
+}
+
+func (t *WsServer) Path() string {
+	return t.wsCfg.path()
+}
+
+func (t *WsServer) Listen() (err error) {
+	if t.wsCfg.Shared {
+		Info("WsServer.Listen: shared %s", t.Path())
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(t.Path(), t)
+	t.lock.Lock()
+	t.listener = &http.Server{
+		Addr:      t.address,
+		Handler:   mux,
+		TLSConfig: t.wsCfg.Tls,
+	}
+	t.lock.Unlock()
+	if t.wsCfg.Tls != nil {
+		Info("WsServer.Listen: wss://%s%s", t.address, t.Path())
+	} else {
+		Info("WsServer.Listen: ws://%s%s", t.address, t.Path())
+	}
+	return nil
+}
+
+// Close closes the listener, and it may be called while accepting.
+func (t *WsServer) Close() {
+	t.lock.Lock()
+	listener := t.listener
+	t.listener = nil
+	t.lock.Unlock()
+	if listener != nil {
+		_ = listener.Close()
+		Info("WsServer.Close: %s", t.address)
+	}
+}
+
+func (t *WsServer) Accept() {
+	Debug("WsServer.Accept")
+	if err := t.Listen(); err != nil {
+		return
+	}
+	t.lock.RLock()
+	listener := t.listener
+	t.lock.RUnlock()
+	if listener == nil {
+		return
+	}
+	defer t.Close()
+	var err error
+	if t.wsCfg.Tls != nil {
+		err = listener.ListenAndServeTLS("", "")
+	} else {
+		err = listener.ListenAndServe()
+	}
+	if err != nil {
+		Error("WsServer.Accept: %s", err)
+	}
+}
+
+// ServeHTTP upgrades request to websocket and waits the client closed.
+func (t *WsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Handler(func(conn *websocket.Conn) {
+		// clear deadlines inherited from the http server.
+		_ = conn.SetDeadline(time.Time{})
+		conn.PayloadType = websocket.BinaryFrame
+		req := conn.Request()
+		remote, _ := net.ResolveTCPAddr("tcp", req.RemoteAddr)
+		local, _ := req.Context().Value(http.LocalAddrContextKey).(net.Addr)
+		client := NewWebSocketClientFromConn(&wsConn{
+			Conn:   conn,
+			local:  local,
+			remote: remote,
+			state:  req.TLS,
+		}, t.wsCfg)
+		t.sts.AcceptCount++
+		t.onClients <- client
+		<-client.done
+	}).ServeHTTP(w, r)
+}
+
+// Client Implement
+
+type WebSocketClient struct {
+	socketClient
+	wsCfg *WsConfig
+	done  chan bool
+	once  sync.Once
+}
+
+func NewWebSocketClient(addr string, cfg *WsConfig) *WebSocketClient {
+	session := NewAeadSession(cfg.Aead)
+	t := &WebSocketClient{
+		wsCfg: cfg,
+		socketClient: socketClient{
+			address: addr,
+			newTime: time.Now().Unix(),
+			dataStream: dataStream{
+				maxSize: 1514,
+				minSize: 15,
+				message: &StreamMessage{
+					timeout: cfg.Timeout,
+					block:   cfg.Block,
+					session: session,
+				},
+				session: session,
+			},
+			status: ClInit,
+		},
+	}
+	t.connecter = t.Connect
+	return t
+}
+
+func NewWebSocketClientFromConn(conn net.Conn, cfg *WsConfig) *WebSocketClient {
+	session := NewAeadSession(cfg.Aead)
+	t := &WebSocketClient{
+		wsCfg: cfg,
+		done:  make(chan bool),
+		socketClient: socketClient{
+			address: conn.RemoteAddr().String(),
+			dataStream: dataStream{
+				connection: conn,
+				maxSize:    1514,
+				minSize:    15,
+				message: &StreamMessage{
+					timeout: cfg.Timeout,
+					block:   cfg.Block,
+					session: session,
+				},
+				session: session,
+			},
+			newTime: time.Now().Unix(),
+		},
+	}
+	t.connecter = t.Connect
+	return t
+}
+
+// Url returns websocket location, address is host:port or an url.
+func (t *WebSocketClient) Url() string {
+	if strings.Contains(t.address, "://") {
+		return t.address
+	}
+	if t.wsCfg.Tls != nil {
+		return "wss://" + t.address + t.wsCfg.path()
+	}
+	return "ws://" + t.address + t.wsCfg.path()
+}
+
+// WsHost returns host and port to dial for websocket address, which is
+// host:port or an url.
+func WsHost(address string) string {
+	if !strings.Contains(address, "://") {
+		return address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return address
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func (t *WebSocketClient) dial() (net.Conn, error) {
+	wsUrl := t.Url()
+	config, err := websocket.NewConfig(wsUrl, wsUrl)
+	if err != nil {
+		return nil, err
+	}
+	host := WsHost(wsUrl)
+	var conn net.Conn
+	if config.Location.Scheme == "wss" {
+		tlsCfg := t.wsCfg.Tls
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{InsecureSkipVerify: true}
+		}
+		conn, err = tls.Dial("tcp", host, tlsCfg)
+	} else {
+		conn, err = net.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return &wsConn{
+		Conn:   ws,
+		local:  conn.LocalAddr(),
+		remote: conn.RemoteAddr(),
+	}, nil
+}
+
+func (t *WebSocketClient) Connect() error {
+	if !t.retry() {
+		return nil
+	}
+	Info("WebSocketClient.Connect: %s", t.Url())
+	conn, err := t.dial()
+	if err != nil {
+		return err
+	}
+	if err := t.handshake(conn); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	t.lock.Lock()
+	t.connection = conn
+	t.status = ClConnected
+	t.lock.Unlock()
+	if t.listener.OnConnected != nil {
+		_ = t.listener.OnConnected(t)
+	}
+	return nil
+}
+
+func (t *WebSocketClient) Close() {
+	t.lock.Lock()
+	if t.connection != nil {
+		if t.status != ClTerminal {
+			t.status = ClClosed
+		}
+		Info("WebSocketClient.Close: %s", t.address)
+		_ = t.connection.Close()
+		t.connection = nil
+		t.private = nil
+		t.lock.Unlock()
+		if t.done != nil {
+			t.once.Do(func() { close(t.done) })
+		}
+		if t.listener.OnClose != nil {
+			_ = t.listener.OnClose(t)
+		}
+	} else {
+		t.lock.Unlock()
+	}
+}
+
+func (t *WebSocketClient) Terminal() {
+	t.SetStatus(ClTerminal)
+	t.Close()
+}
+
+func (t *WebSocketClient) SetStatus(v uint8) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.status != v {
+		if t.listener.OnStatus != nil {
+			t.listener.OnStatus(t, t.status, v)
+		}
+		t.status = v
+	}
+}
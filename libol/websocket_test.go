@@ -0,0 +1,46 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWsClientAndServer(t *testing.T) {
+	cfg := &WsConfig{Timeout: 5 * time.Second}
+	server := NewWsServer("127.0.0.1:18082", cfg)
+	recv := make(chan *FrameMessage, 1)
+	go server.Accept()
+	go server.Loop(ServerListener{
+		OnClient: func(client SocketClient) error {
+			return nil
+		},
+		ReadAt: func(client SocketClient, f *FrameMessage) error {
+			recv <- f
+			return nil
+		},
+	})
+	defer server.Close()
+
+	client := NewWebSocketClient("127.0.0.1:18082", cfg)
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = client.Connect(); err == nil {
+			break
+		}
+		client.SetStatus(ClInit)
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Nil(t, err, "connect to server.")
+	defer client.Close()
+
+	frame := NewFrameMessage()
+	frame.Append([]byte("0123456789abcdefghijklmnopqrstuvwxyz"))
+	assert.Nil(t, client.WriteMsg(frame), "write frame.")
+	select {
+	case f := <-recv:
+		assert.Equal(t, "0123456789abcdefghijklmnopqrstuvwxyz", string(f.Frame()), "be the same.")
+	case <-time.After(5 * time.Second):
+		t.Error("receive frame timeout")
+	}
+}
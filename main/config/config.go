@@ -2,11 +2,14 @@ package config
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/xtaci/kcp-go/v5"
+	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 )
 
 type Log struct {
@@ -22,6 +25,11 @@ type Http struct {
 type Crypt struct {
 	Algo   string `json:"lago,omitempty" yaml:"algo,omitempty"`
 	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Rekey  int    `json:"rekey,omitempty" yaml:"rekey,omitempty"` // secs for aes-gcm and chacha20-poly1305
+}
+
+func (c *Crypt) IsAead() bool {
+	return libol.IsAead(c.Algo)
 }
 
 func (c *Crypt) IsZero() bool {
@@ -48,19 +56,69 @@ func GetAlias() string {
 	return libol.GenToken(13)
 }
 
+func GetCertPool(file string) *x509.CertPool {
+	if file == "" {
+		return nil
+	}
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		libol.Error("GetCertPool: %s", err)
+		return nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		libol.Error("GetCertPool: no certificate in %s", file)
+		return nil
+	}
+	return pool
+}
+
+// GetTlsCfg returns tls config for switch, and verifies certificate of
+// points by the ca if given.
 func GetTlsCfg(cfg Cert) *tls.Config {
 	if cfg.KeyFile != "" && cfg.CrtFile != "" {
 		cer, err := tls.LoadX509KeyPair(cfg.CrtFile, cfg.KeyFile)
 		if err != nil {
 			libol.Error("NewSwitch: %s", err)
 		}
-		return &tls.Config{Certificates: []tls.Certificate{cer}}
+		tlsCfg := &tls.Config{Certificates: []tls.Certificate{cer}}
+		if pool := GetCertPool(cfg.CaFile); pool != nil {
+			tlsCfg.ClientCAs = pool
+			if cfg.RequireClient {
+				tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+			} else {
+				tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+		return tlsCfg
 	}
 	return nil
 }
 
+// GetTlsClientCfg returns tls config for point, the switch is not verified
+// if no ca given.
+func GetTlsClientCfg(cfg *Cert) *tls.Config {
+	tlsCfg := &tls.Config{InsecureSkipVerify: true}
+	if cfg == nil {
+		return tlsCfg
+	}
+	if pool := GetCertPool(cfg.CaFile); pool != nil {
+		tlsCfg.InsecureSkipVerify = false
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.KeyFile != "" && cfg.CrtFile != "" {
+		cer, err := tls.LoadX509KeyPair(cfg.CrtFile, cfg.KeyFile)
+		if err != nil {
+			libol.Error("GetTlsClientCfg: %s", err)
+		} else {
+			tlsCfg.Certificates = []tls.Certificate{cer}
+		}
+	}
+	return tlsCfg
+}
+
 func GetBlock(cfg *Crypt) kcp.BlockCrypt {
-	if cfg == nil || cfg.IsZero() {
+	if cfg == nil || cfg.IsZero() || cfg.IsAead() {
 		return nil
 	}
 	var block kcp.BlockCrypt
@@ -80,3 +138,20 @@ func GetBlock(cfg *Crypt) kcp.BlockCrypt {
 	}
 	return block
 }
+
+// GetAead returns config of AEAD, and the secret is required if the
+// transport is not tls.
+func GetAead(cfg *Crypt, tls bool) *libol.AeadConfig {
+	if cfg == nil || !cfg.IsAead() {
+		return nil
+	}
+	if cfg.Secret == "" && !tls {
+		libol.Error("GetAead: %s without secret is refused except over tls", cfg.Algo)
+	}
+	return &libol.AeadConfig{
+		Cipher: cfg.Algo,
+		Secret: cfg.Secret,
+		Rekey:  time.Duration(cfg.Rekey) * time.Second,
+		Tls:    tls,
+	}
+}
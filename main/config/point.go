@@ -4,6 +4,7 @@ import (
 	"flag"
 	"github.com/danieldin95/openlan-go/libol"
 	"runtime"
+	"sort"
 )
 
 type Interface struct {
@@ -14,21 +15,101 @@ type Interface struct {
 	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
 }
 
+// PointNetwork is a network joined over the connection of point, and it
+// has its own device.
+type PointNetwork struct {
+	Network   string    `json:"network" yaml:"network"`
+	Username  string    `json:"username,omitempty" yaml:"username,omitempty"`
+	Password  string    `json:"password,omitempty" yaml:"password,omitempty"`
+	Interface Interface `json:"interface" yaml:"interface"`
+}
+
+// Endpoint is a switch to connect, and smaller priority is preferred.
+type Endpoint struct {
+	Connection string `json:"connection" yaml:"connection"`
+	Protocol   string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Priority   int    `json:"priority,omitempty" yaml:"priority,omitempty"`
+}
+
+// Bond is paths to the same switch, and frames are spread over paths by
+// hash of flow, or duplicated to all paths if redundant.
+type Bond struct {
+	Mode  string      `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Paths []*BondPath `json:"paths" yaml:"paths"`
+}
+
+// BondPath is a connection of bond, and the connection of point is used
+// if not given.
+type BondPath struct {
+	Connection string `json:"connection,omitempty" yaml:"connection,omitempty"`
+	Protocol   string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Local      string `json:"local,omitempty" yaml:"local,omitempty"` // address or interface to dial from.
+}
+
+// Userspace is proxies of point by provider userspace, which terminates
+// the network by a stack of its own without device of kernel.
+type Userspace struct {
+	Socks string `json:"socks,omitempty" yaml:"socks,omitempty"` // address socks5 listen on.
+	Http  string `json:"http,omitempty" yaml:"http,omitempty"`   // address http CONNECT listen on.
+}
+
+// Forward is a rule forwarding connections from Local to Remote on the
+// network, or from Remote on the network to Local if reverse.
+type Forward struct {
+	Local   string `json:"local" yaml:"local"`   // such as 127.0.0.1:8080.
+	Remote  string `json:"remote" yaml:"remote"` // such as 192.168.1.2:80, or :80 if reverse.
+	Reverse bool   `json:"reverse,omitempty" yaml:"reverse,omitempty"`
+}
+
+// Routing is split tunnelling of point on linux, and routes are installed
+// into a table of its own looked up by rule.
+type Routing struct {
+	Table    int      `json:"table,omitempty" yaml:"table,omitempty"`
+	Priority int      `json:"priority,omitempty" yaml:"priority,omitempty"` // of rule looking up table.
+	Include  []string `json:"include,omitempty" yaml:"include,omitempty"`   // only routes in these prefixes.
+	Exclude  []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`   // never routed via point.
+	Default  bool     `json:"default,omitempty" yaml:"default,omitempty"`   // route everything via gateway.
+	Gateway  string   `json:"gateway,omitempty" yaml:"gateway,omitempty"`   // next hop on network if default.
+}
+
+// Resolver is how dns pushed by switch is applied on linux, and auto is
+// by systemd-resolved if running, otherwise by resolv.conf. It's none by
+// default, and dns pushed is not applied.
+type Resolver struct {
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"` // none, auto, resolved or resolvconf.
+	File string `json:"file,omitempty" yaml:"file,omitempty"` // resolv.conf managed.
+	Stub string `json:"stub,omitempty" yaml:"stub,omitempty"` // address stub of split domains listens on.
+}
+
 type Point struct {
-	Alias       string    `json:"name,omitempty" yaml:"name,omitempty"`
-	Network     string    `json:"network,omitempty" yaml:"network,omitempty"`
-	Connection  string    `json:"connection" yaml:"connection"`
-	Timeout     int       `json:"timeout"`
-	Username    string    `json:"username,omitempty" yaml:"username,omitempty"`
-	Password    string    `json:"password,omitempty" yaml:"password,omitempty"`
-	Protocol    string    `json:"protocol,omitempty" yaml:"protocol,omitempty"`
-	Interface   Interface `json:"interface" yaml:"interface"`
-	Log         Log       `json:"log" yaml:"log"`
-	Http        *Http     `json:"http,omitempty" yaml:"http,omitempty"`
-	Crypt       *Crypt    `json:"crypt"`
-	Prof        string    `json:"prof" yaml:"prof"`
-	RequestAddr bool      `json:"-" yaml:"-"`
-	SaveFile    string    `json:"-" yaml:"-"`
+	Alias       string          `json:"name,omitempty" yaml:"name,omitempty"`
+	Network     string          `json:"network,omitempty" yaml:"network,omitempty"`
+	Connection  string          `json:"connection" yaml:"connection"`
+	Timeout     int             `json:"timeout"`
+	Username    string          `json:"username,omitempty" yaml:"username,omitempty"`
+	Password    string          `json:"password,omitempty" yaml:"password,omitempty"`
+	Credential  string          `json:"credential,omitempty" yaml:"credential,omitempty"` // name in credentials of switch.
+	Protocol    string          `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	Interface   Interface       `json:"interface" yaml:"interface"`
+	Log         Log             `json:"log" yaml:"log"`
+	Http        *Http           `json:"http,omitempty" yaml:"http,omitempty"`
+	Crypt       *Crypt          `json:"crypt"`
+	Cert        *Cert           `json:"cert,omitempty" yaml:"cert,omitempty"`
+	Prof        string          `json:"prof" yaml:"prof"`
+	Networks    []*PointNetwork `json:"networks,omitempty" yaml:"networks,omitempty"` // multiplexed over one connection.
+	Endpoints   []*Endpoint     `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+	Failback    int             `json:"failback,omitempty" yaml:"failback,omitempty"` // secs endpoint preferred is stable to fail back.
+	Bond        *Bond           `json:"bond,omitempty" yaml:"bond,omitempty"`
+	Local       string          `json:"local,omitempty" yaml:"local,omitempty"` // address or interface to dial from.
+	Proxy       string          `json:"proxy,omitempty" yaml:"proxy,omitempty"` // http, https or socks5 url to tunnel.
+	Userspace   *Userspace      `json:"userspace,omitempty" yaml:"userspace,omitempty"`
+	Forwards    []*Forward      `json:"forwards,omitempty" yaml:"forwards,omitempty"` // by stack of userspace.
+	Routing     *Routing        `json:"routing,omitempty" yaml:"routing,omitempty"`
+	Resolver    *Resolver       `json:"resolver,omitempty" yaml:"resolver,omitempty"`
+	RequestAddr bool            `json:"-" yaml:"-"`
+	Link        bool            `json:"-" yaml:"-"` // link of switch.
+	Peer        bool            `json:"-" yaml:"-"` // link of switch in mesh.
+	SaveFile    string          `json:"-" yaml:"-"`
 }
 
 var pd = Point{
@@ -36,6 +117,7 @@ var pd = Point{
 	Connection: "openlan.net",
 	Protocol:   "tls", // udp, kcp, tcp, tls, ws and wss etc.
 	Timeout:    60,
+	Failback:   60,
 	Log: Log{
 		File:    "./point.log",
 		Verbose: libol.INFO,
@@ -48,10 +130,23 @@ var pd = Point{
 	Http: &Http{
 		Listen: "0.0.0.0:10001",
 	},
+	Userspace: &Userspace{
+		Socks: "127.0.0.1:1080",
+	},
+	Routing: &Routing{
+		Table:    100,
+		Priority: 1000,
+	},
+	Resolver: &Resolver{
+		Mode: "none",
+		File: "/etc/resolv.conf",
+		Stub: "127.0.0.153:53",
+	},
 	SaveFile:    "./point.json",
 	Network:     "default",
 	RequestAddr: true,
 	Crypt:       &Crypt{},
+	Cert:        &Cert{},
 }
 
 func NewPoint() (c *Point) {
@@ -59,6 +154,7 @@ func NewPoint() (c *Point) {
 		Http:        &Http{},
 		RequestAddr: true,
 		Crypt:       &Crypt{},
+		Cert:        &Cert{},
 	}
 	flag.StringVar(&c.Alias, "alias", pd.Alias, "alias for this point")
 	flag.StringVar(&c.Network, "net", pd.Network, "Network name")
@@ -66,6 +162,7 @@ func NewPoint() (c *Point) {
 	flag.StringVar(&c.Username, "user", pd.Username, "Accessed username")
 	flag.StringVar(&c.Password, "pass", pd.Password, "Accessed password")
 	flag.StringVar(&c.Protocol, "proto", pd.Protocol, "Connection protocol")
+	flag.StringVar(&c.Proxy, "proxy", pd.Proxy, "Proxy url to tunnel, such as socks5://host:1080")
 	flag.IntVar(&c.Timeout, "timeout", pd.Timeout, "Time in secs socket dead")
 	flag.IntVar(&c.Log.Verbose, "log:level", pd.Log.Verbose, "Log level")
 	flag.StringVar(&c.Log.File, "log:file", pd.Log.File, "Log saved to file")
@@ -76,7 +173,10 @@ func NewPoint() (c *Point) {
 	flag.StringVar(&c.Http.Listen, "http:listen", pd.Http.Listen, "Http listen on")
 	flag.StringVar(&c.SaveFile, "conf", pd.SaveFile, "the configuration file")
 	flag.StringVar(&c.Crypt.Secret, "crypt:secret", pd.Crypt.Secret, "Crypt secret")
-	flag.StringVar(&c.Crypt.Algo, "crypt:algo", pd.Crypt.Algo, "Crypt algorithm")
+	flag.StringVar(&c.Crypt.Algo, "crypt:algo", pd.Crypt.Algo, "Crypt algorithm: xor, aes-128, aes-192, aes-gcm or chacha20-poly1305")
+	flag.StringVar(&c.Cert.CrtFile, "cert:crt", pd.Cert.CrtFile, "Certificate presented to switch")
+	flag.StringVar(&c.Cert.KeyFile, "cert:key", pd.Cert.KeyFile, "Private key of certificate")
+	flag.StringVar(&c.Cert.CaFile, "cert:ca", pd.Cert.CaFile, "CA to verify the switch")
 	flag.StringVar(&c.Prof, "prof", pd.Prof, "Configure file for CPU prof")
 	flag.Parse()
 
@@ -92,8 +192,21 @@ func (c *Point) Right() {
 	if c.Alias == "" {
 		c.Alias = GetAlias()
 	}
+	for _, ep := range c.Endpoints {
+		RightAddr(&ep.Connection, 10002)
+		if ep.Protocol == "" {
+			ep.Protocol = c.Protocol
+		}
+	}
+	sort.SliceStable(c.Endpoints, func(i, j int) bool {
+		return c.Endpoints[i].Priority < c.Endpoints[j].Priority
+	})
+	if len(c.Endpoints) > 0 {
+		c.Connection = c.Endpoints[0].Connection
+		c.Protocol = c.Endpoints[0].Protocol
+	}
 	RightAddr(&c.Connection, 10002)
-	if runtime.GOOS == "darwin" {
+	if runtime.GOOS == "darwin" && c.Interface.Provider != "userspace" {
 		c.Interface.Provider = "tun"
 	}
 }
@@ -110,9 +223,88 @@ func (c *Point) Default() {
 	if c.Timeout == 0 {
 		c.Timeout = pd.Timeout
 	}
+	if c.Failback == 0 {
+		c.Failback = pd.Failback
+	}
+	if c.Bond != nil {
+		if c.Bond.Mode == "" {
+			c.Bond.Mode = libol.BondHash
+		}
+		for _, path := range c.Bond.Paths {
+			if path.Connection == "" {
+				path.Connection = c.Connection
+			}
+			RightAddr(&path.Connection, 10002)
+			if path.Protocol == "" {
+				path.Protocol = c.Protocol
+			}
+		}
+	}
+	if c.Interface.Provider == "userspace" && c.Userspace == nil {
+		c.Userspace = &Userspace{Socks: pd.Userspace.Socks}
+	}
+	if c.Routing != nil {
+		if c.Routing.Table == 0 {
+			c.Routing.Table = pd.Routing.Table
+		}
+		if c.Routing.Priority == 0 {
+			c.Routing.Priority = pd.Routing.Priority
+		}
+	}
+	if c.Resolver == nil {
+		c.Resolver = &Resolver{}
+	}
+	if c.Resolver.Mode == "" {
+		c.Resolver.Mode = pd.Resolver.Mode
+	}
+	if c.Resolver.File == "" {
+		c.Resolver.File = pd.Resolver.File
+	}
+	if c.Resolver.Stub == "" {
+		c.Resolver.Stub = pd.Resolver.Stub
+	}
 	if c.Crypt != nil {
 		c.Crypt.Default()
 	}
+	if c.Cert != nil {
+		c.Cert.Right()
+	}
+}
+
+// Split returns configuration of point for each network, and only the
+// first one serves http.
+func (c *Point) Split() []*Point {
+	points := make([]*Point, 0, len(c.Networks))
+	for i, n := range c.Networks {
+		p := *c
+		p.Networks = nil
+		p.Endpoints = nil // failover is not multiplexed.
+		p.Bond = nil
+		p.Network = n.Network
+		if n.Username != "" {
+			p.Username = n.Username
+			p.Password = n.Password
+		}
+		p.Interface = n.Interface
+		if p.Interface.IfMtu == 0 {
+			p.Interface.IfMtu = c.Interface.IfMtu
+		}
+		if p.Interface.Provider == "" {
+			p.Interface.Provider = c.Interface.Provider
+		}
+		if runtime.GOOS == "darwin" && p.Interface.Provider != "userspace" {
+			p.Interface.Provider = "tun"
+		}
+		if i > 0 {
+			p.Http = nil
+			p.Userspace = nil
+			p.Forwards = nil
+			p.Routing = nil
+			p.Resolver = nil
+		}
+		points = append(points, &p)
+	}
+	return points
 }
 
 func (c *Point) Load() error {
@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"github.com/danieldin95/openlan-go/libol"
+	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Bridge struct {
@@ -14,10 +16,18 @@ type Bridge struct {
 	Provider string `json:"provider"`
 }
 
+type StaticLease struct {
+	UUID    string `json:"uuid"`
+	Address string `json:"address"`
+}
+
 type IpSubnet struct {
-	Start   string `json:"start"`
-	End     string `json:"end"`
-	Netmask string `json:"netmask"`
+	Start   string        `json:"start"`
+	End     string        `json:"end"`
+	Netmask string        `json:"netmask"`
+	Prefix6 string        `json:"prefix6,omitempty" yaml:"prefix6,omitempty"` // ipv6 prefix to allocate address by uuid.
+	Grace   int           `json:"grace,omitempty" yaml:"grace,omitempty"`     // secs to hold address after released.
+	Static  []StaticLease `json:"static,omitempty" yaml:"static,omitempty"`
 }
 
 type PrefixRoute struct {
@@ -30,6 +40,42 @@ type Password struct {
 	Password string `json:"password"`
 }
 
+// Credential is used by links to login other switches, and saved in
+// credential.json of configuration directory.
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type Auth struct {
+	Type    string `json:"type" yaml:"type"` // local or http.
+	Url     string `json:"url,omitempty" yaml:"url,omitempty"`
+	Timeout int    `json:"timeout,omitempty" yaml:"timeout,omitempty"` // secs
+}
+
+type Dhcp struct {
+	Server string   `json:"server,omitempty" yaml:"server,omitempty"` // default is address of bridge.
+	Router string   `json:"router,omitempty" yaml:"router,omitempty"` // default is server.
+	Dns    []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	Lease  int      `json:"lease,omitempty" yaml:"lease,omitempty"` // secs
+	Mtu    int      `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+}
+
+// Dns is name resolution pushed to points, and names in domains are
+// resolved by servers on the network only.
+type Dns struct {
+	Servers []string `json:"servers" yaml:"servers"`
+	Domains []string `json:"domains,omitempty" yaml:"domains,omitempty"` // search and split domains.
+}
+
+// Peer is a switch linking to this one. Routes learned from it are
+// accepted only in prefixes if any.
+type Peer struct {
+	Address  string   `json:"address" yaml:"address"`                       // host or address connecting from.
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"` // login by, any if empty.
+	Prefixes []string `json:"prefixes,omitempty" yaml:"prefixes,omitempty"` // routes accepted.
+}
+
 type Network struct {
 	Alias    string        `json:"-"`
 	Name     string        `json:"name" yaml:"name"`
@@ -38,6 +84,12 @@ type Network struct {
 	Routes   []PrefixRoute `json:"routes"`
 	Subnet   IpSubnet      `json:"subnet"`
 	Password []Password    `json:"password"`
+	Auth     *Auth         `json:"auth,omitempty" yaml:"auth,omitempty"`
+	Dhcp     *Dhcp         `json:"dhcp,omitempty" yaml:"dhcp,omitempty"`
+	Dns      *Dns          `json:"dns,omitempty" yaml:"dns,omitempty"`
+	Mesh     bool          `json:"mesh,omitempty" yaml:"mesh,omitempty"`   // links are full meshed peers with split horizon.
+	Peers    []Peer        `json:"peers,omitempty" yaml:"peers,omitempty"` // switches allowed to link to this one.
+	File     string        `json:"-" yaml:"-"`                             // saved in, empty if in switch.json.
 }
 
 func (n *Network) Right() {
@@ -50,12 +102,100 @@ func (n *Network) Right() {
 	if n.Bridge.IfMtu == 0 {
 		n.Bridge.IfMtu = 1518
 	}
+	if n.Auth == nil {
+		n.Auth = &Auth{Type: "local"}
+	}
+	if n.Auth.Timeout == 0 {
+		n.Auth.Timeout = 5
+	}
+	if n.Dhcp != nil {
+		if n.Dhcp.Server == "" && n.Bridge.Address != "" {
+			n.Dhcp.Server = strings.SplitN(n.Bridge.Address, "/", 2)[0]
+		}
+		if n.Dhcp.Router == "" {
+			n.Dhcp.Router = n.Dhcp.Server
+		}
+		if n.Dhcp.Lease == 0 {
+			n.Dhcp.Lease = 24 * 3600
+		}
+	}
+}
+
+// Save writes the network into its file, and hashes passwords before.
+func (n *Network) Save() error {
+	if n.File == "" {
+		return libol.NewErr("Network.Save: %s not in file", n.Name)
+	}
+	for i, pass := range n.Password {
+		if !libol.IsHashed(pass.Password) {
+			n.Password[i].Password = libol.HashPassword(pass.Password)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(n.File), 0755); err != nil {
+		return err
+	}
+	return libol.MarshalSave(n, n.File, true)
+}
+
+// hashPassword replaces plaintext passwords in a network decoded from
+// json, and returns true if any replaced.
+func hashPassword(n map[string]interface{}) bool {
+	values, ok := n["password"].([]interface{})
+	if !ok {
+		return false
+	}
+	changed := false
+	for _, v := range values {
+		pass, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := pass["password"].(string); ok && !libol.IsHashed(value) {
+			pass["password"] = libol.HashPassword(value)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// MigratePassword rewrites plaintext passwords in the network file or
+// in the networks of switch file by hashes.
+func MigratePassword(file string, isSwitch bool) error {
+	data := make(map[string]interface{})
+	if err := libol.UnmarshalLoad(&data, file); err != nil {
+		return err
+	}
+	changed := false
+	if isSwitch {
+		networks, _ := data["network"].([]interface{})
+		for _, v := range networks {
+			if n, ok := v.(map[string]interface{}); ok && hashPassword(n) {
+				changed = true
+			}
+		}
+	} else {
+		changed = hashPassword(data)
+	}
+	if !changed {
+		return nil
+	}
+	libol.Info("MigratePassword: %s", file)
+	return libol.MarshalSave(data, file, true)
 }
 
 type Cert struct {
-	Dir     string `json:"dir"`
-	CrtFile string `json:"crt" yaml:"crt"`
-	KeyFile string `json:"key" yaml:"key"`
+	Dir           string `json:"dir"`
+	CrtFile       string `json:"crt" yaml:"crt"`
+	KeyFile       string `json:"key" yaml:"key"`
+	CaFile        string `json:"ca,omitempty" yaml:"ca,omitempty"`
+	RequireClient bool   `json:"requireClient,omitempty" yaml:"requireClient,omitempty"`
+}
+
+func (c *Cert) Right() {
+	if c.Dir != "" {
+		c.CrtFile = fmt.Sprintf("%s/crt.pem", c.Dir)
+		c.KeyFile = fmt.Sprintf("%s/private.key", c.Dir)
+	}
 }
 
 type FlowRules struct {
@@ -71,22 +211,46 @@ type FlowRules struct {
 	Jump     string `json:"jump"` // SNAT/RETURN/MASQUERADE
 }
 
+type Lockout struct {
+	Window    int `json:"window" yaml:"window"`       // secs to count failures.
+	Threshold int `json:"threshold" yaml:"threshold"` // failures to lock.
+	Duration  int `json:"duration" yaml:"duration"`   // secs to lock.
+	Delay     int `json:"delay" yaml:"delay"`         // ms to delay first failure, and doubled.
+}
+
+func (l *Lockout) Default() {
+	if l.Window == 0 {
+		l.Window = 5 * 60
+	}
+	if l.Threshold == 0 {
+		l.Threshold = 5
+	}
+	if l.Duration == 0 {
+		l.Duration = 15 * 60
+	}
+	if l.Delay == 0 {
+		l.Delay = 500
+	}
+}
+
 type Switch struct {
-	Alias     string      `json:"alias"`
-	Protocol  string      `json:"protocol"` // tcp/tls, udp/kcp.
-	Listen    string      `json:"listen"`
-	Timeout   int         `json:"timeout"`
-	Http      *Http       `json:"http,omitempty" yaml:"http,omitempty"`
-	Log       Log         `json:"log" yaml:"log"`
-	Cert      Cert        `json:"cert"`
-	Crypt     *Crypt      `json:"crypt"`
-	Prof      string      `json:"prof"`
-	Network   []*Network  `json:"network"`
-	FireWall  []FlowRules `json:"firewall"`
-	Inspect   string      `json:"inspect"`
-	ConfDir   string      `json:"-" yaml:"-"`
-	TokenFile string      `json:"-" yaml:"-"`
-	SaveFile  string      `json:"-" yaml:"-"`
+	Alias       string                `json:"alias"`
+	Protocol    string                `json:"protocol"` // tcp/tls, udp/kcp, ws/wss.
+	Listen      string                `json:"listen"`
+	Timeout     int                   `json:"timeout"`
+	Http        *Http                 `json:"http,omitempty" yaml:"http,omitempty"`
+	Log         Log                   `json:"log" yaml:"log"`
+	Cert        Cert                  `json:"cert"`
+	Crypt       *Crypt                `json:"crypt"`
+	Prof        string                `json:"prof"`
+	Network     []*Network            `json:"network"`
+	FireWall    []FlowRules           `json:"firewall"`
+	Inspect     string                `json:"inspect"`
+	Lockout     *Lockout              `json:"lockout,omitempty" yaml:"lockout,omitempty"`
+	ConfDir     string                `json:"-" yaml:"-"`
+	Credentials map[string]Credential `json:"-" yaml:"-"`
+	TokenFile   string                `json:"-" yaml:"-"`
+	SaveFile    string                `json:"-" yaml:"-"`
 }
 
 var sd = Switch{
@@ -128,10 +292,7 @@ func (c *Switch) Right() {
 	}
 	c.TokenFile = fmt.Sprintf("%s/token", c.ConfDir)
 	c.SaveFile = fmt.Sprintf("%s/switch.json", c.ConfDir)
-	if c.Cert.Dir != "" {
-		c.Cert.CrtFile = fmt.Sprintf("%s/crt.pem", c.Cert.Dir)
-		c.Cert.KeyFile = fmt.Sprintf("%s/private.key", c.Cert.Dir)
-	}
+	c.Cert.Right()
 }
 
 func (c *Switch) Default() {
@@ -145,13 +306,28 @@ func (c *Switch) Default() {
 	if c.Crypt != nil {
 		c.Crypt.Default()
 	}
+	if c.Lockout == nil {
+		c.Lockout = &Lockout{}
+	}
+	c.Lockout.Default()
+	c.Credentials = make(map[string]Credential, 32)
+	file := c.ConfDir + "/credential.json"
+	if err := libol.FileExist(file); err == nil {
+		if err := libol.UnmarshalLoad(&c.Credentials, file); err != nil {
+			libol.Error("Switch.Default %s", err)
+		}
+	}
 	files, err := filepath.Glob(c.ConfDir + "/network/*.json")
 	if err != nil {
 		libol.Error("Switch.Default %s", err)
 	}
 	for _, k := range files {
+		if err := MigratePassword(k, false); err != nil {
+			libol.Warn("Switch.Default %s", err)
+		}
 		n := &Network{
 			Alias: c.Alias,
+			File:  k,
 		}
 		if err := libol.UnmarshalLoad(n, k); err != nil {
 			libol.Error("Switch.Default %s", err)
@@ -169,9 +345,31 @@ func (c *Switch) Default() {
 }
 
 func (c *Switch) Load() error {
+	if err := libol.FileExist(c.SaveFile); err == nil {
+		if err := MigratePassword(c.SaveFile, true); err != nil {
+			libol.Warn("Switch.Load %s", err)
+		}
+	}
 	return libol.UnmarshalLoad(c, c.SaveFile)
 }
 
+// Reload reads configuration again from directory of current one.
+func (c *Switch) Reload() (Switch, error) {
+	n := Switch{
+		ConfDir:  c.ConfDir,
+		SaveFile: c.SaveFile,
+		Log:      c.Log,
+		Prof:     c.Prof,
+	}
+	if err := libol.FileExist(n.SaveFile); err == nil {
+		if err := n.Load(); err != nil {
+			return n, err
+		}
+	}
+	n.Default()
+	return n, nil
+}
+
 func init() {
 	sd.Right()
 }
@@ -10,9 +10,13 @@ import (
 
 func main() {
 	c := config.NewPoint()
-	p := point.NewPoint(c)
-	p.Initialize()
-	p.Start()
+	points := point.NewPoints(c)
+	for _, p := range points {
+		p.Initialize()
+		p.Start()
+	}
 	libol.Wait()
-	p.Stop()
+	for _, p := range points {
+		p.Stop()
+	}
 }
@@ -10,16 +10,20 @@ import (
 
 func main() {
 	c := config.NewPoint()
-	p := point.NewPoint(c)
+	points := point.NewPoints(c)
 	if c.Prof != "" {
 		f := libol.Prof{File: c.Prof}
 		f.Start()
 		defer f.Stop()
 	}
 	libol.PreNotify()
-	p.Initialize()
-	p.Start()
+	for _, p := range points {
+		p.Initialize()
+		p.Start()
+	}
 	libol.SdNotify()
 	libol.Wait()
-	p.Stop()
+	for _, p := range points {
+		p.Stop()
+	}
 }
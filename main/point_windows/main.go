@@ -11,9 +11,11 @@ import (
 
 func main() {
 	c := config.NewPoint()
-	p := point.NewPoint(c)
-	p.Initialize()
-	p.Start()
+	points := point.NewPoints(c)
+	for _, p := range points {
+		p.Initialize()
+		p.Start()
+	}
 	go func() {
 		for {
 			input := ""
@@ -22,13 +24,17 @@ func main() {
 				break
 			}
 			if input == "s" || input == "state" {
-				fmt.Printf("UUID  : %s\n", p.UUID())
-				fmt.Printf("State : %s\n", p.State())
-				fmt.Printf("Uptime: %d\n", p.UpTime())
-				fmt.Printf("Device: %s\n", p.IfName())
+				for _, p := range points {
+					fmt.Printf("UUID  : %s\n", p.UUID())
+					fmt.Printf("State : %s\n", p.State())
+					fmt.Printf("Uptime: %d\n", p.UpTime())
+					fmt.Printf("Device: %s\n", p.IfName())
+				}
 			}
 		}
 	}()
 	libol.Wait()
-	p.Stop()
+	for _, p := range points {
+		p.Stop()
+	}
 }
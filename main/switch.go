@@ -18,6 +18,10 @@ func main() {
 	s.Initialize()
 	s.Start()
 	libol.SdNotify()
+	libol.OnHangup(func() {
+		ret := s.Reload()
+		libol.Info("main: reload %v", ret)
+	})
 	libol.Wait()
 	s.Stop()
 }
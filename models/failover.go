@@ -0,0 +1,18 @@
+package models
+
+// Endpoint is a switch which point connects to.
+type Endpoint struct {
+	Connection string `json:"connection"`
+	Protocol   string `json:"protocol"`
+	Priority   int    `json:"priority"`
+	Active     bool   `json:"active"`
+	Stable     int64  `json:"stable,omitempty"` // secs reachable before failing back.
+}
+
+// Failover records point switched from one endpoint to another.
+type Failover struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"` // failed, dead or failback.
+	Time   int64  `json:"time"`
+}
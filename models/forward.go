@@ -0,0 +1,11 @@
+package models
+
+// Forward is a rule forwarding connections between local and the network.
+type Forward struct {
+	Id      string `json:"id"`
+	Local   string `json:"local"`
+	Remote  string `json:"remote"`
+	Reverse bool   `json:"reverse"`
+	Active  int    `json:"active"` // connections forwarding.
+	Total   uint64 `json:"total"`
+}
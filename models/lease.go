@@ -0,0 +1,31 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type Lease struct {
+	UUID     string `json:"uuid"`
+	Network  string `json:"network"`
+	Address  string `json:"address"`
+	Address6 string `json:"address6,omitempty"`
+	Static   bool   `json:"static,omitempty"`
+	Expire   int64  `json:"expire,omitempty"` // unix time, and zero is bound.
+}
+
+func NewLease(uuid, network, address string) *Lease {
+	return &Lease{
+		UUID:    uuid,
+		Network: network,
+		Address: address,
+	}
+}
+
+func (l *Lease) String() string {
+	return fmt.Sprintf("%s, %s, %s, %d", l.UUID, l.Network, l.Address, l.Expire)
+}
+
+func (l *Lease) Expired() bool {
+	return !l.Static && l.Expire != 0 && l.Expire < time.Now().Unix()
+}
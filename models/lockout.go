@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+type Lockout struct {
+	Type     string  // ip or user.
+	Source   string  // remote address or username.
+	Failures []int64 // unix time of failures in window.
+	Until    int64   // unix time locked until.
+	Window   int64   // secs failures are counted in.
+}
+
+func NewLockout(t, source string) *Lockout {
+	return &Lockout{
+		Type:     t,
+		Source:   source,
+		Failures: make([]int64, 0, 8),
+	}
+}
+
+func (l *Lockout) String() string {
+	return l.Type + ":" + l.Source
+}
+
+// Remain returns secs of lockout remaining.
+func (l *Lockout) Remain() int64 {
+	if remain := l.Until - time.Now().Unix(); remain > 0 {
+		return remain
+	}
+	return 0
+}
+
+// Expired returns true if not locked, and no failure is in window.
+func (l *Lockout) Expired() bool {
+	if l.Remain() > 0 {
+		return false
+	}
+	if n := len(l.Failures); n > 0 && time.Now().Unix()-l.Failures[n-1] < l.Window {
+		return false
+	}
+	return true
+}
+
+// Last returns unix time of the latest failure.
+func (l *Lockout) Last() int64 {
+	if n := len(l.Failures); n > 0 {
+		return l.Failures[n-1]
+	}
+	return 0
+}
+
+// Fail records a failure, and drops failures older than window.
+func (l *Lockout) Fail(window int64) int {
+	l.Window = window
+	now := time.Now().Unix()
+	failures := l.Failures[:0]
+	for _, t := range l.Failures {
+		if now-t < window {
+			failures = append(failures, t)
+		}
+	}
+	l.Failures = append(failures, now)
+	return len(l.Failures)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"strings"
@@ -23,6 +24,19 @@ func (u *Route) String() string {
 	return fmt.Sprintf("%s, %s", u.Prefix, u.NextHop)
 }
 
+// PeerRoute is the route advertised by the switch linked.
+type PeerRoute struct {
+	Network string `json:"network"`
+	Prefix  string `json:"prefix"`
+	NextHop string `json:"nexthop"`
+	Origin  string `json:"origin"` // connection of link or address of point.
+	NewTime int64  `json:"newTime"`
+}
+
+func (u *PeerRoute) String() string {
+	return fmt.Sprintf("%s, %s, %s, %s", u.Network, u.Prefix, u.NextHop, u.Origin)
+}
+
 type Network struct {
 	Name    string   `json:"name"`
 	Tenant  string   `json:"tenant,omitempty"`
@@ -30,7 +44,12 @@ type Network struct {
 	IpStart string   `json:"ipStart"`
 	IpEnd   string   `json:"ipEnd"`
 	Netmask string   `json:"netmask"`
+	IfAddr6 string   `json:"ifAddr6,omitempty"`
+	Prefix6 string   `json:"prefix6,omitempty"` // ipv6 prefix of network.
 	Routes  []*Route `json:"routes"`
+	Dns     []string `json:"dns,omitempty"`
+	Domains []string `json:"domains,omitempty"` // search and split domains of dns.
+	Grace   int64    `json:"-"`                 // secs to hold address after released.
 }
 
 func NewNetwork(name string, ifAddr string) (this *Network) {
@@ -61,3 +80,29 @@ func (u *Network) String() string {
 
 func (u *Network) ParseIP(s string) {
 }
+
+// Addr6 returns ipv6 address with prefix length, and empty if not assigned.
+func (u *Network) Addr6() string {
+	if u.IfAddr6 == "" {
+		return ""
+	}
+	prefix := 64
+	if _, n, err := net.ParseCIDR(u.Prefix6); err == nil {
+		prefix, _ = n.Mask.Size()
+	}
+	return fmt.Sprintf("%s/%d", u.IfAddr6, prefix)
+}
+
+// Size returns number of addresses between start and end.
+func (u *Network) Size() int {
+	start := net.ParseIP(u.IpStart).To4()
+	end := net.ParseIP(u.IpEnd).To4()
+	if start == nil || end == nil {
+		return 0
+	}
+	s, e := binary.BigEndian.Uint32(start), binary.BigEndian.Uint32(end)
+	if e < s {
+		return 0
+	}
+	return int(e-s) + 1
+}
@@ -13,8 +13,12 @@ type Point struct {
 	Uptime  int64              `json:"uptime"`
 	Status  string             `json:"status"`
 	IfName  string             `json:"ifName"`
+	Link    bool               `json:"link"`
+	Peer    bool               `json:"peer"`
 	Client  libol.SocketClient `json:"-"`
 	Device  network.Taper      `json:"-"`
+	Bond    *libol.Bond        `json:"-"` // shared by paths with the same session.
+	Session string             `json:"-"`
 }
 
 func NewPoint(c libol.SocketClient, d network.Taper) (w *Point) {
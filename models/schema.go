@@ -4,6 +4,7 @@ import (
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/switch/schema"
 	"strings"
+	"time"
 )
 
 func NewPointSchema(p *Point) schema.Point {
@@ -13,6 +14,7 @@ func NewPointSchema(p *Point) schema.Point {
 		UUID:    p.UUID,
 		Alias:   p.Alias,
 		Address: client.Addr(),
+		Switch:  client.LocalAddr(),
 		Device:  dev.Name(),
 		RxBytes: client.Sts().RecvOkay,
 		TxBytes: client.Sts().SendOkay,
@@ -31,6 +33,9 @@ func NewLinkSchema(p *Point) schema.Link {
 		Address: client.Addr(),
 		State:   client.State(),
 		IpAddr:  strings.Split(client.Addr(), ":")[0],
+		RxBytes: client.Sts().RecvOkay,
+		TxBytes: client.Sts().SendOkay,
+		ErrPkt:  client.Sts().SendError,
 		Network: p.Network,
 	}
 }
@@ -44,6 +49,16 @@ func NewNeighborSchema(n *Neighbor) schema.Neighbor {
 	}
 }
 
+func NewRouteSchema(r *PeerRoute) schema.Route {
+	return schema.Route{
+		Network: r.Network,
+		Prefix:  r.Prefix,
+		NextHop: r.NextHop,
+		Origin:  r.Origin,
+		Uptime:  time.Now().Unix() - r.NewTime,
+	}
+}
+
 func NewOnLineSchema(l *Line) schema.OnLine {
 	return schema.OnLine{
 		HitTime:    l.LastTime(),
@@ -59,10 +74,9 @@ func NewOnLineSchema(l *Line) schema.OnLine {
 
 func NewUserSchema(u *User) schema.User {
 	return schema.User{
-		Name:     u.Name,
-		Password: u.Password,
-		Token:    u.Token,
-		Alias:    u.Alias,
+		Name:  u.Name,
+		Token: u.Token,
+		Alias: u.Alias,
 	}
 }
 
@@ -81,7 +95,10 @@ func NewNetworkSchema(n *Network) schema.Network {
 		IpStart: n.IpStart,
 		IpEnd:   n.IpEnd,
 		Netmask: n.Netmask,
+		Prefix6: n.Prefix6,
 		Routes:  make([]schema.PrefixRoute, 0, 32),
+		Dns:     n.Dns,
+		Domains: n.Domains,
 	}
 	for _, route := range n.Routes {
 		sn.Routes = append(sn.Routes,
@@ -92,3 +109,16 @@ func NewNetworkSchema(n *Network) schema.Network {
 	}
 	return sn
 }
+
+func NewLockoutSchema(l *Lockout) schema.Lockout {
+	sl := schema.Lockout{
+		Type:     l.Type,
+		Source:   l.Source,
+		Failures: len(l.Failures),
+		Remain:   l.Remain(),
+	}
+	if len(l.Failures) > 0 {
+		sl.LastTime = l.Failures[len(l.Failures)-1]
+	}
+	return sl
+}
@@ -11,6 +11,10 @@ type User struct {
 	Token    string `json:"token"`
 	Password string `json:"password"`
 	UUID     string `json:"uuid"`
+	Link     bool   `json:"link,omitempty"`    // login by link of switch.
+	Peer     bool   `json:"peer,omitempty"`    // login by link of switch in mesh.
+	Bond     string `json:"bond,omitempty"`    // mode of bond if login by its path.
+	Session  string `json:"session,omitempty"` // paths of the same bond have the same session.
 }
 
 func NewUser(name string, password string) (this *User) {
@@ -22,5 +26,5 @@ func NewUser(name string, password string) (this *User) {
 }
 
 func (u *User) String() string {
-	return fmt.Sprintf("%s, %s, %s, %s", u.UUID, u.Name, u.Password, u.Token)
+	return fmt.Sprintf("%s, %s, %s", u.UUID, u.Name, u.Alias)
 }
@@ -3,6 +3,7 @@ package network
 import (
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/vishvananda/netlink"
+	"syscall"
 )
 
 type LinuxBridge struct {
@@ -10,12 +11,14 @@ type LinuxBridge struct {
 	ifMtu   int
 	name    string
 	device  netlink.Link
+	stp     bool
 }
 
 func NewLinuxBridge(name string, mtu int) *LinuxBridge {
 	b := &LinuxBridge{
 		name:  name,
 		ifMtu: mtu,
+		stp:   true,
 	}
 	return b
 }
@@ -44,7 +47,7 @@ func (b *LinuxBridge) Open(addr string) {
 	}
 
 	brCtl := libol.NewBrCtl(b.name)
-	if err := brCtl.Stp(true); err != nil {
+	if err := brCtl.Stp(b.stp); err != nil {
 		libol.Error("LinuxBridge.newBr.Stp: %s", err)
 	}
 	if err = netlink.LinkSetUp(link); err != nil {
@@ -144,6 +147,50 @@ func (b *LinuxBridge) SetTimeout(value int) {
 	//TODO
 }
 
+func (b *LinuxBridge) SetStp(on bool) {
+	b.stp = on
+}
+
+func (b *LinuxBridge) SetPeer(name string) error {
+	brCtl := libol.NewBrCtl(b.name)
+	if err := brCtl.Isolate(name, true); err != nil {
+		libol.Error("LinuxBridge.SetPeer: %s %s", name, err)
+		return err
+	}
+	libol.Info("LinuxBridge.SetPeer: %s %s", name, b.name)
+	return nil
+}
+
 func (b *LinuxBridge) Mtu() int {
 	return b.ifMtu
 }
+
+func (b *LinuxBridge) MacSize() int {
+	if b.device == nil {
+		return 0
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		libol.Error("LinuxBridge.MacSize: %s", err)
+		return 0
+	}
+	ports := make(map[int]bool, 32)
+	for _, link := range links {
+		if link.Attrs().MasterIndex == b.device.Attrs().Index {
+			ports[link.Attrs().Index] = true
+		}
+	}
+	neighs, err := netlink.NeighList(0, syscall.AF_BRIDGE)
+	if err != nil {
+		libol.Error("LinuxBridge.MacSize: %s", err)
+		return 0
+	}
+	size := 0
+	for _, n := range neighs {
+		// permanent entries are addresses of ports self.
+		if ports[n.LinkIndex] && n.State&netlink.NUD_PERMANENT == 0 {
+			size++
+		}
+	}
+	return size
+}
@@ -20,6 +20,7 @@ type VirtualBridge struct {
 	lock     sync.RWMutex
 	devices  map[string]Taper
 	learners map[string]*Learner
+	peers    map[string]bool
 	done     chan bool
 	ticker   *time.Ticker
 	timeout  int
@@ -33,6 +34,7 @@ func NewVirtualBridge(name string, mtu int) *VirtualBridge {
 		ifMtu:    mtu,
 		devices:  make(map[string]Taper, 1024),
 		learners: make(map[string]*Learner, 1024),
+		peers:    make(map[string]bool, 32),
 		done:     make(chan bool),
 		ticker:   time.NewTicker(5 * time.Second),
 		timeout:  5 * 60,
@@ -95,6 +97,7 @@ func (b *VirtualBridge) DelSlave(dev Taper) error {
 	if _, ok := b.devices[dev.Name()]; ok {
 		delete(b.devices, dev.Name())
 	}
+	delete(b.peers, dev.Name())
 
 	libol.Info("VirtualBridge.DelSlave: %s %s", dev.Name(), b.name)
 
@@ -117,6 +120,29 @@ func (b *VirtualBridge) SetTimeout(value int) {
 	b.timeout = value
 }
 
+func (b *VirtualBridge) SetStp(on bool) {
+	// not support stp.
+}
+
+func (b *VirtualBridge) SetPeer(name string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.peers[name] = true
+
+	libol.Info("VirtualBridge.SetPeer: %s %s", name, b.name)
+
+	return nil
+}
+
+func (b *VirtualBridge) IsPeer(dev Taper) bool {
+	if dev == nil {
+		return false
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.peers[dev.Name()]
+}
+
 func (b *VirtualBridge) Forward(m *Framer) error {
 	if is := b.Unicast(m); !is {
 		_ = b.Flood(m)
@@ -244,10 +270,15 @@ func (b *VirtualBridge) Flood(m *Framer) error {
 	data := m.Data
 	src := m.Source
 	libol.Debug("VirtualBridge.Flood: % x", data[:20])
+	fromPeer := b.IsPeer(src)
 	for _, dst := range b.devices {
 		if src == dst {
 			continue
 		}
+		// split horizon: peer floods to its local ports self.
+		if fromPeer && b.IsPeer(dst) {
+			continue
+		}
 		_, err = dst.InRead(data)
 	}
 	return err
@@ -260,7 +291,7 @@ func (b *VirtualBridge) Unicast(m *Framer) bool {
 
 	if l := b.FindDest(index); l != nil {
 		dst := l.Device
-		if dst != src {
+		if dst != src && !(b.IsPeer(src) && b.IsPeer(dst)) {
 			if _, err := dst.InRead(data); err != nil {
 				libol.Debug("VirtualBridge.Unicast: %s %s", dst, err)
 			}
@@ -275,3 +306,9 @@ func (b *VirtualBridge) Unicast(m *Framer) bool {
 func (b *VirtualBridge) Mtu() int {
 	return b.ifMtu
 }
+
+func (b *VirtualBridge) MacSize() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return len(b.learners)
+}
@@ -11,4 +11,7 @@ type Bridger interface {
 	Input(m *Framer) error
 	SetTimeout(value int)
 	Mtu() int
+	MacSize() int // number of learned mac addresses.
+	SetStp(on bool)
+	SetPeer(name string) error // not forward frames between peers.
 }
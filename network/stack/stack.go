@@ -0,0 +1,402 @@
+package stack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/network"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	neighborTimeout = 300 // secs an arp entry is used.
+	maxPending      = 16  // frames wait for arp reply.
+)
+
+type neighbor struct {
+	hwAddr  []byte
+	update  int64
+	request int64 // last time of arp request.
+	pending [][]byte
+}
+
+type connKey struct {
+	local  uint16
+	remote [4]byte
+	port   uint16
+}
+
+// Stack is a small tcp/ip stack over an userspace device. It has its own
+// hardware address, answers arp and ping to its address, and provides
+// tcp connections to hosts on the network.
+type Stack struct {
+	lock      sync.Mutex
+	name      string
+	mtu       int
+	device    network.Taper
+	hwAddr    []byte
+	ipAddr    net.IP
+	ipNet     *net.IPNet
+	findNext  func(dest []byte) []byte
+	neighbors map[string]*neighbor
+	conns     map[connKey]*Conn
+	listens   map[uint16]*Listener
+	ident     uint16
+}
+
+// NewStack returns a stack, and mtu is size of ip packet.
+func NewStack(name string, mtu int) *Stack {
+	if mtu <= 0 {
+		mtu = 1500
+	}
+	return &Stack{
+		name:      name,
+		mtu:       mtu,
+		hwAddr:    libol.GenEthAddr(6),
+		neighbors: make(map[string]*neighbor, 32),
+		conns:     make(map[connKey]*Conn, 1024),
+		listens:   make(map[uint16]*Listener, 32),
+		ident:     uint16(rand.Uint32()),
+	}
+}
+
+func (s *Stack) String() string {
+	return s.name
+}
+
+func (s *Stack) Type() string {
+	return "stack"
+}
+
+func (s *Stack) Name() string {
+	return s.name
+}
+
+func (s *Stack) SetName(value string) {
+	s.name = value
+}
+
+func (s *Stack) Open(addr string) {
+	if addr != "" {
+		_ = s.SetAddr(addr)
+	}
+}
+
+// Close resets all connections and listeners.
+func (s *Stack) Close() error {
+	s.lock.Lock()
+	conns := make([]*Conn, 0, len(s.conns))
+	for _, c := range s.conns {
+		conns = append(conns, c)
+	}
+	listens := make([]*Listener, 0, len(s.listens))
+	for _, l := range s.listens {
+		listens = append(listens, l)
+	}
+	s.lock.Unlock()
+
+	for _, l := range listens {
+		_ = l.Close()
+	}
+	for _, c := range conns {
+		c.abort()
+	}
+	return nil
+}
+
+func (s *Stack) AddSlave(dev network.Taper) error {
+	dev.Slave(s)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.device = dev
+	libol.Info("Stack.AddSlave: %s %s", dev.Name(), s.name)
+	return nil
+}
+
+func (s *Stack) DelSlave(dev network.Taper) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.device == dev {
+		s.device = nil
+	}
+	libol.Info("Stack.DelSlave: %s %s", dev.Name(), s.name)
+	return nil
+}
+
+func (s *Stack) SetTimeout(value int) {
+}
+
+func (s *Stack) Mtu() int {
+	return s.mtu
+}
+
+func (s *Stack) MacSize() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.neighbors)
+}
+
+func (s *Stack) SetStp(on bool) {
+}
+
+func (s *Stack) SetPeer(name string) error {
+	return nil
+}
+
+// SetFindNext sets the function to find next hop by routes.
+func (s *Stack) SetFindNext(call func(dest []byte) []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.findNext = call
+}
+
+func (s *Stack) HwAddr() net.HardwareAddr {
+	return s.hwAddr
+}
+
+// Addr returns ipv4 address with prefix, and empty if not configured.
+func (s *Stack) Addr() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ipNet == nil {
+		return ""
+	}
+	prefix, _ := s.ipNet.Mask.Size()
+	return fmt.Sprintf("%s/%d", s.ipAddr, prefix)
+}
+
+// SetAddr configures address such as 192.168.1.2/24, and ipv6 is ignored.
+func (s *Stack) SetAddr(ipStr string) error {
+	ip, ipNet, err := net.ParseCIDR(ipStr)
+	if err != nil {
+		return err
+	}
+	if ip.To4() == nil {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ipAddr = ip.To4()
+	s.ipNet = ipNet
+	libol.Info("Stack.SetAddr: %s %s", s.name, ipStr)
+	return nil
+}
+
+// DelAddr removes address, and connections are kept to resume if the
+// same address added again.
+func (s *Stack) DelAddr(ipStr string) error {
+	ip, _, err := net.ParseCIDR(ipStr)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ipAddr != nil && s.ipAddr.Equal(ip) {
+		s.ipAddr = nil
+		s.ipNet = nil
+		s.neighbors = make(map[string]*neighbor, 32)
+		libol.Info("Stack.DelAddr: %s %s", s.name, ipStr)
+	}
+	return nil
+}
+
+// Input processes frames written to the device.
+func (s *Stack) Input(m *network.Framer) error {
+	data := m.Data
+	proto := libol.NewFrameProto(data)
+	if err := proto.Decode(); err != nil {
+		return nil
+	}
+	eth := proto.Eth
+	if !equal(eth.Dst, s.hwAddr) && !equal(eth.Dst, libol.BROADED) || proto.Vlan != nil {
+		return nil
+	}
+	if proto.Arp != nil {
+		s.onArp(proto.Arp)
+		return nil
+	}
+	iph := proto.Ip4
+	if iph == nil {
+		return nil
+	}
+	hdrLen := int(iph.HeaderLen) * 4
+	total := int(iph.TotalLen)
+	if hdrLen < libol.Ipv4Len || total < hdrLen || eth.Len+total > len(data) {
+		return nil
+	}
+	// fragments are not reassembled.
+	if iph.Offset != 0 || iph.Flag&0x01 != 0 {
+		return nil
+	}
+	payload := data[eth.Len+hdrLen : eth.Len+total]
+	s.lock.Lock()
+	if s.ipAddr == nil || !s.ipAddr.Equal(iph.Destination) {
+		s.lock.Unlock()
+		return nil
+	}
+	if s.ipNet.Contains(iph.Source) {
+		s.learn(iph.Source, eth.Src)
+	}
+	s.lock.Unlock()
+	switch iph.Protocol {
+	case libol.IpIcmp:
+		s.onIcmp(iph, payload)
+	case libol.IpTcp:
+		s.onTcp(iph, payload)
+	}
+	return nil
+}
+
+// learn updates hardware address of neighbor, and sends frames waiting.
+func (s *Stack) learn(ip, hwAddr []byte) {
+	key := net.IP(ip).String()
+	neb, ok := s.neighbors[key]
+	if !ok {
+		neb = &neighbor{}
+		s.neighbors[key] = neb
+	}
+	neb.hwAddr = append([]byte(nil), hwAddr...)
+	neb.update = time.Now().Unix()
+	for _, frame := range neb.pending {
+		copy(frame[:6], neb.hwAddr)
+		s.write(frame)
+	}
+	neb.pending = nil
+}
+
+func (s *Stack) onArp(arp *libol.Arp) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !arp.IsIP4() || s.ipAddr == nil {
+		return
+	}
+	if s.ipNet.Contains(arp.SIpAddr) {
+		if _, ok := s.neighbors[net.IP(arp.SIpAddr).String()]; ok || equal(arp.TIpAddr, s.ipAddr) {
+			s.learn(arp.SIpAddr, arp.SHwAddr)
+		}
+	}
+	if arp.OpCode != libol.ArpRequest || !equal(arp.TIpAddr, s.ipAddr) {
+		return
+	}
+	eth := libol.NewEther(libol.EthArp)
+	eth.Dst = arp.SHwAddr
+	eth.Src = s.hwAddr
+	reply := libol.NewArp()
+	reply.OpCode = libol.ArpReply
+	reply.SIpAddr = s.ipAddr
+	reply.SHwAddr = s.hwAddr
+	reply.TIpAddr = arp.SIpAddr
+	reply.THwAddr = arp.SHwAddr
+	s.write(append(eth.Encode(), reply.Encode()...))
+}
+
+// request sends arp request for address of next hop.
+func (s *Stack) request(dest []byte) {
+	eth := libol.NewEther(libol.EthArp)
+	eth.Dst = libol.BROADED
+	eth.Src = s.hwAddr
+	req := libol.NewArp()
+	req.OpCode = libol.ArpRequest
+	req.SIpAddr = s.ipAddr
+	req.SHwAddr = s.hwAddr
+	req.TIpAddr = dest
+	req.THwAddr = libol.ZEROED
+	s.write(append(eth.Encode(), req.Encode()...))
+}
+
+func (s *Stack) onIcmp(iph *libol.Ipv4, payload []byte) {
+	icmp, err := libol.NewIcmpFromFrame(payload)
+	if err != nil || icmp.Type != libol.IcmpEchoRequest {
+		return
+	}
+	reply := make([]byte, len(payload))
+	copy(reply, payload)
+	reply[0] = libol.IcmpEchoReply
+	binary.BigEndian.PutUint16(reply[2:4], 0)
+	binary.BigEndian.PutUint16(reply[2:4], libol.IpChecksum(reply))
+	_ = s.send(iph.Source, libol.IpIcmp, reply)
+}
+
+// nextHop returns address of next hop to dest, and nil if no route.
+func (s *Stack) nextHop(dest net.IP) net.IP {
+	if s.ipNet == nil {
+		return nil
+	}
+	if s.ipNet.Contains(dest) {
+		return dest
+	}
+	if s.findNext != nil {
+		if nxt := net.IP(s.findNext(dest)); nxt != nil && !nxt.Equal(dest) && s.ipNet.Contains(nxt) {
+			return nxt.To4()
+		}
+	}
+	return nil
+}
+
+// send encapsulates payload by ip and ethernet, and frame waits for arp
+// reply if hardware address of next hop is unknown.
+func (s *Stack) send(dest net.IP, proto uint8, payload []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	nxt := s.nextHop(dest)
+	if nxt == nil {
+		return syscall.ENETUNREACH
+	}
+	iph := libol.NewIpv4()
+	iph.TotalLen = uint16(libol.Ipv4Len + len(payload))
+	iph.Identifier = s.ident
+	iph.Flag = 0x02 // don't fragment.
+	iph.ToL = 64
+	iph.Protocol = proto
+	iph.Source = s.ipAddr
+	iph.Destination = dest.To4()
+	s.ident++
+	header := iph.Encode()
+	binary.BigEndian.PutUint16(header[10:12], libol.IpChecksum(header))
+
+	eth := libol.NewEther(libol.EthIp4)
+	eth.Src = s.hwAddr
+	frame := make([]byte, 0, libol.EtherLen+len(header)+len(payload))
+	frame = append(frame, eth.Encode()...)
+	frame = append(frame, header...)
+	frame = append(frame, payload...)
+
+	now := time.Now().Unix()
+	key := nxt.String()
+	neb, ok := s.neighbors[key]
+	if ok && neb.hwAddr != nil && now-neb.update < neighborTimeout {
+		copy(frame[:6], neb.hwAddr)
+		s.write(frame)
+		return nil
+	}
+	if !ok {
+		neb = &neighbor{}
+		s.neighbors[key] = neb
+	}
+	if len(neb.pending) < maxPending {
+		neb.pending = append(neb.pending, frame)
+	}
+	if neb.request != now {
+		neb.request = now
+		s.request(nxt)
+	}
+	return nil
+}
+
+func (s *Stack) write(frame []byte) {
+	if s.device == nil {
+		return
+	}
+	if _, err := s.device.InRead(frame); err != nil {
+		libol.Warn("Stack.write: %s %s", s.name, err)
+	}
+}
+
+func equal(a, b []byte) bool {
+	return string(a) == string(b)
+}
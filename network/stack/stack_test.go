@@ -0,0 +1,211 @@
+package stack
+
+import (
+	"bytes"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestStack(name, addr string) (*Stack, network.Taper) {
+	s := NewStack(name, 1500)
+	_ = s.SetAddr(addr)
+	tap, _ := network.NewUserSpaceTap("test", network.TapConfig{Type: network.TAP})
+	tap.Up()
+	_ = s.AddSlave(tap)
+	return s, tap
+}
+
+// wire forwards frames read from a device to another, and frames are
+// dropped if drop returns true.
+func wire(from, to network.Taper, drop func() bool) {
+	for {
+		buf := make([]byte, 1600)
+		n, err := from.Read(buf)
+		if err != nil {
+			return
+		}
+		if drop != nil && drop() {
+			continue
+		}
+		_, _ = to.Write(buf[:n])
+	}
+}
+
+func readFrame(tap network.Taper) *libol.FrameProto {
+	frames := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 1600)
+		n, _ := tap.Read(buf)
+		frames <- buf[:n]
+	}()
+	select {
+	case data := <-frames:
+		proto := libol.NewFrameProto(data)
+		_ = proto.Decode()
+		return proto
+	case <-time.After(2 * time.Second):
+		return nil
+	}
+}
+
+func TestStackArpPing(t *testing.T) {
+	s, tap := newTestStack("arp", "192.168.1.2/24")
+	defer tap.Close()
+	hwAddr := []byte{0x0a, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	eth := libol.NewEther(libol.EthArp)
+	eth.Dst = libol.BROADED
+	eth.Src = hwAddr
+	arp := libol.NewArp()
+	arp.SHwAddr = hwAddr
+	arp.SIpAddr = net.ParseIP("192.168.1.1").To4()
+	arp.TIpAddr = net.ParseIP("192.168.1.2").To4()
+	_, _ = tap.Write(append(eth.Encode(), arp.Encode()...))
+	proto := readFrame(tap)
+	assert.NotNil(t, proto, "replied.")
+	if proto == nil || proto.Arp == nil {
+		return
+	}
+	assert.Equal(t, uint16(libol.ArpReply), proto.Arp.OpCode, "be the same.")
+	assert.Equal(t, []byte(s.HwAddr()), proto.Arp.SHwAddr, "be the same.")
+	assert.Equal(t, hwAddr, proto.Eth.Dst, "be the same.")
+	assert.Equal(t, 1, s.MacSize(), "learned.")
+
+	// echo request.
+	eth = libol.NewEther(libol.EthIp4)
+	eth.Dst = s.HwAddr()
+	eth.Src = hwAddr
+	icmp := libol.NewIcmp()
+	icmp.Type = libol.IcmpEchoRequest
+	icmp.Rest = 0x12340001
+	payload := append(icmp.Encode(), []byte("ping")...)
+	payload[2], payload[3] = 0, 0
+	sum := libol.IpChecksum(payload)
+	payload[2], payload[3] = byte(sum>>8), byte(sum)
+	iph := libol.NewIpv4()
+	iph.TotalLen = uint16(libol.Ipv4Len + len(payload))
+	iph.Protocol = libol.IpIcmp
+	iph.Source = arp.SIpAddr
+	iph.Destination = arp.TIpAddr
+	frame := append(eth.Encode(), iph.Encode()...)
+	_, _ = tap.Write(append(frame, payload...))
+	proto = readFrame(tap)
+	assert.NotNil(t, proto, "replied.")
+	if proto == nil || proto.Icmp == nil {
+		return
+	}
+	assert.Equal(t, uint8(libol.IcmpEchoReply), proto.Icmp.Type, "be the same.")
+	assert.Equal(t, uint32(0x12340001), proto.Icmp.Rest, "be the same.")
+	assert.Equal(t, "192.168.1.1", proto.Destination().String(), "be the same.")
+}
+
+func TestStackTcp(t *testing.T) {
+	a, tapA := newTestStack("a", "192.168.1.1/24")
+	b, tapB := newTestStack("b", "192.168.1.2/24")
+	defer tapA.Close()
+	defer tapB.Close()
+	// lose some frames to retransmit.
+	var count int32
+	drop := func() bool {
+		n := atomic.AddInt32(&count, 1)
+		return n == 8 || n == 40
+	}
+	go wire(tapA, tapB, drop)
+	go wire(tapB, tapA, nil)
+
+	l, err := b.Listen("tcp", ":80")
+	assert.Nil(t, err, "listen.")
+	defer l.Close()
+	_, err = b.Listen("tcp", ":80")
+	assert.NotNil(t, err, "in use.")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	conn, err := a.Dial("tcp", "192.168.1.2:80")
+	assert.Nil(t, err, "dial.")
+	if conn == nil {
+		return
+	}
+	assert.Equal(t, "192.168.1.2:80", conn.RemoteAddr().String(), "be the same.")
+	data := make([]byte, 200*1024)
+	rand.Read(data)
+	go func() {
+		_, _ = conn.Write(data)
+		_ = conn.(*Conn).CloseWrite()
+	}()
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	echo, err := ioutil.ReadAll(conn)
+	assert.Nil(t, err, "read.")
+	assert.True(t, bytes.Equal(data, echo), "be the same.")
+	_ = conn.Close()
+
+	// closed by both.
+	for i := 0; i < 20 && conn.(*Conn).State() != StateClosed; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.Equal(t, StateClosed, conn.(*Conn).State(), "closed.")
+
+	_, err = a.Dial("tcp", "192.168.1.2:81")
+	assert.Equal(t, syscall.ECONNREFUSED, err.(*net.OpError).Err, "refused.")
+	_, err = a.Dial("tcp", "192.168.2.2:80")
+	assert.Equal(t, syscall.ENETUNREACH, err.(*net.OpError).Err, "no route.")
+
+	// by next hop of routes.
+	a.SetFindNext(func(dest []byte) []byte {
+		return net.ParseIP("192.168.1.3").To4()
+	})
+	_, err = a.DialTimeout("tcp", "192.168.2.2:80", 100*time.Millisecond)
+	assert.True(t, err.(net.Error).Timeout(), "timeout.")
+}
+
+func TestStackDeadline(t *testing.T) {
+	a, tapA := newTestStack("a", "192.168.1.1/24")
+	b, tapB := newTestStack("b", "192.168.1.2/24")
+	defer tapA.Close()
+	defer tapB.Close()
+	go wire(tapA, tapB, nil)
+	go wire(tapB, tapA, nil)
+
+	l, _ := b.Listen("tcp", ":80")
+	defer l.Close()
+	conn, err := a.Dial("tcp", "192.168.1.2:80")
+	assert.Nil(t, err, "dial.")
+	if conn == nil {
+		return
+	}
+	server, err := l.Accept()
+	assert.Nil(t, err, "accept.")
+
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 16))
+	assert.True(t, err.(net.Error).Timeout(), "timeout.")
+
+	// reset by stack closed.
+	_ = b.Close()
+	_ = conn.SetReadDeadline(time.Time{})
+	_, err = conn.Read(make([]byte, 16))
+	assert.Equal(t, syscall.ECONNRESET, err, "reset.")
+	_, err = server.Read(make([]byte, 16))
+	assert.Equal(t, syscall.ECONNABORTED, err, "aborted.")
+	_, err = l.Accept()
+	assert.NotNil(t, err, "closed.")
+}
@@ -0,0 +1,845 @@
+package stack
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/danieldin95/openlan-go/libol"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	StateSynSent = iota
+	StateSynRcvd
+	StateEstablished
+	StateCloseWait // fin received.
+	StateClosed
+)
+
+const (
+	maxWindow   = 65535
+	maxSendBuf  = 256 * 1024
+	maxRetries  = 8
+	minRto      = time.Second
+	maxRto      = 8 * time.Second
+	dialTimeout = 10 * time.Second
+	lingerTime  = 60 * time.Second // wait fin of peer after closed.
+	backlog     = 128
+)
+
+var errClosed = errors.New("use of closed connection")
+
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+func seqLT(a, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+func seqLE(a, b uint32) bool {
+	return int32(a-b) <= 0
+}
+
+// Conn is a tcp connection of stack, and implements net.Conn.
+type Conn struct {
+	lock       sync.Mutex
+	stack      *Stack
+	key        connKey
+	local      *net.TCPAddr
+	remote     *net.TCPAddr
+	state      int
+	listener   *Listener // accepted by if passive opened.
+	iss        uint32
+	sndUna     uint32
+	sndNxt     uint32
+	sndMax     uint32 // highest sent.
+	sndWnd     uint32
+	sndBuf     []byte // data not acked from sndUna.
+	mss        int
+	closing    bool // fin sent after data.
+	finSent    bool
+	finAcked   bool
+	rcvNxt     uint32
+	rcvBuf     []byte
+	rcvFin     bool
+	readClosed bool
+	err        error
+	rto        time.Duration
+	retries    int
+	timing     bool
+	timer      *time.Timer
+	notify     chan struct{}
+	rDeadline  time.Time
+	wDeadline  time.Time
+}
+
+func newConn(s *Stack, local, remote *net.TCPAddr, state int) *Conn {
+	c := &Conn{
+		stack:  s,
+		local:  local,
+		remote: remote,
+		state:  state,
+		iss:    rand.Uint32(),
+		mss:    536,
+		rto:    minRto,
+		notify: make(chan struct{}),
+	}
+	copy(c.key.remote[:], remote.IP.To4())
+	c.key.local = uint16(local.Port)
+	c.key.port = uint16(remote.Port)
+	c.sndUna = c.iss
+	c.sndNxt = c.iss + 1
+	c.sndMax = c.sndNxt
+	c.timer = time.AfterFunc(time.Hour, c.retransmit)
+	c.timer.Stop()
+	return c
+}
+
+func (c *Conn) String() string {
+	return c.local.String() + "-" + c.remote.String()
+}
+
+// State returns state of connection.
+func (c *Conn) State() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.state
+}
+
+// wake notifies all waiting for changes.
+func (c *Conn) wake() {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// wait waits changes until deadline, and lock must be held.
+func (c *Conn) wait(deadline time.Time) error {
+	notify := c.notify
+	if deadline.IsZero() {
+		c.lock.Unlock()
+		<-notify
+		c.lock.Lock()
+		return nil
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		return &timeoutError{}
+	}
+	timer := time.NewTimer(left)
+	c.lock.Unlock()
+	select {
+	case <-notify:
+	case <-timer.C:
+	}
+	timer.Stop()
+	c.lock.Lock()
+	return nil
+}
+
+func (c *Conn) window() uint16 {
+	free := maxWindow - len(c.rcvBuf)
+	if free < 0 {
+		free = 0
+	}
+	return uint16(free)
+}
+
+// segment sends a segment, and mss option is carried by syn.
+func (c *Conn) segment(flags uint8, seq uint32, data []byte) {
+	tcp := libol.NewTcp()
+	tcp.Source = uint16(c.local.Port)
+	tcp.Destination = uint16(c.remote.Port)
+	tcp.Sequence = seq
+	if flags&libol.TcpAck != 0 {
+		tcp.Acknowledgment = c.rcvNxt
+	}
+	tcp.ControlBits = flags
+	tcp.Window = c.window()
+	var opts []byte
+	if flags&libol.TcpSyn != 0 {
+		mss := c.stack.mtu - libol.Ipv4Len - libol.TcpLen
+		opts = []byte{0x02, 0x04, byte(mss >> 8), byte(mss)}
+	}
+	tcp.DataOffset = uint8((libol.TcpLen+len(opts))/4) << 4
+	seg := make([]byte, 0, libol.TcpLen+len(opts)+len(data))
+	seg = append(seg, tcp.Encode()...)
+	seg = append(seg, opts...)
+	seg = append(seg, data...)
+	sum := libol.Ipv4Checksum(c.local.IP.To4(), c.remote.IP.To4(), libol.IpTcp, seg)
+	binary.BigEndian.PutUint16(seg[16:18], sum)
+	_ = c.stack.send(c.remote.IP, libol.IpTcp, seg)
+}
+
+// update records the highest sequence sent.
+func (c *Conn) update() {
+	if seqLT(c.sndMax, c.sndNxt) {
+		c.sndMax = c.sndNxt
+	}
+}
+
+// arm starts timer of retransmission if not started.
+func (c *Conn) arm() {
+	if !c.timing {
+		c.timing = true
+		c.timer.Reset(c.rto)
+	}
+}
+
+func (c *Conn) disarm() {
+	c.timing = false
+	c.timer.Stop()
+}
+
+// output sends data allowed by window of peer, and fin after all data.
+func (c *Conn) output() {
+	if c.state != StateEstablished && c.state != StateCloseWait {
+		return
+	}
+	for !c.finSent {
+		inflight := int(c.sndNxt - c.sndUna)
+		unsent := len(c.sndBuf) - inflight
+		if unsent > 0 {
+			size := int(c.sndWnd) - inflight
+			if size <= 0 {
+				break
+			}
+			if size > unsent {
+				size = unsent
+			}
+			if size > c.mss {
+				size = c.mss
+			}
+			c.segment(libol.TcpAck|libol.TcpPsh, c.sndNxt, c.sndBuf[inflight:inflight+size])
+			c.sndNxt += uint32(size)
+			c.update()
+			continue
+		}
+		if c.closing {
+			c.segment(libol.TcpFin|libol.TcpAck, c.sndNxt, nil)
+			c.sndNxt++
+			c.finSent = true
+			c.update()
+		}
+		break
+	}
+	if c.sndNxt != c.sndUna || len(c.sndBuf) > 0 {
+		c.arm()
+	}
+}
+
+func (c *Conn) retransmit() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.timing = false
+	if c.state == StateClosed {
+		return
+	}
+	c.retries++
+	if c.retries > maxRetries {
+		c.destroy(&timeoutError{})
+		return
+	}
+	if c.rto *= 2; c.rto > maxRto {
+		c.rto = maxRto
+	}
+	switch c.state {
+	case StateSynSent:
+		c.segment(libol.TcpSyn, c.iss, nil)
+		c.arm()
+	case StateSynRcvd:
+		c.segment(libol.TcpSyn|libol.TcpAck, c.iss, nil)
+		c.arm()
+	default:
+		if c.finAcked || c.sndNxt == c.sndUna && len(c.sndBuf) == 0 {
+			return
+		}
+		// go back to data not acked.
+		c.sndNxt = c.sndUna
+		c.finSent = false
+		if c.sndWnd == 0 && len(c.sndBuf) > 0 {
+			// probe window closed by one byte.
+			c.segment(libol.TcpAck, c.sndUna, c.sndBuf[:1])
+			c.sndNxt++
+			c.update()
+			c.arm()
+			return
+		}
+		c.output()
+	}
+}
+
+// destroy closes connection, and removes it from stack.
+func (c *Conn) destroy(err error) {
+	if c.state == StateClosed {
+		return
+	}
+	libol.Debug("Conn.destroy: %s %v", c, err)
+	c.state = StateClosed
+	if c.err == nil {
+		c.err = err
+	}
+	c.disarm()
+	c.stack.remove(c)
+	c.wake()
+}
+
+// abort resets connection to peer.
+func (c *Conn) abort() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.state == StateClosed {
+		return
+	}
+	c.segment(libol.TcpRst|libol.TcpAck, c.sndNxt, nil)
+	c.destroy(syscall.ECONNABORTED)
+}
+
+func mssOption(opts []byte) int {
+	for len(opts) > 0 {
+		switch opts[0] {
+		case 0x00:
+			return 0
+		case 0x01:
+			opts = opts[1:]
+			continue
+		}
+		if len(opts) < 2 || int(opts[1]) < 2 || int(opts[1]) > len(opts) {
+			return 0
+		}
+		if opts[0] == 0x02 && opts[1] == 4 {
+			return int(binary.BigEndian.Uint16(opts[2:4]))
+		}
+		opts = opts[opts[1]:]
+	}
+	return 0
+}
+
+// established is called if handshake completed.
+func (c *Conn) established(tcp *libol.Tcp, opts []byte) {
+	c.state = StateEstablished
+	c.sndUna = tcp.Acknowledgment
+	c.sndNxt = tcp.Acknowledgment
+	c.sndMax = tcp.Acknowledgment
+	c.sndWnd = uint32(tcp.Window)
+	c.retries = 0
+	c.rto = minRto
+	c.disarm()
+	if mss := mssOption(opts); mss > 0 {
+		c.mss = mss
+	}
+	if mss := c.stack.mtu - libol.Ipv4Len - libol.TcpLen; c.mss > mss {
+		c.mss = mss
+	}
+	c.wake()
+}
+
+// input processes a segment received.
+func (c *Conn) input(tcp *libol.Tcp, opts, payload []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	seq := tcp.Sequence
+	if tcp.HasFlag(libol.TcpRst) {
+		switch {
+		case c.state == StateSynSent:
+			if tcp.HasFlag(libol.TcpAck) && tcp.Acknowledgment == c.iss+1 {
+				c.destroy(syscall.ECONNREFUSED)
+			}
+		case seq == c.rcvNxt:
+			c.destroy(syscall.ECONNRESET)
+		}
+		return
+	}
+	switch c.state {
+	case StateSynSent:
+		if !tcp.HasFlag(libol.TcpSyn|libol.TcpAck) || tcp.Acknowledgment != c.iss+1 {
+			return
+		}
+		c.rcvNxt = seq + 1
+		c.established(tcp, opts)
+		c.segment(libol.TcpAck, c.sndNxt, nil)
+		return
+	case StateSynRcvd:
+		if tcp.HasFlag(libol.TcpSyn) {
+			c.segment(libol.TcpSyn|libol.TcpAck, c.iss, nil)
+			return
+		}
+		if !tcp.HasFlag(libol.TcpAck) || tcp.Acknowledgment != c.iss+1 {
+			return
+		}
+		c.established(tcp, opts)
+		if !c.listener.enqueue(c) {
+			c.segment(libol.TcpRst|libol.TcpAck, c.sndNxt, nil)
+			c.destroy(syscall.ECONNREFUSED)
+			return
+		}
+	case StateClosed:
+		return
+	}
+	if tcp.HasFlag(libol.TcpSyn) {
+		// ack lost, and syn retransmitted.
+		c.segment(libol.TcpAck, c.sndNxt, nil)
+		return
+	}
+	if tcp.HasFlag(libol.TcpAck) {
+		ack := tcp.Acknowledgment
+		if seqLT(c.sndMax, ack) {
+			c.segment(libol.TcpAck, c.sndNxt, nil)
+			return
+		}
+		if seqLT(c.sndUna, ack) {
+			size := int(ack - c.sndUna)
+			if size > len(c.sndBuf) {
+				// beyond data is fin.
+				c.finAcked = true
+				c.finSent = true
+				size = len(c.sndBuf)
+			}
+			c.sndBuf = c.sndBuf[size:]
+			if len(c.sndBuf) == 0 {
+				c.sndBuf = nil
+			}
+			c.sndUna = ack
+			if seqLT(c.sndNxt, ack) {
+				c.sndNxt = ack
+			}
+			c.rto = minRto
+			c.disarm()
+			c.wake()
+		}
+		if seqLE(c.sndUna, ack) {
+			c.sndWnd = uint32(tcp.Window)
+			c.retries = 0
+		}
+	}
+	fin := tcp.HasFlag(libol.TcpFin)
+	if len(payload) > 0 || fin {
+		if seqLT(seq, c.rcvNxt) {
+			skip := int(c.rcvNxt - seq)
+			if skip > len(payload) {
+				skip = len(payload)
+				fin = false
+			}
+			payload = payload[skip:]
+			seq += uint32(skip)
+		}
+		if seq != c.rcvNxt || c.rcvFin {
+			// out of order, and ack to retransmit.
+			c.segment(libol.TcpAck, c.sndNxt, nil)
+			c.output()
+			return
+		}
+		if room := int(c.window()); len(payload) > room && !c.readClosed {
+			payload = payload[:room]
+			fin = false
+		}
+		if !c.readClosed {
+			c.rcvBuf = append(c.rcvBuf, payload...)
+		}
+		c.rcvNxt += uint32(len(payload))
+		if fin {
+			c.rcvNxt++
+			c.rcvFin = true
+			c.state = StateCloseWait
+		}
+		c.segment(libol.TcpAck, c.sndNxt, nil)
+		c.wake()
+	}
+	c.output()
+	if c.finAcked && c.rcvFin {
+		c.destroy(nil)
+	}
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for {
+		if len(c.rcvBuf) > 0 {
+			low := int(c.window()) < c.mss
+			n := copy(b, c.rcvBuf)
+			c.rcvBuf = c.rcvBuf[n:]
+			if len(c.rcvBuf) == 0 {
+				c.rcvBuf = nil
+			}
+			// update window opened.
+			if low && int(c.window()) >= c.mss && c.state != StateClosed {
+				c.segment(libol.TcpAck, c.sndNxt, nil)
+			}
+			return n, nil
+		}
+		if c.readClosed {
+			return 0, errClosed
+		}
+		if c.rcvFin {
+			return 0, io.EOF
+		}
+		if c.err != nil {
+			return 0, c.err
+		}
+		if err := c.wait(c.rDeadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	n := 0
+	for n < len(b) {
+		if c.err != nil {
+			return n, c.err
+		}
+		if c.closing || c.state != StateEstablished && c.state != StateCloseWait {
+			return n, errClosed
+		}
+		room := maxSendBuf - len(c.sndBuf)
+		if room <= 0 {
+			if err := c.wait(c.wDeadline); err != nil {
+				return n, err
+			}
+			continue
+		}
+		if room > len(b)-n {
+			room = len(b) - n
+		}
+		c.sndBuf = append(c.sndBuf, b[n:n+room]...)
+		n += room
+		c.output()
+	}
+	return n, nil
+}
+
+// CloseWrite sends fin after data written, and peer reads EOF.
+func (c *Conn) CloseWrite() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.state != StateEstablished && c.state != StateCloseWait {
+		return errClosed
+	}
+	c.closing = true
+	c.output()
+	return nil
+}
+
+// Close sends fin, and connection is removed if acked and fin of peer
+// received, or lingered too long.
+func (c *Conn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch c.state {
+	case StateEstablished, StateCloseWait:
+		c.closing = true
+		c.output()
+		time.AfterFunc(lingerTime, c.abort)
+	case StateClosed:
+	default:
+		c.segment(libol.TcpRst|libol.TcpAck, c.sndNxt, nil)
+		c.destroy(errClosed)
+	}
+	c.readClosed = true
+	c.rcvBuf = nil
+	c.wake()
+	return nil
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rDeadline = t
+	c.wDeadline = t
+	c.wake()
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rDeadline = t
+	c.wake()
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.wDeadline = t
+	c.wake()
+	return nil
+}
+
+// Listener accepts tcp connections to a port of stack.
+type Listener struct {
+	stack *Stack
+	port  uint16
+	queue chan *Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (l *Listener) enqueue(c *Conn) bool {
+	select {
+	case <-l.done:
+		return false
+	case l.queue <- c:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.queue:
+		return c, nil
+	case <-l.done:
+		return nil, errClosed
+	}
+}
+
+// Close stops listening, and resets connections not accepted.
+func (l *Listener) Close() error {
+	l.once.Do(func() {
+		l.stack.lock.Lock()
+		delete(l.stack.listens, l.port)
+		l.stack.lock.Unlock()
+		close(l.done)
+		for {
+			select {
+			case c := <-l.queue:
+				c.abort()
+			default:
+				return
+			}
+		}
+	})
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	l.stack.lock.Lock()
+	defer l.stack.lock.Unlock()
+	return &net.TCPAddr{IP: l.stack.ipAddr, Port: int(l.port)}
+}
+
+func (s *Stack) remove(c *Conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conns[c.key] == c {
+		delete(s.conns, c.key)
+	}
+}
+
+// allocPort returns a free port in range of ephemeral.
+func (s *Stack) allocPort() uint16 {
+	used := make(map[uint16]bool, len(s.conns))
+	for key := range s.conns {
+		used[key.local] = true
+	}
+	for i := 0; i < 16384; i++ {
+		port := uint16(49152 + rand.Intn(16384))
+		if !used[port] && s.listens[port] == nil {
+			return port
+		}
+	}
+	return 0
+}
+
+func resolve(address string) (*net.TCPAddr, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, libol.NewErr("invalid port %s", portStr)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range ips {
+			if addr.To4() != nil {
+				ip = addr
+				break
+			}
+		}
+	}
+	if ip == nil || ip.To4() == nil {
+		return nil, libol.NewErr("%s not ipv4", host)
+	}
+	return &net.TCPAddr{IP: ip.To4(), Port: port}, nil
+}
+
+func (s *Stack) Dial(network, address string) (net.Conn, error) {
+	return s.DialTimeout(network, address, dialTimeout)
+}
+
+// DialTimeout connects to address on the network, such as 192.168.1.2:80.
+func (s *Stack) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" {
+		return nil, libol.NewErr("%s not supported", network)
+	}
+	remote, err := resolve(address)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: network, Addr: remote, Err: err}
+	}
+	s.lock.Lock()
+	if s.nextHop(remote.IP) == nil {
+		s.lock.Unlock()
+		return nil, opErr(syscall.ENETUNREACH)
+	}
+	port := s.allocPort()
+	if port == 0 {
+		s.lock.Unlock()
+		return nil, opErr(syscall.EADDRNOTAVAIL)
+	}
+	local := &net.TCPAddr{IP: s.ipAddr, Port: int(port)}
+	c := newConn(s, local, remote, StateSynSent)
+	s.conns[c.key] = c
+	s.lock.Unlock()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.segment(libol.TcpSyn, c.iss, nil)
+	c.arm()
+	deadline := time.Now().Add(timeout)
+	for c.state == StateSynSent {
+		if err := c.wait(deadline); err != nil {
+			c.destroy(err)
+		}
+	}
+	if c.state != StateEstablished {
+		return nil, opErr(c.err)
+	}
+	return c, nil
+}
+
+// Listen listens on port of address, and host of address is ignored.
+func (s *Stack) Listen(network, address string) (net.Listener, error) {
+	if network != "tcp" && network != "tcp4" {
+		return nil, libol.NewErr("%s not supported", network)
+	}
+	_, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, libol.NewErr("invalid port %s", portStr)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if port == 0 {
+		port = int(s.allocPort())
+	}
+	if _, ok := s.listens[uint16(port)]; ok {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: syscall.EADDRINUSE}
+	}
+	l := &Listener{
+		stack: s,
+		port:  uint16(port),
+		queue: make(chan *Conn, backlog),
+		done:  make(chan struct{}),
+	}
+	s.listens[l.port] = l
+	libol.Info("Stack.Listen: %s %d", s.name, port)
+	return l, nil
+}
+
+// reset answers segment of no connection.
+func (s *Stack) reset(iph *libol.Ipv4, tcp *libol.Tcp, size int) {
+	rst := libol.NewTcp()
+	rst.Source = tcp.Destination
+	rst.Destination = tcp.Source
+	if tcp.HasFlag(libol.TcpAck) {
+		rst.Sequence = tcp.Acknowledgment
+		rst.ControlBits = libol.TcpRst
+	} else {
+		if tcp.HasFlag(libol.TcpSyn) {
+			size++
+		}
+		if tcp.HasFlag(libol.TcpFin) {
+			size++
+		}
+		rst.Acknowledgment = tcp.Sequence + uint32(size)
+		rst.ControlBits = libol.TcpRst | libol.TcpAck
+	}
+	rst.DataOffset = libol.TcpLen / 4 << 4
+	seg := rst.Encode()
+	sum := libol.Ipv4Checksum(iph.Destination, iph.Source, libol.IpTcp, seg)
+	binary.BigEndian.PutUint16(seg[16:18], sum)
+	_ = s.send(iph.Source, libol.IpTcp, seg)
+}
+
+func (s *Stack) onTcp(iph *libol.Ipv4, seg []byte) {
+	if libol.Ipv4Checksum(iph.Source, iph.Destination, libol.IpTcp, seg) != 0 {
+		return
+	}
+	tcp, err := libol.NewTcpFromFrame(seg)
+	if err != nil {
+		return
+	}
+	hdrLen := int(tcp.DataOffset>>4) * 4
+	if hdrLen < libol.TcpLen || hdrLen > len(seg) {
+		return
+	}
+	opts := seg[libol.TcpLen:hdrLen]
+	payload := seg[hdrLen:]
+
+	key := connKey{local: tcp.Destination, port: tcp.Source}
+	copy(key.remote[:], iph.Source)
+	s.lock.Lock()
+	c, ok := s.conns[key]
+	if !ok && tcp.ControlBits&(libol.TcpSyn|libol.TcpAck|libol.TcpRst) == libol.TcpSyn {
+		if l, ok := s.listens[tcp.Destination]; ok {
+			local := &net.TCPAddr{IP: iph.Destination, Port: int(tcp.Destination)}
+			remote := &net.TCPAddr{IP: net.IP(iph.Source).To4(), Port: int(tcp.Source)}
+			c = newConn(s, local, remote, StateSynRcvd)
+			c.listener = l
+			c.rcvNxt = tcp.Sequence + 1
+			c.sndWnd = uint32(tcp.Window)
+			if mss := mssOption(opts); mss > 0 {
+				c.mss = mss
+			}
+			s.conns[key] = c
+			s.lock.Unlock()
+
+			c.lock.Lock()
+			c.segment(libol.TcpSyn|libol.TcpAck, c.iss, nil)
+			c.arm()
+			c.lock.Unlock()
+			return
+		}
+	}
+	s.lock.Unlock()
+	if c == nil {
+		if !tcp.HasFlag(libol.TcpRst) {
+			s.reset(iph, tcp, len(payload))
+		}
+		return
+	}
+	c.input(tcp, opts, payload)
+}
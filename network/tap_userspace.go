@@ -23,6 +23,7 @@ func NewUserSpaceTap(tenant string, c TapConfig) (*UserSpaceTap, error) {
 	tap := &UserSpaceTap{
 		tenant: tenant,
 		name:   c.Name,
+		config: c,
 		ifMtu:  1514,
 	}
 	Tapers.Add(tap)
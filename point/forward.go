@@ -0,0 +1,202 @@
+package point
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/network/stack"
+	"net"
+	"sort"
+	"sync"
+)
+
+type forward struct {
+	id        string
+	cfg       *config.Forward
+	forwarder *libol.Forwarder
+}
+
+func (f *forward) Model() models.Forward {
+	return models.Forward{
+		Id:      f.id,
+		Local:   f.cfg.Local,
+		Remote:  f.cfg.Remote,
+		Reverse: f.cfg.Reverse,
+		Active:  f.forwarder.Active(),
+		Total:   f.forwarder.Total(),
+	}
+}
+
+// Forwards is rules forwarding connections between local and the network
+// by stack of point, and id of rule is local-<listen>-<remote>, or
+// reverse-<listen>-<local> if reverse. The lock guards forwards of point
+// configuration too.
+type Forwards struct {
+	lock  sync.RWMutex
+	stack *stack.Stack
+	rules map[string]*forward
+}
+
+func NewForwards(s *stack.Stack) *Forwards {
+	return &Forwards{
+		stack: s,
+		rules: make(map[string]*forward, 32),
+	}
+}
+
+// Start listens by rule, and connections accepted are forwarded to the
+// network, or to local if reverse.
+func (fs *Forwards) Start(cfg *config.Forward) (models.Forward, error) {
+	if _, _, err := net.SplitHostPort(cfg.Local); err != nil {
+		return models.Forward{}, err
+	}
+	if _, _, err := net.SplitHostPort(cfg.Remote); err != nil {
+		return models.Forward{}, err
+	}
+	var fwd *libol.Forwarder
+	if cfg.Reverse {
+		fwd = libol.NewForwarder(cfg.Remote, cfg.Local, fs.stack.Listen, net.Dial)
+	} else {
+		fwd = libol.NewForwarder(cfg.Local, cfg.Remote, net.Listen, fs.stack.Dial)
+	}
+	if err := fwd.Listen(); err != nil {
+		return models.Forward{}, err
+	}
+	f := &forward{id: "local-" + fwd.Addr() + "-" + cfg.Remote, cfg: cfg, forwarder: fwd}
+	if cfg.Reverse {
+		f.id = "reverse-" + fwd.Addr() + "-" + cfg.Local
+	}
+	fs.lock.Lock()
+	if _, ok := fs.rules[f.id]; ok {
+		fs.lock.Unlock()
+		fwd.Close()
+		return models.Forward{}, libol.NewErr("forward %s existed", f.id)
+	}
+	fs.rules[f.id] = f
+	fs.lock.Unlock()
+	libol.Go(fwd.Accept)
+	return f.Model(), nil
+}
+
+// Stop closes rule and connections forwarding by it.
+func (fs *Forwards) Stop(id string) (*config.Forward, error) {
+	fs.lock.Lock()
+	f, ok := fs.rules[id]
+	delete(fs.rules, id)
+	fs.lock.Unlock()
+	if !ok {
+		return nil, libol.NewErr("forward %s not found", id)
+	}
+	f.forwarder.Close()
+	return f.cfg, nil
+}
+
+func (fs *Forwards) StopAll() {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	for id, f := range fs.rules {
+		f.forwarder.Close()
+		delete(fs.rules, id)
+	}
+}
+
+func (fs *Forwards) Get(id string) (models.Forward, bool) {
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+	if f, ok := fs.rules[id]; ok {
+		return f.Model(), true
+	}
+	return models.Forward{}, false
+}
+
+func (fs *Forwards) List() []models.Forward {
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+	list := make([]models.Forward, 0, len(fs.rules))
+	for _, f := range fs.rules {
+		list = append(list, f.Model())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+	return list
+}
+
+// AddForward starts rule, and saves it to configuration.
+func (p *Worker) AddForward(cfg config.Forward) (models.Forward, error) {
+	if p.forwards == nil {
+		return models.Forward{}, libol.NewErr("forward needs provider userspace")
+	}
+	f, err := p.forwards.Start(&cfg)
+	if err != nil {
+		return f, err
+	}
+	libol.Info("Worker.AddForward: %s %s->%s", f.Id, cfg.Local, cfg.Remote)
+	p.forwards.lock.Lock()
+	p.config.Forwards = append(p.config.Forwards, &cfg)
+	p.forwards.lock.Unlock()
+	return f, nil
+}
+
+// DelForward stops rule, and removes it from configuration.
+func (p *Worker) DelForward(id string) error {
+	if p.forwards == nil {
+		return libol.NewErr("forward %s not found", id)
+	}
+	cfg, err := p.forwards.Stop(id)
+	if err != nil {
+		return err
+	}
+	libol.Info("Worker.DelForward: %s", id)
+	p.forwards.lock.Lock()
+	defer p.forwards.lock.Unlock()
+	forwards := make([]*config.Forward, 0, len(p.config.Forwards))
+	for _, f := range p.config.Forwards {
+		if f != cfg {
+			forwards = append(forwards, f)
+		}
+	}
+	p.config.Forwards = forwards
+	return nil
+}
+
+// UpdateForward replaces rule, and the older is restored if failed.
+func (p *Worker) UpdateForward(id string, cfg config.Forward) (models.Forward, error) {
+	if p.forwards == nil {
+		return models.Forward{}, libol.NewErr("forward %s not found", id)
+	}
+	older, err := p.forwards.Stop(id)
+	if err != nil {
+		return models.Forward{}, err
+	}
+	f, err := p.forwards.Start(&cfg)
+	if err != nil {
+		if _, err := p.forwards.Start(older); err != nil {
+			libol.Warn("Worker.UpdateForward: restore %s", err)
+		}
+		return f, err
+	}
+	libol.Info("Worker.UpdateForward: %s->%s", id, f.Id)
+	p.forwards.lock.Lock()
+	defer p.forwards.lock.Unlock()
+	for i, fwd := range p.config.Forwards {
+		if fwd == older {
+			p.config.Forwards[i] = &cfg
+		}
+	}
+	return f, nil
+}
+
+func (p *Worker) Forward(id string) (models.Forward, bool) {
+	if p.forwards == nil {
+		return models.Forward{}, false
+	}
+	return p.forwards.Get(id)
+}
+
+func (p *Worker) Forwards() []models.Forward {
+	if p.forwards == nil {
+		return make([]models.Forward, 0)
+	}
+	return p.forwards.List()
+}
@@ -0,0 +1,126 @@
+package point
+
+import (
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/danieldin95/openlan-go/network/stack"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func wireTap(from, to network.Taper) {
+	for {
+		buf := make([]byte, 1600)
+		n, err := from.Read(buf)
+		if err != nil {
+			return
+		}
+		_, _ = to.Write(buf[:n])
+	}
+}
+
+func echoOnce(t *testing.T, conn net.Conn, data string) {
+	_, err := conn.Write([]byte(data))
+	assert.Nil(t, err, "write.")
+	buf := make([]byte, len(data))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.ReadFull(conn, buf)
+	assert.Nil(t, err, "read.")
+	assert.Equal(t, data, string(buf), "be the same.")
+}
+
+func serveEcho(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			_, _ = io.Copy(conn, conn)
+			_ = conn.Close()
+		}()
+	}
+}
+
+func TestWorkerForward(t *testing.T) {
+	c := &config.Point{
+		Connection: "127.0.0.1:10002",
+		Protocol:   "tcp",
+		Network:    "default",
+		Interface:  config.Interface{IfMtu: 1518, Provider: "userspace"},
+	}
+	p := NewWorker(c)
+	p.Initialize()
+	defer p.tapWorker.close()
+	_ = p.OnIpAddr(p.tcpWorker, &models.Network{IfAddr: "192.168.1.10", Netmask: "255.255.255.0"})
+
+	// a host on the network.
+	peer := stack.NewStack("peer", 1500)
+	_ = peer.SetAddr("192.168.1.2/24")
+	tap, _ := network.NewUserSpaceTap("test", network.TapConfig{Type: network.TAP})
+	tap.Up()
+	defer tap.Close()
+	_ = peer.AddSlave(tap)
+	go wireTap(p.Device(), tap)
+	go wireTap(tap, p.Device())
+	l, _ := peer.Listen("tcp", ":80")
+	defer l.Close()
+	go serveEcho(l)
+
+	fwd, err := p.AddForward(config.Forward{Local: "127.0.0.1:0", Remote: "192.168.1.2:80"})
+	assert.Nil(t, err, "add.")
+	_, port, _ := net.SplitHostPort(p.forwards.rules[fwd.Id].forwarder.Addr())
+	assert.Equal(t, "local-127.0.0.1:"+port+"-192.168.1.2:80", fwd.Id, "be the same.")
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	assert.Nil(t, err, "dial.")
+	echoOnce(t, conn, "hello")
+	fwd, _ = p.Forward(fwd.Id)
+	assert.Equal(t, 1, fwd.Active, "forwarding.")
+	assert.Equal(t, uint64(1), fwd.Total, "be the same.")
+	_ = conn.Close()
+
+	// reverse to local service.
+	local, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer local.Close()
+	go serveEcho(local)
+	rev, err := p.AddForward(config.Forward{Local: local.Addr().String(), Remote: ":8080", Reverse: true})
+	assert.Nil(t, err, "add.")
+	assert.Equal(t, "reverse-192.168.1.10:8080-"+local.Addr().String(), rev.Id, "be the same.")
+	conn, err = peer.Dial("tcp", "192.168.1.10:8080")
+	assert.Nil(t, err, "dial.")
+	if conn != nil {
+		echoOnce(t, conn, "world")
+		_ = conn.Close()
+	}
+	assert.Equal(t, 2, len(p.Forwards()), "be the same.")
+	assert.Equal(t, 2, len(c.Forwards), "saved.")
+
+	_, err = p.AddForward(config.Forward{Local: "127.0.0.1:0", Remote: ":8080", Reverse: true})
+	assert.NotNil(t, err, "in use.")
+	rev, err = p.UpdateForward(rev.Id, config.Forward{Local: local.Addr().String(), Remote: ":8081", Reverse: true})
+	assert.Nil(t, err, "update.")
+	assert.Equal(t, "reverse-192.168.1.10:8081-"+local.Addr().String(), rev.Id, "be the same.")
+	assert.Equal(t, ":8081", c.Forwards[1].Remote, "saved.")
+
+	// same port on other host is not replaced.
+	other, err := p.AddForward(config.Forward{Local: "127.0.0.2:" + port, Remote: "192.168.1.2:80"})
+	assert.Nil(t, err, "add.")
+	assert.NotEqual(t, fwd.Id, other.Id, "not same.")
+	assert.Equal(t, 3, len(p.Forwards()), "be the same.")
+	assert.Nil(t, p.DelForward(other.Id), "delete.")
+
+	assert.Nil(t, p.DelForward(fwd.Id), "delete.")
+	assert.NotNil(t, p.DelForward(fwd.Id), "not found.")
+	assert.Equal(t, 1, len(c.Forwards), "removed.")
+	_, err = net.Dial("tcp", "127.0.0.1:"+port)
+	assert.NotNil(t, err, "not listen.")
+	p.forwards.StopAll()
+	assert.Equal(t, 0, len(p.Forwards()), "stopped.")
+
+	_, err = NewWorker(&config.Point{}).AddForward(config.Forward{Local: ":1", Remote: ":2"})
+	assert.NotNil(t, err, "not userspace.")
+}
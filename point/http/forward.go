@@ -0,0 +1,76 @@
+package http
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Forward struct {
+	pointer Pointer
+}
+
+func (h Forward) Router(router *mux.Router) {
+	router.HandleFunc("/forward", h.List).Methods("GET")
+	router.HandleFunc("/forward", h.Add).Methods("POST")
+	router.HandleFunc("/forward/{id}", h.Get).Methods("GET")
+	router.HandleFunc("/forward/{id}", h.Update).Methods("POST", "PUT")
+	router.HandleFunc("/forward/{id}", h.Del).Methods("DELETE")
+}
+
+func (h Forward) List(w http.ResponseWriter, r *http.Request) {
+	ResponseJson(w, h.pointer.Forwards())
+}
+
+func (h Forward) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if fwd, ok := h.pointer.Forward(vars["id"]); ok {
+		ResponseJson(w, fwd)
+	} else {
+		http.Error(w, vars["id"], http.StatusNotFound)
+	}
+}
+
+func (h Forward) Add(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Forward{}
+	if err := GetData(r, &cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fwd, err := h.pointer.AddForward(cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	ResponseJson(w, fwd)
+}
+
+func (h Forward) Update(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cfg := config.Forward{}
+	if err := GetData(r, &cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.pointer.Forward(vars["id"]); !ok {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	fwd, err := h.pointer.UpdateForward(vars["id"], cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	ResponseJson(w, fwd)
+}
+
+func (h Forward) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	libol.Info("Forward.Del %s", vars["id"])
+	if err := h.pointer.DelForward(vars["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
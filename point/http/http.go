@@ -105,6 +105,39 @@ func (h *Http) LoadRouter() {
 			ResponseJson(w, h.pointer.Config())
 		}
 	})
+	router.HandleFunc("/current/route", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJson(w, h.pointer.Routes())
+	})
+	router.HandleFunc("/current/endpoint", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJson(w, h.pointer.Endpoints())
+	})
+	router.HandleFunc("/current/failover", func(w http.ResponseWriter, r *http.Request) {
+		ResponseJson(w, h.pointer.Failovers())
+	})
+	router.HandleFunc("/metrics", h.GetMetrics)
+	Forward{pointer: h.pointer}.Router(router)
+}
+
+func (h *Http) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	m := libol.NewMetrics()
+	c := h.pointer.Config()
+	labels := []string{"network", c.Network, "connection", c.Connection}
+	up := 0.0
+	if client := h.pointer.Client(); client != nil {
+		m.AddClient("openlan_point", client.Sts(), labels...)
+		if client.Status() == libol.ClAuth {
+			up = 1
+		}
+	}
+	m.Gauge("openlan_point_up", "Point signed in switch.", up, labels...)
+	m.Counter("openlan_point_reconnects_total", "Reconnects to switch.", h.pointer.Reconnects(), labels...)
+	for _, fwd := range h.pointer.Forwards() {
+		fwdLabels := append([]string{"forward", fwd.Id}, labels...)
+		m.Gauge("openlan_point_forward_active", "Connections forwarding.", float64(fwd.Active), fwdLabels...)
+		m.Counter("openlan_point_forward_total", "Connections forwarded.", fwd.Total, fwdLabels...)
+	}
+	m.AddGoroutines()
+	ResponseText(w, m.Bytes())
 }
 
 func (h *Http) Start() {
@@ -1,8 +1,22 @@
 package http
 
-import "github.com/danieldin95/openlan-go/main/config"
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+)
 
 type Pointer interface {
 	UUID() string
 	Config() *config.Point
+	Client() libol.SocketClient
+	Reconnects() uint64
+	Routes() []*models.Route
+	Endpoints() []models.Endpoint
+	Failovers() []models.Failover
+	Forwards() []models.Forward
+	Forward(id string) (models.Forward, bool)
+	AddForward(cfg config.Forward) (models.Forward, error)
+	UpdateForward(id string, cfg config.Forward) (models.Forward, error)
+	DelForward(id string) error
 }
@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"net/http"
 )
 
@@ -16,6 +17,23 @@ func ResponseJson(w http.ResponseWriter, v interface{}) {
 	}
 }
 
+func ResponseMsg(w http.ResponseWriter, code int, message string) {
+	ret := struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{
+		Code:    code,
+		Message: message,
+	}
+	ResponseJson(w, ret)
+}
+
+// ResponseText responds metrics in text exposition format.
+func ResponseText(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(data)
+}
+
 func ResponseYaml(w http.ResponseWriter, v interface{}) {
 	str, err := yaml.Marshal(v)
 	if err == nil {
@@ -26,6 +44,15 @@ func ResponseYaml(w http.ResponseWriter, v interface{}) {
 	}
 }
 
+func GetData(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
 func GetQueryOne(req *http.Request, name string) string {
 	query := req.URL.Query()
 	if values, ok := query[name]; ok {
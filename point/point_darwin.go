@@ -42,6 +42,10 @@ func (p *Point) Stop() {
 }
 
 func (p *Point) AddAddr(ipStr string) error {
+	if strings.Contains(ipStr, ":") {
+		libol.Warn("Point.AddAddr: ipv6 %s not supported", ipStr)
+		return nil
+	}
 	if ipStr == "" {
 		return nil
 	}
@@ -65,6 +69,10 @@ func (p *Point) AddAddr(ipStr string) error {
 }
 
 func (p *Point) DelAddr(ipStr string) error {
+	if strings.Contains(ipStr, ":") {
+		libol.Warn("Point.DelAddr: ipv6 %s not supported", ipStr)
+		return nil
+	}
 	// delete directly route.
 	out, err := libol.IpRouteDel(p.IfName(), ipStr, "")
 	if err != nil {
@@ -95,7 +103,7 @@ func (p *Point) AddRoutes(routes []*models.Route) error {
 		}
 		libol.Info("Point.AddRoutes: route %s via %s", route.Prefix, p.IfName())
 	}
-	p.routes = routes
+	p.routes = append(p.routes, routes...)
 	return nil
 }
 
@@ -108,6 +116,6 @@ func (p *Point) DelRoutes(routes []*models.Route) error {
 		}
 		libol.Info("Point.DelRoutes: route %s via %s", route.Prefix, p.IfName())
 	}
-	p.routes = nil
+	p.routes = DiffRoutes(p.routes, routes)
 	return nil
 }
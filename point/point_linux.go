@@ -13,14 +13,19 @@ type Point struct {
 	// private
 	brName string
 	addr   string
+	addr6  string
 	routes []*models.Route
 	link   netlink.Link
 	uuid   string
+	peer   bool
+	policy *Policy
+	dns    *Resolver
 }
 
 func NewPoint(config *config.Point) *Point {
 	p := Point{
 		brName:   config.Interface.Bridge,
+		peer:     config.Peer,
 		MixPoint: NewMixPoint(config),
 	}
 	return &p
@@ -32,19 +37,57 @@ func (p *Point) Initialize() {
 	p.worker.listener.AddRoutes = p.AddRoutes
 	p.worker.listener.DelRoutes = p.DelRoutes
 	p.worker.listener.OnTap = p.OnTap
+	if p.config.Routing != nil {
+		p.policy = NewPolicy(p.config.Routing)
+		p.worker.listener.OnConnect = p.OnConnect
+	}
+	if r := p.config.Resolver; r != nil && r.Mode != "none" {
+		p.dns = NewResolver(r, p.config.Network)
+		p.worker.listener.AddDns = p.AddDns
+		p.worker.listener.DelDns = p.DelDns
+	}
 	p.MixPoint.Initialize()
 }
 
 func (p *Point) Start() {
 	libol.Info("Point.Start: linux.")
+	if p.policy != nil {
+		p.policy.Start()
+	}
 	p.worker.Start()
 }
 
+// OnConnect resolves switch again, and bypasses it by policy.
+func (p *Point) OnConnect() error {
+	p.policy.Update(SwitchHosts(p.config))
+	return nil
+}
+
 func (p *Point) Stop() {
 	defer libol.Catch("Point.Stop")
+	// cleaned even if worker panics.
+	if p.policy != nil {
+		defer p.policy.Stop()
+	}
+	if p.dns != nil {
+		defer p.dns.Restore()
+	}
 	p.worker.Stop()
 }
 
+func (p *Point) AddDns(servers, domains []string) error {
+	if err := p.dns.Apply(p.link, servers, domains); err != nil {
+		libol.Warn("Point.AddDns: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (p *Point) DelDns() error {
+	p.dns.Restore()
+	return nil
+}
+
 func (p *Point) DelAddr(ipStr string) error {
 	if p.link == nil || ipStr == "" {
 		return nil
@@ -58,7 +101,11 @@ func (p *Point) DelAddr(ipStr string) error {
 		libol.Warn("Point.DelAddr.UnsetLinkIp: %s", err)
 	}
 	libol.Info("Point.DelAddr: %s", ipStr)
-	p.addr = ""
+	if ipAddr.IP.To4() == nil {
+		p.addr6 = ""
+	} else {
+		p.addr = ""
+	}
 	return nil
 }
 
@@ -76,7 +123,14 @@ func (p *Point) AddAddr(ipStr string) error {
 		return err
 	}
 	libol.Info("Point.AddAddr: %s", ipStr)
-	p.addr = ipStr
+	if ipAddr.IP.To4() == nil {
+		p.addr6 = ipStr
+	} else {
+		p.addr = ipStr
+		if p.policy != nil {
+			p.policy.AddDefault(p.link)
+		}
+	}
 	return nil
 }
 
@@ -98,9 +152,12 @@ func (p *Point) UpBr(name string) *netlink.Bridge {
 		libol.Error("Point.UpBr: %s %s", name, err)
 		return nil
 	}
-	brCtl := libol.NewBrCtl(name)
-	if err := brCtl.Stp(true); err != nil {
-		libol.Error("Point.UpBr.Stp: %s", err)
+	// stp blocks paths between peers in mesh.
+	if !p.peer {
+		brCtl := libol.NewBrCtl(name)
+		if err := brCtl.Stp(true); err != nil {
+			libol.Error("Point.UpBr.Stp: %s", err)
+		}
 	}
 	if err := netlink.LinkSetUp(link); err != nil {
 		libol.Error("Point.UpBr.newBr.Up: %s", err)
@@ -125,6 +182,11 @@ func (p *Point) OnTap(w *TapWorker) error {
 		if err := netlink.LinkSetMaster(link, br); err != nil {
 			libol.Error("Point.OnTap.AddSlave: Switch dev %s: %s", name, err)
 		}
+		if p.peer {
+			if err := libol.NewBrCtl(p.brName).Isolate(name, true); err != nil {
+				libol.Error("Point.OnTap.Isolate: %s %s", name, err)
+			}
+		}
 		link, err = netlink.LinkByName(p.brName)
 		if err != nil {
 			libol.Error("Point.OnTap: Get dev %s: %s", p.brName, err)
@@ -134,10 +196,21 @@ func (p *Point) OnTap(w *TapWorker) error {
 	return nil
 }
 
+// route returns route on link, and in table of policy if split.
+func (p *Point) route(dst *net.IPNet, nxt net.IP) *netlink.Route {
+	if p.policy != nil {
+		return p.policy.Route(p.link, dst, nxt)
+	}
+	return &netlink.Route{LinkIndex: p.link.Attrs().Index, Dst: dst, Gw: nxt}
+}
+
 func (p *Point) AddRoutes(routes []*models.Route) error {
 	if routes == nil || p.link == nil {
 		return nil
 	}
+	if p.policy != nil {
+		routes = p.policy.Routes(routes)
+	}
 
 	for _, route := range routes {
 		_, dst, err := net.ParseCIDR(route.Prefix)
@@ -145,15 +218,15 @@ func (p *Point) AddRoutes(routes []*models.Route) error {
 			continue
 		}
 		nxt := net.ParseIP(route.NextHop)
-		rte := netlink.Route{LinkIndex: p.link.Attrs().Index, Dst: dst, Gw: nxt}
+		rte := p.route(dst, nxt)
 		libol.Debug("Point.AddRoute: %s", rte)
-		if err := netlink.RouteAdd(&rte); err != nil {
+		if err := netlink.RouteAdd(rte); err != nil {
 			libol.Warn("Point.AddRoute: %s", err)
 			continue
 		}
 		libol.Info("Point.AddRoutes: route %s via %s", route.Prefix, route.NextHop)
 	}
-	p.routes = routes
+	p.routes = append(p.routes, routes...)
 	return nil
 }
 
@@ -161,19 +234,22 @@ func (p *Point) DelRoutes(routes []*models.Route) error {
 	if routes == nil || p.link == nil {
 		return nil
 	}
+	if p.policy != nil {
+		routes = p.policy.Routes(routes)
+	}
 	for _, route := range routes {
 		_, dst, err := net.ParseCIDR(route.Prefix)
 		if err != nil {
 			continue
 		}
 		nxt := net.ParseIP(route.NextHop)
-		rte := netlink.Route{LinkIndex: p.link.Attrs().Index, Dst: dst, Gw: nxt}
-		if err := netlink.RouteDel(&rte); err != nil {
+		rte := p.route(dst, nxt)
+		if err := netlink.RouteDel(rte); err != nil {
 			libol.Warn("Point.DelRoute: %s", err)
 			continue
 		}
 		libol.Info("Point.DelRoutes: route %s via %s", route.Prefix, route.NextHop)
 	}
-	p.routes = nil
+	p.routes = DiffRoutes(p.routes, routes)
 	return nil
 }
@@ -56,6 +56,10 @@ func (p *Point) OnTap(w *TapWorker) error {
 }
 
 func (p *Point) AddAddr(ipStr string) error {
+	if strings.Contains(ipStr, ":") {
+		libol.Warn("Point.AddAddr: ipv6 %s not supported", ipStr)
+		return nil
+	}
 	if ipStr == "" {
 		return nil
 	}
@@ -76,6 +80,10 @@ func (p *Point) AddAddr(ipStr string) error {
 }
 
 func (p *Point) DelAddr(ipStr string) error {
+	if strings.Contains(ipStr, ":") {
+		libol.Warn("Point.DelAddr: ipv6 %s not supported", ipStr)
+		return nil
+	}
 	ipv4 := strings.Split(ipStr, "/")[0]
 	out, err := libol.IpAddrDel(p.IfName(), ipv4)
 	if err != nil {
@@ -91,7 +99,6 @@ func (p *Point) AddRoutes(routes []*models.Route) error {
 	if routes == nil {
 		return nil
 	}
-	_ = libol.MarshalSave(routes, ".routes.json", true)
 	for _, route := range routes {
 		out, err := libol.IpRouteAdd(p.IfName(), route.Prefix, route.NextHop)
 		if err != nil {
@@ -100,7 +107,8 @@ func (p *Point) AddRoutes(routes []*models.Route) error {
 		}
 		libol.Info("Point.AddRoutes: route %s via %s", route.Prefix, route.NextHop)
 	}
-	p.routes = routes
+	p.routes = append(p.routes, routes...)
+	_ = libol.MarshalSave(p.routes, ".routes.json", true)
 	return nil
 }
 
@@ -113,6 +121,7 @@ func (p *Point) DelRoutes(routes []*models.Route) error {
 		}
 		libol.Info("Point.DelRoutes: route %s via %s", route.Prefix, route.NextHop)
 	}
-	p.routes = nil
+	p.routes = DiffRoutes(p.routes, routes)
+	_ = libol.MarshalSave(p.routes, ".routes.json", true)
 	return nil
 }
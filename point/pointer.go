@@ -3,6 +3,7 @@ package point
 import (
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/network"
 )
 
@@ -74,10 +75,30 @@ func (p *MixPoint) UpTime() int64 {
 	return p.worker.UpTime()
 }
 
+func (p *MixPoint) Reconnects() uint64 {
+	return p.worker.Reconnects()
+}
+
 func (p *MixPoint) IfAddr() string {
 	return p.worker.ifAddr
 }
 
+// SetRouter sets functions to get routes advertised to switch, and to
+// learn routes advertised by it.
+func (p *MixPoint) SetRouter(advertise func() []*models.Route, learn func(routes []*models.Route) error) {
+	p.worker.listener.Advertise = advertise
+	p.worker.listener.OnRoutes = learn
+}
+
+func (p *MixPoint) Advertise() {
+	p.worker.Advertise()
+}
+
+// SetMux sets session to share connection with points of other networks.
+func (p *MixPoint) SetMux(mux *libol.MuxSession) {
+	p.worker.mux = mux
+}
+
 func (p *MixPoint) Tenant() string {
 	return p.tenant
 }
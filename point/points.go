@@ -0,0 +1,23 @@
+package point
+
+import (
+	"github.com/danieldin95/openlan-go/main/config"
+)
+
+// NewPoints returns a point for each network joined over one connection,
+// or only one point if networks not configured.
+func NewPoints(c *config.Point) []*Point {
+	if len(c.Networks) == 0 {
+		return []*Point{NewPoint(c)}
+	}
+	mux := GetMuxSession(c)
+	points := make([]*Point, 0, len(c.Networks))
+	for _, pc := range c.Split() {
+		p := NewPoint(pc)
+		if mux != nil {
+			p.SetMux(mux)
+		}
+		points = append(points, p)
+	}
+	return points
+}
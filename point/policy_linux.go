@@ -0,0 +1,226 @@
+package point
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/vishvananda/netlink"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// Policy is split tunnelling of point, and routes are installed into a
+// table of its own which is looked up by rule of priority. Rules looking
+// up main table are one before it for prefixes excluded, for addresses of
+// switch, and for routes more specific than default if default.
+type Policy struct {
+	cfg   *config.Routing
+	hosts []net.IP // switch connected to, resolved before connecting.
+}
+
+func NewPolicy(cfg *config.Routing) *Policy {
+	return &Policy{cfg: cfg}
+}
+
+// SwitchHosts resolves addresses of switch, and proxy if tunneled.
+func SwitchHosts(c *config.Point) []net.IP {
+	addrs := []string{c.Connection}
+	for _, ep := range c.Endpoints {
+		addrs = append(addrs, ep.Connection)
+	}
+	if c.Bond != nil {
+		for _, path := range c.Bond.Paths {
+			addrs = append(addrs, path.Connection)
+		}
+	}
+	if c.Proxy != "" {
+		if u, err := url.Parse(c.Proxy); err == nil {
+			addrs = []string{u.Host}
+		}
+	}
+	hosts := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			libol.Warn("SwitchHosts: %s", err)
+			continue
+		}
+		hosts = append(hosts, ips...)
+	}
+	return hosts
+}
+
+func hostNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+func (r *Policy) addRule(rule *netlink.Rule) {
+	if err := netlink.RuleAdd(rule); err != nil {
+		libol.Warn("Policy.addRule: %s: %s", rule, err)
+		return
+	}
+	libol.Info("Policy.addRule: %s to %s", rule, rule.Dst)
+}
+
+func (r *Policy) hostRule(ip net.IP) *netlink.Rule {
+	rule := netlink.NewRule()
+	rule.Dst = hostNet(ip)
+	rule.Table = syscall.RT_TABLE_MAIN
+	rule.Priority = r.cfg.Priority - 1
+	return rule
+}
+
+// Start cleans what left by previous, and adds rules. Rules of switch
+// are added by update before connecting.
+func (r *Policy) Start() {
+	r.Clean()
+	r.hosts = nil
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		rule := netlink.NewRule()
+		rule.Family = family
+		rule.Table = r.cfg.Table
+		rule.Priority = r.cfg.Priority
+		r.addRule(rule)
+		if r.cfg.Default {
+			rule := netlink.NewRule()
+			rule.Family = family
+			rule.Table = syscall.RT_TABLE_MAIN
+			rule.Priority = r.cfg.Priority - 1
+			rule.SuppressPrefixlen = 0
+			r.addRule(rule)
+		}
+	}
+	for _, prefix := range r.cfg.Exclude {
+		_, dst, err := net.ParseCIDR(prefix)
+		if err != nil {
+			libol.Warn("Policy.Start: %s", err)
+			continue
+		}
+		rule := netlink.NewRule()
+		rule.Dst = dst
+		rule.Table = syscall.RT_TABLE_MAIN
+		rule.Priority = r.cfg.Priority - 1
+		r.addRule(rule)
+	}
+}
+
+func hasIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Update replaces rules of switch by hosts resolved again, and it's
+// called before connecting or failing over to other endpoint.
+func (r *Policy) Update(hosts []net.IP) {
+	if !r.cfg.Default {
+		r.hosts = hosts
+		return
+	}
+	for _, ip := range r.hosts {
+		if hasIP(hosts, ip) {
+			continue
+		}
+		rule := r.hostRule(ip)
+		if err := netlink.RuleDel(rule); err != nil {
+			libol.Warn("Policy.Update: %s: %s", rule, err)
+			continue
+		}
+		libol.Info("Policy.Update: del %s", rule.Dst)
+	}
+	for _, ip := range hosts {
+		if !hasIP(r.hosts, ip) {
+			r.addRule(r.hostRule(ip))
+		}
+	}
+	r.hosts = hosts
+}
+
+// Clean removes rules of our priorities, and flushes our table.
+func (r *Policy) Clean() {
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		rules, err := netlink.RuleList(family)
+		if err != nil {
+			libol.Warn("Policy.Clean: %s", err)
+			continue
+		}
+		for _, rule := range rules {
+			if rule.Priority != r.cfg.Priority && rule.Priority != r.cfg.Priority-1 {
+				continue
+			}
+			del := netlink.NewRule()
+			del.Family = family
+			del.Priority = rule.Priority
+			del.Table = rule.Table
+			del.Dst = rule.Dst
+			if err := netlink.RuleDel(del); err != nil {
+				libol.Warn("Policy.Clean: %s: %s", rule, err)
+				continue
+			}
+			libol.Info("Policy.Clean: %s to %s", rule, rule.Dst)
+		}
+	}
+	filter := &netlink.Route{Table: r.cfg.Table}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, filter, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		libol.Warn("Policy.Clean: %s", err)
+		return
+	}
+	for _, rte := range routes {
+		if err := netlink.RouteDel(&rte); err != nil {
+			libol.Warn("Policy.Clean: %s: %s", rte, err)
+			continue
+		}
+		libol.Info("Policy.Clean: %s", rte)
+	}
+}
+
+// Stop removes all installed.
+func (r *Policy) Stop() {
+	r.Clean()
+}
+
+// Routes returns routes accepted by include and exclude.
+func (r *Policy) Routes(routes []*models.Route) []*models.Route {
+	return FilterRoutes(routes, r.cfg.Include, r.cfg.Exclude)
+}
+
+// Route returns route on link in our table.
+func (r *Policy) Route(link netlink.Link, dst *net.IPNet, gw net.IP) *netlink.Route {
+	return &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       dst,
+		Gw:        gw,
+		Table:     r.cfg.Table,
+	}
+}
+
+// AddDefault adds default route via gateway, and it's reachable after
+// address of link is added.
+func (r *Policy) AddDefault(link netlink.Link) {
+	if !r.cfg.Default {
+		return
+	}
+	gw := net.ParseIP(r.cfg.Gateway)
+	if gw == nil || gw.To4() == nil {
+		libol.Warn("Policy.AddDefault: invalid gateway '%s'", r.cfg.Gateway)
+		return
+	}
+	_, dst, _ := net.ParseCIDR("0.0.0.0/0")
+	if err := netlink.RouteReplace(r.Route(link, dst, gw)); err != nil {
+		libol.Warn("Policy.AddDefault: %s", err)
+		return
+	}
+	libol.Info("Policy.AddDefault: via %s table %d", gw, r.cfg.Table)
+}
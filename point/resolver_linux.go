@@ -0,0 +1,133 @@
+package point
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/vishvananda/netlink"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	resolvedDest = "org.freedesktop.resolve1"
+	resolvedPath = "/org/freedesktop/resolve1"
+	resolvedIf   = "org.freedesktop.resolve1.Manager"
+)
+
+// Resolver applies dns pushed by switch, per link by systemd-resolved
+// over D-Bus, or by resolv.conf with a stub for split domains. And the
+// original is restored on exit.
+type Resolver struct {
+	cfg   *config.Resolver
+	conf  *libol.ResolvConf
+	index int // link applied by resolved.
+}
+
+func NewResolver(cfg *config.Resolver, network string) *Resolver {
+	r := &Resolver{cfg: cfg, conf: libol.NewResolvConf(cfg.File, network)}
+	// left by previous if not exited cleanly.
+	if err := r.conf.Restore(); err != nil {
+		libol.Warn("NewResolver: %s", err)
+	}
+	return r
+}
+
+// HasResolved returns true if systemd-resolved is running.
+func HasResolved() bool {
+	if _, err := exec.LookPath("busctl"); err != nil {
+		return false
+	}
+	if _, err := os.Stat("/run/systemd/resolve"); err != nil {
+		return false
+	}
+	return true
+}
+
+func busctl(method, signature string, args ...string) error {
+	cmd := append([]string{"call", resolvedDest, resolvedPath, resolvedIf, method, signature}, args...)
+	if out, err := exec.Command("busctl", cmd...).CombinedOutput(); err != nil {
+		return libol.NewErr("%s: %s", err, out)
+	}
+	return nil
+}
+
+// ResolvedArgs returns arguments of SetLinkDNS and SetLinkDomains, and
+// all names are routed to link if no domains.
+func ResolvedArgs(index int, servers, domains []string) ([]string, []string) {
+	link := strconv.Itoa(index)
+	dns := []string{link, ""}
+	count := 0
+	for _, server := range servers {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			dns = append(dns, "2", "4")
+			ip = ip4
+		} else {
+			dns = append(dns, "10", "16")
+		}
+		for _, b := range ip {
+			dns = append(dns, strconv.Itoa(int(b)))
+		}
+		count++
+	}
+	dns[1] = strconv.Itoa(count)
+	search := []string{link, strconv.Itoa(len(domains))}
+	for _, domain := range domains {
+		search = append(search, domain, "false")
+	}
+	if len(domains) == 0 {
+		search = []string{link, "1", ".", "true"}
+	}
+	return dns, search
+}
+
+func (r *Resolver) applyResolved(link netlink.Link, servers, domains []string) error {
+	index := link.Attrs().Index
+	dns, search := ResolvedArgs(index, servers, domains)
+	if err := busctl("SetLinkDNS", "ia(iay)", dns...); err != nil {
+		return err
+	}
+	r.index = index
+	return busctl("SetLinkDomains", "ia(sb)", search...)
+}
+
+// Apply applies servers and domains by mode.
+func (r *Resolver) Apply(link netlink.Link, servers, domains []string) error {
+	mode := r.cfg.Mode
+	if mode == "auto" {
+		mode = "resolvconf"
+		if HasResolved() {
+			mode = "resolved"
+		}
+	}
+	libol.Info("Resolver.Apply: %s %s by %s", servers, domains, mode)
+	switch mode {
+	case "resolved":
+		if link == nil {
+			return libol.NewErr("no link to apply")
+		}
+		return r.applyResolved(link, servers, domains)
+	case "resolvconf":
+		return r.conf.Apply(servers, domains, r.cfg.Stub)
+	}
+	return nil
+}
+
+// Restore reverts link by resolved, or writes back resolv.conf.
+func (r *Resolver) Restore() {
+	if r.index > 0 {
+		if err := busctl("RevertLink", "i", strconv.Itoa(r.index)); err != nil {
+			libol.Warn("Resolver.Restore: %s", err)
+		}
+		libol.Info("Resolver.Restore: link %d", r.index)
+		r.index = 0
+	}
+	if err := r.conf.Restore(); err != nil {
+		libol.Warn("Resolver.Restore: %s", err)
+	}
+}
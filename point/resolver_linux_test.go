@@ -0,0 +1,15 @@
+package point
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestResolvedArgs(t *testing.T) {
+	dns, search := ResolvedArgs(5, []string{"10.0.0.2", "bad", "fd00::1"}, []string{"corp.lan"})
+	assert.Equal(t, []string{"5", "2", "2", "4", "10", "0", "0", "2",
+		"10", "16", "253", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "0", "1"}, dns, "be the same.")
+	assert.Equal(t, []string{"5", "1", "corp.lan", "false"}, search, "be the same.")
+	_, search = ResolvedArgs(5, []string{"10.0.0.2"}, nil)
+	assert.Equal(t, []string{"5", "1", ".", "true"}, search, "all names.")
+}
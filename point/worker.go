@@ -2,13 +2,14 @@ package point
 
 import (
 	"bytes"
-	"crypto/tls"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
 	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/network"
+	"github.com/danieldin95/openlan-go/network/stack"
 	"github.com/danieldin95/openlan-go/point/http"
 	"net"
 	"strings"
@@ -32,6 +33,8 @@ type SocketWorkerListener struct {
 	OnClose   func(w *SocketWorker) error
 	OnSuccess func(w *SocketWorker) error
 	OnIpAddr  func(w *SocketWorker, n *models.Network) error
+	OnRoute   func(w *SocketWorker, n *models.Network) error
+	OnConnect func(w *SocketWorker) error // before connecting.
 	ReadAt    func(frame *libol.FrameMessage) error
 }
 
@@ -68,12 +71,22 @@ type jobTimer struct {
 }
 
 type recordTime struct {
-	last      int64 // record time last frame received or connected.
-	connected int64 // record last connected time.
-	reconnect int64 // record time when triggered reconnected.
-	sleeps    int   // record times to control connecting delay.
-	closed    int64
-	live      int64 // record received pong frame time.
+	last       int64  // record time last frame received or connected.
+	connected  int64  // record last connected time.
+	reconnect  int64  // record time when triggered reconnected.
+	sleeps     int    // record times to control connecting delay.
+	reconnects uint64 // record times triggered reconnected.
+	waiting    bool   // reconnecting is waiting in jober.
+	closed     int64
+	live       int64 // record received pong frame time.
+	failed     int64 // record time connecting failed first since connected.
+}
+
+// failback records endpoint preferred probed to be reachable.
+type failback struct {
+	index int   // index of endpoint reachable.
+	since int64 // time reachable since.
+	next  int64 // time to probe next.
 }
 type SocketWorker struct {
 	// private
@@ -91,6 +104,11 @@ type SocketWorker struct {
 	writeQueue chan *libol.FrameMessage
 	jober      []jobTimer
 	record     recordTime
+	endpoints  []*config.Endpoint
+	active     int // index of endpoint connected.
+	failovers  []models.Failover
+	failback   failback
+	failed     error // connecting failed last.
 }
 
 func NewSocketWorker(client libol.SocketClient, c *config.Point) (t *SocketWorker) {
@@ -110,22 +128,36 @@ func NewSocketWorker(client libol.SocketClient, c *config.Point) (t *SocketWorke
 			LastTime: time.Now().Unix(),
 		},
 		pointCfg:   c,
+		endpoints:  c.Endpoints,
+		failovers:  make([]models.Failover, 0, 32),
 		eventQueue: make(chan socketEvent, 32),
 		writeQueue: make(chan *libol.FrameMessage, 1024),
 		jober:      make([]jobTimer, 0, 32),
 	}
 	t.user.Alias = c.Alias
 	t.user.Network = c.Network
+	t.user.Link = c.Link
+	t.user.Peer = c.Peer
+	if bond, ok := client.(*libol.BondClient); ok {
+		t.user.Bond = bond.Mode()
+		t.user.Session = bond.Session()
+		t.endpoints = nil // failover is not bonded.
+	}
 
 	return
 }
 
+// sleepNow returns secs to delay connecting, which is doubled by times
+// failed and up to 64s.
 func (t *SocketWorker) sleepNow() int64 {
-	return int64(t.record.sleeps * 5)
+	if t.record.sleeps == 0 {
+		return 0
+	}
+	return int64(1) << uint(t.record.sleeps)
 }
 
 func (t *SocketWorker) sleepIdle() int64 {
-	if t.record.sleeps < 20 {
+	if t.record.sleeps < 6 {
 		t.record.sleeps++
 	}
 	return t.sleepNow()
@@ -139,10 +171,16 @@ func (t *SocketWorker) Initialize() {
 		return
 	}
 	libol.Info("SocketWorker.Initialize")
+	t.setClient(t.client)
+}
+
+func (t *SocketWorker) setClient(client libol.SocketClient) {
+	t.client = client
 	t.client.SetMaxSize(t.pointCfg.Interface.IfMtu)
 	t.client.SetListener(libol.ClientListener{
 		OnConnected: func(client libol.SocketClient) error {
 			t.record.connected = time.Now().Unix()
+			t.record.failed = 0
 			t.eventQueue <- NewEvent(EventConed, "from socket")
 			return nil
 		},
@@ -157,7 +195,9 @@ func (t *SocketWorker) Initialize() {
 func (t *SocketWorker) Start() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	_ = t.connect()
+	if err := t.connect(); err != nil {
+		t.reconnect()
+	}
 	libol.Go(t.Loop)
 }
 
@@ -215,24 +255,41 @@ func (t *SocketWorker) connect() error {
 		libol.Warn("SocketWorker.connect %s %d->%d", t.client, s, libol.ClInit)
 		t.client.SetStatus(libol.ClInit)
 	}
-	if err := t.client.Connect(); err != nil {
-		libol.Error("SocketWorker.connect %s %s", t.client, err)
-		return err
+	if t.listener.OnConnect != nil {
+		_ = t.listener.OnConnect(t)
+	}
+	t.failed = t.client.Connect()
+	if t.failed != nil {
+		if _, ok := t.failed.(*libol.ProxyError); ok {
+			libol.Error("SocketWorker.connect %s by proxy: %s", t.client, t.failed)
+		} else {
+			libol.Error("SocketWorker.connect %s %s", t.client, t.failed)
+		}
+		return t.failed
 	}
 	return nil
 }
 
 func (t *SocketWorker) reconnect() {
-	if t.isStopped() {
+	if t.isStopped() || t.record.waiting {
 		return
 	}
+	t.record.waiting = true
 	t.record.reconnect = time.Now().Unix()
+	t.record.reconnects++
 	t.jober = append(t.jober, jobTimer{
 		Time: time.Now().Unix() + t.sleepIdle(),
 		Call: func() error {
 			libol.Debug("SocketWorker.reconnect: on jober")
+			t.record.waiting = false
 			if t.record.connected < t.record.reconnect { // already connected after.
-				return t.connect()
+				if err := t.connect(); err != nil {
+					if t.unreachable(time.Now().Unix()) {
+						t.failover(t.nextEndpoint(), "failed")
+					}
+					t.reconnect() // try again later.
+					return err
+				}
 			} else {
 				libol.Info("SocketWorker.reconnect: dissed by waked up")
 			}
@@ -271,6 +328,22 @@ func (t *SocketWorker) toNetwork(client libol.SocketClient) error {
 	return nil
 }
 
+// route request to advertise routes of switch.
+func (t *SocketWorker) toRoute(client libol.SocketClient, routes []*models.Route) error {
+	n := models.Network{Name: t.user.Network, Routes: routes}
+	body, err := json.Marshal(n)
+	if err != nil {
+		libol.Error("SocketWorker.toRoute: %s", err)
+		return err
+	}
+	libol.Cmd("SocketWorker.toRoute: %s", body)
+	if err := client.WriteReq("route", string(body)); err != nil {
+		libol.Error("SocketWorker.toRoute: %s", err)
+		return err
+	}
+	return nil
+}
+
 func (t *SocketWorker) onLogin(resp string) error {
 	if strings.HasPrefix(resp, "okay") {
 		t.client.SetStatus(libol.ClAuth)
@@ -304,6 +377,17 @@ func (t *SocketWorker) onIpAddr(resp string) error {
 	return nil
 }
 
+func (t *SocketWorker) onRoute(resp string) error {
+	n := &models.Network{}
+	if err := json.Unmarshal([]byte(resp), n); err != nil {
+		return libol.NewErr("SocketWorker.onRoute: Invalid json data.")
+	}
+	if t.listener.OnRoute != nil {
+		_ = t.listener.OnRoute(t, n)
+	}
+	return nil
+}
+
 func (t *SocketWorker) onLeft(resp string) error {
 	client := t.client
 	libol.Info("SocketWorker.onLeft: %s %s", client.String(), resp)
@@ -332,6 +416,8 @@ func (t *SocketWorker) onInstruct(frame *libol.FrameMessage) error {
 		return t.onLogin(resp)
 	case "ipad:":
 		return t.onIpAddr(resp)
+	case "rout=", "rout:":
+		return t.onRoute(resp)
 	case "pong:":
 		t.record.live = time.Now().Unix()
 	case "sign=":
@@ -375,17 +461,27 @@ func (t *SocketWorker) doTicker() error {
 		}
 	}
 
+	if len(t.endpoints) > 1 {
+		t.deadCheck()
+		t.toFailback()
+	}
+
 	// travel jober and execute expired.
 	now := time.Now().Unix()
 	newTimer := make([]jobTimer, 0, 32)
+	expired := make([]jobTimer, 0, 32)
 	for _, t := range t.jober {
 		if now >= t.Time {
-			_ = t.Call()
+			expired = append(expired, t)
 		} else {
 			newTimer = append(newTimer, t)
 		}
 	}
+	// jober may be added by calls.
 	t.jober = newTimer
+	for _, t := range expired {
+		_ = t.Call()
+	}
 	libol.Debug("SocketWorker.doTicker %d", len(t.jober))
 	return nil
 }
@@ -430,16 +526,20 @@ func (t *SocketWorker) isStopped() bool {
 }
 
 func (t *SocketWorker) Read() {
-	libol.Info("SocketWorker.Read: %s", t.client)
+	t.lock.Lock()
+	client := t.client
+	t.lock.Unlock()
+	libol.Info("SocketWorker.Read: %s", client)
 	for {
 		t.lock.Lock()
-		if t.isStopped() || !t.client.IsOk() {
-			libol.Error("SocketWorker.Read: %v", t.client)
+		// client is replaced by failover.
+		if t.isStopped() || client != t.client || !client.IsOk() {
+			libol.Error("SocketWorker.Read: %v", client)
 			t.lock.Unlock()
 			break
 		}
 		t.lock.Unlock()
-		data, err := t.client.ReadMsg()
+		data, err := client.ReadMsg()
 		t.lock.Lock()
 		if err != nil {
 			libol.Error("SocketWorker.Read: %s", err)
@@ -460,7 +560,10 @@ func (t *SocketWorker) Read() {
 		}
 		t.lock.Unlock()
 	}
-	if !t.isStopped() {
+	t.lock.Lock()
+	replaced := client != t.client
+	t.lock.Unlock()
+	if !t.isStopped() && !replaced {
 		t.eventQueue <- NewEvent(EventRecon, "from read")
 	}
 	libol.Info("SocketWorker.Read: exit")
@@ -470,11 +573,165 @@ func (t *SocketWorker) deadCheck() {
 	dt := time.Now().Unix() - t.record.last
 	if dt > int64(t.pointCfg.Timeout) {
 		libol.Warn("SocketWorker.deadCheck: %s idle %ds", t.client, dt)
+		if t.client.Have(libol.ClAuth) || t.client.Have(libol.ClConnected) {
+			t.failover(t.nextEndpoint(), "dead")
+		}
 		t.eventQueue <- NewEvent(EventRecon, "from dead check")
 		t.record.last = time.Now().Unix()
 	}
 }
 
+// unreachable records connecting failed, and returns true if the active
+// endpoint is failed to connect for timeout.
+func (t *SocketWorker) unreachable(now int64) bool {
+	if t.record.failed == 0 {
+		t.record.failed = now
+	}
+	return now-t.record.failed >= int64(t.pointCfg.Timeout)
+}
+
+// nextEndpoint returns index of endpoint after active one.
+func (t *SocketWorker) nextEndpoint() int {
+	if len(t.endpoints) == 0 {
+		return 0
+	}
+	return (t.active + 1) % len(t.endpoints)
+}
+
+// failover replaces client by one connecting to the endpoint, and the
+// user keeps the same uuid.
+func (t *SocketWorker) failover(index int, reason string) {
+	if index == t.active || index >= len(t.endpoints) {
+		return
+	}
+	from, to := t.endpoints[t.active], t.endpoints[index]
+	libol.Warn("SocketWorker.failover: %s->%s by %s", from.Connection, to.Connection, reason)
+	if len(t.failovers) >= 32 {
+		t.failovers = t.failovers[1:]
+	}
+	t.failovers = append(t.failovers, models.Failover{
+		From:   from.Connection,
+		To:     to.Connection,
+		Reason: reason,
+		Time:   time.Now().Unix(),
+	})
+	t.active = index
+	t.failback = failback{}
+	t.record.failed = 0
+	cfg := *t.pointCfg
+	cfg.Connection = to.Connection
+	cfg.Protocol = to.Protocol
+	old := t.client
+	t.setClient(GetSocketClient(&cfg))
+	old.Terminal()
+}
+
+// probe returns index of the first endpoint reachable before active, and
+// only endpoints over tcp can be probed. It dials as the client of the
+// endpoint does, and by the proxy if given.
+func probe(c *config.Point, endpoints []*config.Endpoint, active int) int {
+	for i := 0; i < active && i < len(endpoints); i++ {
+		ep := endpoints[i]
+		if ep.Protocol == "udp" || ep.Protocol == "kcp" {
+			continue
+		}
+		addr := ep.Connection
+		tcpCfg := &libol.TcpConfig{
+			Timeout: 5 * time.Second,
+			Local:   c.Local,
+			Proxy:   c.Proxy,
+		}
+		if ep.Protocol == "ws" || ep.Protocol == "wss" {
+			// websocket is not tunneled by proxy.
+			addr = libol.WsHost(addr)
+			tcpCfg = &libol.TcpConfig{Timeout: tcpCfg.Timeout}
+		}
+		conn, err := libol.DialTcp(addr, tcpCfg)
+		if err != nil {
+			continue
+		}
+		_ = conn.Close()
+		return i
+	}
+	return -1
+}
+
+// toFailback probes endpoints preferred in background.
+func (t *SocketWorker) toFailback() {
+	now := time.Now().Unix()
+	if t.active == 0 || !t.client.Have(libol.ClAuth) || now < t.failback.next {
+		return
+	}
+	t.failback.next = now + 10
+	cfg, endpoints, active := t.pointCfg, t.endpoints, t.active
+	libol.Go(func() {
+		index := probe(cfg, endpoints, active)
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		t.onProbe(index, time.Now().Unix())
+	})
+}
+
+// onProbe fails back if endpoint preferred is reachable for secs.
+func (t *SocketWorker) onProbe(index int, now int64) {
+	if index < 0 || index >= t.active {
+		t.failback.since = 0
+		return
+	}
+	if index != t.failback.index || t.failback.since == 0 {
+		t.failback.index = index
+		t.failback.since = now
+	}
+	if now-t.failback.since < int64(t.pointCfg.Failback) {
+		return
+	}
+	t.failover(index, "failback")
+	t.reconnect()
+}
+
+// Endpoints returns switches to connect and which is active.
+func (t *SocketWorker) Endpoints() []models.Endpoint {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	eps := make([]models.Endpoint, 0, len(t.endpoints))
+	for i, ep := range t.endpoints {
+		obj := models.Endpoint{
+			Connection: ep.Connection,
+			Protocol:   ep.Protocol,
+			Priority:   ep.Priority,
+			Active:     i == t.active,
+		}
+		if i == t.failback.index && t.failback.since != 0 {
+			obj.Stable = time.Now().Unix() - t.failback.since
+		}
+		eps = append(eps, obj)
+	}
+	return eps
+}
+
+// State returns state of client, or why proxy failed if not connected.
+func (t *SocketWorker) State() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.client == nil {
+		return ""
+	}
+	state := t.client.State()
+	if t.client.Have(libol.ClConnected) || t.client.Have(libol.ClAuth) {
+		return state
+	}
+	if err, ok := t.failed.(*libol.ProxyError); ok {
+		return "proxy " + err.Op + " failed"
+	}
+	return state
+}
+
+func (t *SocketWorker) Failovers() []models.Failover {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]models.Failover{}, t.failovers...)
+}
+
 func (t *SocketWorker) DoWrite(frame *libol.FrameMessage) error {
 	if libol.HasLog(libol.DEBUG) {
 		libol.Debug("SocketWorker.DoWrite: %x", frame)
@@ -534,8 +791,11 @@ type TapWorkerListener struct {
 }
 
 type TunEther struct {
-	HwAddr []byte
-	IpAddr []byte
+	HwAddr  []byte
+	IpAddr  []byte
+	Ip6Addr []byte   // global ipv6 address if configured.
+	Ip6Link []byte   // link-local address from hardware address.
+	Ip6Host [][]byte // addresses on device, cached when configured.
 }
 
 type TapWorker struct {
@@ -571,8 +831,8 @@ func (a *TapWorker) Initialize() {
 
 	libol.Info("TapWorker.Initialize")
 	a.neighbor = Neighbors{
-		neighbors: make(map[uint32]*Neighbor, 1024),
-		done:      make(chan bool),
+		neighbors: make(map[string]*Neighbor, 1024),
+		done:      make(chan bool, 2),
 		ticker:    time.NewTicker(5 * time.Second),
 		timeout:   3 * 60,
 		interval:  60,
@@ -589,6 +849,7 @@ func (a *TapWorker) Initialize() {
 	if a.device != nil && a.device.IsTun() {
 		a.setEther(a.pointCfg.Interface.Address)
 		a.ether.HwAddr = libol.GenEthAddr(6)
+		a.ether.Ip6Link = libol.Ip6LinkLocal(a.ether.HwAddr)
 		libol.Info("TapWorker.Initialize: src %x", a.ether.HwAddr)
 	}
 }
@@ -613,6 +874,39 @@ func (a *TapWorker) setEther(addr string) {
 	a.ifAddr = addr
 }
 
+// setEther6 sets global ipv6 address to answer neighbor solicitation.
+func (a *TapWorker) setEther6(addr string) {
+	ifAddr := strings.SplitN(addr, "/", 2)[0]
+	a.ether.Ip6Addr = net.ParseIP(ifAddr).To16()
+}
+
+// hostAddr6 returns ipv6 addresses on device.
+func (a *TapWorker) hostAddr6() [][]byte {
+	addrs := make([][]byte, 0, 4)
+	if a.device == nil {
+		return addrs
+	}
+	ifc, err := net.InterfaceByName(a.device.Name())
+	if err != nil {
+		return addrs
+	}
+	ifAddrs, _ := ifc.Addrs()
+	for _, ifAddr := range ifAddrs {
+		if ipNet, ok := ifAddr.(*net.IPNet); ok && ipNet.IP.To4() == nil {
+			addrs = append(addrs, ipNet.IP.To16())
+		}
+	}
+	return addrs
+}
+
+// cacheAddr6 caches ipv6 addresses on device after they're configured,
+// and they're not looked up on the packet path.
+func (a *TapWorker) cacheAddr6() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.ether.Ip6Host = a.hostAddr6()
+}
+
 func (a *TapWorker) open() {
 	if a.device != nil {
 		_ = a.device.Close()
@@ -620,13 +914,23 @@ func (a *TapWorker) open() {
 			time.Sleep(5 * time.Second) // sleep 5s and release cpu.
 		}
 	}
-	device, err := network.NewKernelTap(a.pointCfg.Network, a.deviceCfg)
+	var device network.Taper
+	var err error
+	if a.pointCfg.Interface.Provider == "userspace" {
+		device, err = network.NewUserSpaceTap(a.pointCfg.Network, a.deviceCfg)
+	} else {
+		device, err = network.NewKernelTap(a.pointCfg.Network, a.deviceCfg)
+	}
 	if err != nil {
 		libol.Error("TapWorker.open: %s", err)
 		return
 	}
+	if a.pointCfg.Interface.Provider == "userspace" {
+		device.Up()
+	}
 	libol.Info("TapWorker.open: >>>> %s <<<<", device.Name())
 	a.device = device
+	a.ether.Ip6Host = a.hostAddr6()
 	if a.listener.OnOpen != nil {
 		_ = a.listener.OnOpen(a)
 	}
@@ -648,6 +952,10 @@ func (a *TapWorker) OnArpAlive(dest []byte) {
 // process if ethernet destination is missed
 func (a *TapWorker) onMiss(dest []byte) {
 	libol.Debug("TapWorker.onMiss: %v.", dest)
+	if len(dest) == net.IPv6len {
+		a.onMiss6(dest)
+		return
+	}
 	eth := a.newEth(libol.EthArp, libol.BROADED)
 	reply := libol.NewArp()
 	reply.OpCode = libol.ArpRequest
@@ -668,22 +976,40 @@ func (a *TapWorker) onMiss(dest []byte) {
 func (a *TapWorker) onFrame(frame *libol.FrameMessage, data []byte) int {
 	size := len(data)
 	if a.device.IsTun() {
-		iph, err := libol.NewIpv4FromFrame(data)
-		if err != nil {
-			libol.Warn("TapWorker.onFrame: %s", err)
-			return 0
-		}
-		dest := iph.Destination
-		if a.listener.FindNext != nil {
-			dest = a.listener.FindNext(dest)
+		var dest []byte
+		ethType := uint16(libol.EthIp4)
+		if len(data) > 0 && data[0]>>4 == libol.Ipv6Ver {
+			iph, err := libol.NewIpv6FromFrame(data)
+			if err != nil {
+				libol.Warn("TapWorker.onFrame: %s", err)
+				return 0
+			}
+			dest = iph.Destination
+			ethType = libol.EthIp6
+		} else {
+			iph, err := libol.NewIpv4FromFrame(data)
+			if err != nil {
+				libol.Warn("TapWorker.onFrame: %s", err)
+				return 0
+			}
+			dest = iph.Destination
 		}
-		neb := a.neighbor.GetByBytes(dest)
-		if neb == nil {
-			a.onMiss(dest)
-			libol.Debug("TapWorker.onFrame: onMiss neighbor %v", dest)
-			return 0
+		var hwAddr []byte
+		if ethType == libol.EthIp6 && dest[0] == 0xff {
+			hwAddr = libol.Ip6MulticastHw(dest)
+		} else {
+			if a.listener.FindNext != nil {
+				dest = a.listener.FindNext(dest)
+			}
+			neb := a.neighbor.GetByBytes(dest)
+			if neb == nil {
+				a.onMiss(dest)
+				libol.Debug("TapWorker.onFrame: onMiss neighbor %v", dest)
+				return 0
+			}
+			hwAddr = neb.HwAddr
 		}
-		eth := a.newEth(libol.EthIp4, neb.HwAddr)
+		eth := a.newEth(ethType, hwAddr)
 		frame.Append(eth.Encode()) // insert ethernet header.
 		size += eth.Len
 	}
@@ -764,16 +1090,22 @@ func (a *TapWorker) DoWrite(frame *libol.FrameMessage) error {
 			a.lock.Unlock()
 			return nil
 		}
+		// proxy neighbor solicitation.
+		if a.toNdp(data) {
+			libol.Debug("TapWorker.Loop: Ndp proxy.")
+			a.lock.Unlock()
+			return nil
+		}
 		eth, err := libol.NewEtherFromFrame(data)
 		if err != nil {
 			libol.Error("TapWorker.Loop: %s", err)
 			a.lock.Unlock()
 			return nil
 		}
-		if eth.IsIP4() {
+		if eth.IsIP4() || eth.IsIP6() {
 			data = data[14:]
 		} else {
-			libol.Debug("TapWorker.Loop: 0x%04x not IPv4", eth.Type)
+			libol.Debug("TapWorker.Loop: 0x%04x not IP", eth.Type)
 			a.lock.Unlock()
 			return nil
 		}
@@ -846,6 +1178,115 @@ func (a *TapWorker) toArp(data []byte) bool {
 	return true
 }
 
+// isOwner returns true if the address is assigned to this device.
+func (a *TapWorker) isOwner(addr []byte) bool {
+	if bytes.Equal(addr, a.ether.Ip6Link) || bytes.Equal(addr, a.ether.Ip6Addr) {
+		return true
+	}
+	for _, host := range a.ether.Ip6Host {
+		if bytes.Equal(addr, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// newNdp returns a frame of neighbor solicitation or advertisement.
+func (a *TapWorker) newNdp(t uint8, dst, ethDst, source []byte, ndp *libol.Ndp) *libol.FrameMessage {
+	icmp := libol.NewIcmp6()
+	icmp.Type = t
+	payload := append(icmp.Encode(), ndp.Encode()...)
+	sum := libol.Ipv6Checksum(source, dst, libol.IpIcmp6, payload)
+	binary.BigEndian.PutUint16(payload[2:4], sum)
+
+	iph := libol.NewIpv6()
+	iph.NextHeader = libol.IpIcmp6
+	iph.PayloadLen = uint16(len(payload))
+	copy(iph.Source, source)
+	copy(iph.Destination, dst)
+
+	eth := a.newEth(libol.EthIp6, ethDst)
+	frame := libol.NewFrameMessage()
+	frame.Append(eth.Encode())
+	frame.Append(iph.Encode())
+	frame.Append(payload)
+	return frame
+}
+
+// solicit hardware address of destination from our link-local address.
+func (a *TapWorker) onMiss6(dest []byte) {
+	ndp := libol.NewNdp()
+	copy(ndp.Target, dest)
+	ndp.Options = append(ndp.Options, libol.NdpOption{
+		Type:  libol.NdpOptSrcLink,
+		Value: a.ether.HwAddr,
+	})
+	dst := libol.Ip6SolicitedNode(dest)
+	frame := a.newNdp(libol.Icmp6NeighborSolicit, dst, libol.Ip6MulticastHw(dst), a.ether.Ip6Link, ndp)
+	libol.Debug("TapWorker.onMiss6: %x.", frame.Frame()[:64])
+	if a.listener.ReadAt != nil {
+		_ = a.listener.ReadAt(frame)
+	}
+}
+
+// answer neighbor solicitation and learn from advertisement.
+func (a *TapWorker) toNdp(data []byte) bool {
+	proto := libol.NewFrameProto(data)
+	if err := proto.Decode(); err != nil || proto.Ndp == nil {
+		return false
+	}
+	iph, ndp := proto.Ip6, proto.Ndp
+	switch proto.Icmp6.Type {
+	case libol.Icmp6NeighborSolicit:
+		hwAddr := ndp.LinkAddr(libol.NdpOptSrcLink)
+		unspecified := net.IP(iph.Source).IsUnspecified()
+		if hwAddr != nil && !unspecified {
+			a.neighbor.Add(&Neighbor{
+				HwAddr:  hwAddr,
+				IpAddr:  iph.Source,
+				NewTime: time.Now().Unix(),
+				Uptime:  time.Now().Unix(),
+			})
+		}
+		if !a.isOwner(ndp.Target) {
+			break
+		}
+		rep := libol.NewNdp()
+		rep.Flags = libol.NdpOverride
+		copy(rep.Target, ndp.Target)
+		rep.Options = append(rep.Options, libol.NdpOption{
+			Type:  libol.NdpOptTgtLink,
+			Value: a.ether.HwAddr,
+		})
+		dst := iph.Source
+		ethDst := proto.Eth.Src
+		if unspecified { // duplicate address detection.
+			dst = net.IPv6linklocalallnodes
+			ethDst = libol.Ip6MulticastHw(dst)
+		} else {
+			rep.Flags |= libol.NdpSolicited
+		}
+		frame := a.newNdp(libol.Icmp6NeighborAdvert, dst, ethDst, ndp.Target, rep)
+		libol.Cmd1("TapWorker.toNdp: reply %v on %x.", net.IP(ndp.Target), a.ether.HwAddr)
+		if a.listener.ReadAt != nil {
+			_ = a.listener.ReadAt(frame)
+		}
+	case libol.Icmp6NeighborAdvert:
+		hwAddr := ndp.LinkAddr(libol.NdpOptTgtLink)
+		if hwAddr == nil {
+			hwAddr = proto.Eth.Src
+		}
+		a.neighbor.Add(&Neighbor{
+			HwAddr:  hwAddr,
+			IpAddr:  ndp.Target,
+			NewTime: time.Now().Unix(),
+			Uptime:  time.Now().Unix(),
+		})
+		libol.Cmd1("TapWorker.toNdp: recv %v on %x.", net.IP(ndp.Target), hwAddr)
+	}
+	return true
+}
+
 func (a *TapWorker) close() {
 	libol.Info("TapWorker.close")
 	if a.device != nil {
@@ -879,6 +1320,11 @@ type WorkerListener struct {
 	OnTap     func(w *TapWorker) error
 	AddRoutes func(routes []*models.Route) error
 	DelRoutes func(routes []*models.Route) error
+	AddDns    func(servers, domains []string) error
+	DelDns    func() error
+	OnConnect func() error                       // before connecting to switch.
+	Advertise func() []*models.Route             // routes advertised by link.
+	OnRoutes  func(routes []*models.Route) error // routes advertised to link.
 }
 
 type PrefixRule struct {
@@ -888,32 +1334,105 @@ type PrefixRule struct {
 }
 
 func GetSocketClient(c *config.Point) libol.SocketClient {
+	if c.Bond != nil && len(c.Bond.Paths) > 0 {
+		return GetBondClient(c)
+	}
+	if c.Proxy != "" && c.Protocol != "tcp" && c.Protocol != "tls" && c.Protocol != "" {
+		libol.Warn("GetSocketClient: %s not tunneled by proxy", c.Protocol)
+	}
 	switch c.Protocol {
 	case "kcp":
 		kcpCfg := &libol.KcpConfig{
 			Block: config.GetBlock(c.Crypt),
+			Aead:  config.GetAead(c.Crypt, false),
 		}
 		return libol.NewKcpClient(c.Connection, kcpCfg)
 	case "tcp":
 		tcpCfg := &libol.TcpConfig{
 			Block: config.GetBlock(c.Crypt),
+			Aead:  config.GetAead(c.Crypt, false),
+			Local: c.Local,
+			Proxy: c.Proxy,
 		}
 		return libol.NewTcpClient(c.Connection, tcpCfg)
 	case "udp":
 		udpCfg := &libol.UdpConfig{
 			Block:   config.GetBlock(c.Crypt),
+			Aead:    config.GetAead(c.Crypt, false),
 			Timeout: time.Duration(c.Timeout) * time.Second,
 		}
 		return libol.NewUdpClient(c.Connection, udpCfg)
+	case "ws", "wss":
+		wsCfg := &libol.WsConfig{
+			Block:   config.GetBlock(c.Crypt),
+			Aead:    config.GetAead(c.Crypt, c.Protocol == "wss"),
+			Timeout: time.Duration(c.Timeout) * time.Second,
+		}
+		if c.Protocol == "wss" {
+			wsCfg.Tls = config.GetTlsClientCfg(c.Cert)
+		}
+		return libol.NewWebSocketClient(c.Connection, wsCfg)
 	default:
 		tcpCfg := &libol.TcpConfig{
-			Tls:   &tls.Config{InsecureSkipVerify: true},
+			Tls:   config.GetTlsClientCfg(c.Cert),
 			Block: config.GetBlock(c.Crypt),
+			Aead:  config.GetAead(c.Crypt, true),
+			Local: c.Local,
+			Proxy: c.Proxy,
 		}
 		return libol.NewTcpClient(c.Connection, tcpCfg)
 	}
 }
 
+// GetBondClient returns client holds a path for each connection of bond,
+// and only paths over tcp or tls dial from local address.
+func GetBondClient(c *config.Point) libol.SocketClient {
+	paths := make([]libol.SocketClient, 0, len(c.Bond.Paths))
+	for _, path := range c.Bond.Paths {
+		cfg := *c
+		cfg.Bond = nil
+		cfg.Connection = path.Connection
+		cfg.Protocol = path.Protocol
+		if path.Local != "" {
+			cfg.Local = path.Local
+		}
+		if cfg.Local != "" && cfg.Protocol != "tcp" && cfg.Protocol != "tls" {
+			libol.Warn("GetBondClient: %s not dial from %s", cfg.Protocol, cfg.Local)
+		}
+		paths = append(paths, GetSocketClient(&cfg))
+	}
+	return libol.NewBondClient(c.Bond.Mode, paths)
+}
+
+// GetMuxSession returns session to multiplex networks over one connection,
+// and nil if the protocol is not stream.
+func GetMuxSession(c *config.Point) *libol.MuxSession {
+	tcpCfg := &libol.TcpConfig{Proxy: c.Proxy}
+	if c.Protocol == "tls" || c.Protocol == "" {
+		tcpCfg.Tls = config.GetTlsClientCfg(c.Cert)
+	}
+	protocol := c.Protocol
+	if protocol == "" {
+		protocol = "tls"
+	}
+	dialer := libol.NewMuxDialer(protocol, c.Connection, tcpCfg, config.GetBlock(c.Crypt))
+	if dialer == nil {
+		libol.Warn("GetMuxSession: %s not multiplexed", c.Protocol)
+		return nil
+	}
+	return libol.NewMuxSession(c.Connection, dialer)
+}
+
+func GetMuxClient(mux *libol.MuxSession, c *config.Point) libol.SocketClient {
+	muxCfg := &libol.MuxConfig{
+		Aead: config.GetAead(c.Crypt, c.Protocol == "tls" || c.Protocol == ""),
+	}
+	if c.Protocol != "kcp" { // kcp encrypts by itself.
+		muxCfg.Block = config.GetBlock(c.Crypt)
+	}
+	return libol.NewMuxClient(mux, muxCfg)
+}
+
 func GetTapCfg(c *config.Point) network.TapConfig {
 	if c.Interface.Provider == "tun" {
 		return network.TapConfig{
@@ -941,6 +1460,10 @@ type Worker struct {
 	uuid      string
 	network   *models.Network
 	routes    []PrefixRule
+	mux       *libol.MuxSession // shared by networks of point.
+	stack     *stack.Stack      // instead of device by provider userspace.
+	proxies   []*libol.ProxyServer
+	forwards  *Forwards
 }
 
 func NewWorker(config *config.Point) (p *Worker) {
@@ -956,8 +1479,16 @@ func (p *Worker) Initialize() {
 		return
 	}
 	libol.Info("Worker.Initialize")
-	client := GetSocketClient(p.config)
+	var client libol.SocketClient
+	if p.mux != nil {
+		client = GetMuxClient(p.mux, p.config)
+	} else {
+		client = GetSocketClient(p.config)
+	}
 	p.tcpWorker = NewSocketWorker(client, p.config)
+	if p.config.Interface.Provider == "userspace" {
+		p.initStack()
+	}
 
 	tapCfg := GetTapCfg(p.config)
 	// register listener
@@ -968,6 +1499,13 @@ func (p *Worker) Initialize() {
 		OnClose:   p.OnClose,
 		OnSuccess: p.OnSuccess,
 		OnIpAddr:  p.OnIpAddr,
+		OnRoute:   p.OnRoute,
+		OnConnect: func(w *SocketWorker) error {
+			if p.listener.OnConnect != nil {
+				return p.listener.OnConnect()
+			}
+			return nil
+		},
 		ReadAt: func(frame *libol.FrameMessage) error {
 			p.tapWorker.writeQueue <- frame
 			return nil
@@ -1000,6 +1538,32 @@ func (p *Worker) Initialize() {
 	}
 }
 
+// initStack terminates the network by a stack in userspace instead of
+// device of kernel, and applications reach hosts on it by proxies.
+func (p *Worker) initStack() {
+	mtu := p.config.Interface.IfMtu - libol.EtherLen - libol.VlanLen
+	p.stack = stack.NewStack(p.config.Network, mtu)
+	p.stack.SetFindNext(p.FindNext)
+	p.listener.AddAddr = p.stack.SetAddr
+	p.listener.DelAddr = p.stack.DelAddr
+	p.listener.AddRoutes = nil
+	p.listener.DelRoutes = nil
+	p.listener.AddDns = nil
+	p.listener.DelDns = nil
+	p.listener.OnTap = func(w *TapWorker) error {
+		return p.stack.AddSlave(w.device)
+	}
+	p.forwards = NewForwards(p.stack)
+	if us := p.config.Userspace; us != nil {
+		if us.Socks != "" {
+			p.proxies = append(p.proxies, libol.NewSocks5Server(us.Socks, p.stack.Dial))
+		}
+		if us.Http != "" {
+			p.proxies = append(p.proxies, libol.NewHttpProxy(us.Http, p.stack.Dial))
+		}
+	}
+}
+
 func (p *Worker) Start() {
 	libol.Debug("Worker.Start linux.")
 	p.tapWorker.Start()
@@ -1007,6 +1571,22 @@ func (p *Worker) Start() {
 	if p.http != nil {
 		libol.Go(p.http.Start)
 	}
+	for _, proxy := range p.proxies {
+		if err := proxy.Listen(); err != nil {
+			libol.Error("Worker.Start: %s", err)
+			continue
+		}
+		libol.Go(proxy.Accept)
+	}
+	for _, fwd := range p.config.Forwards {
+		if p.forwards == nil {
+			libol.Warn("Worker.Start: forward needs provider userspace")
+			break
+		}
+		if _, err := p.forwards.Start(fwd); err != nil {
+			libol.Error("Worker.Start: forward %s: %s", fwd.Local, err)
+		}
+	}
 }
 
 func (p *Worker) Stop() {
@@ -1016,13 +1596,27 @@ func (p *Worker) Stop() {
 	if p.http != nil {
 		p.http.Shutdown()
 	}
+	for _, proxy := range p.proxies {
+		proxy.Close()
+	}
+	if p.forwards != nil {
+		p.forwards.StopAll()
+	}
 	p.FreeIpAddr()
 	p.tcpWorker.Stop()
 	p.tapWorker.Stop()
+	if p.stack != nil {
+		_ = p.stack.Close()
+	}
 	p.tcpWorker = nil
 	p.tapWorker = nil
 }
 
+// Stack returns stack in userspace, and nil if provider is not userspace.
+func (p *Worker) Stack() *stack.Stack {
+	return p.stack
+}
+
 func (p *Worker) Client() libol.SocketClient {
 	if p.tcpWorker != nil {
 		return p.tcpWorker.client
@@ -1045,10 +1639,30 @@ func (p *Worker) UpTime() int64 {
 	return 0
 }
 
+func (p *Worker) Reconnects() uint64 {
+	if p.tcpWorker != nil {
+		return p.tcpWorker.record.reconnects
+	}
+	return 0
+}
+
+func (p *Worker) Endpoints() []models.Endpoint {
+	if p.tcpWorker != nil {
+		return p.tcpWorker.Endpoints()
+	}
+	return nil
+}
+
+func (p *Worker) Failovers() []models.Failover {
+	if p.tcpWorker != nil {
+		return p.tcpWorker.Failovers()
+	}
+	return nil
+}
+
 func (p *Worker) State() string {
-	client := p.Client()
-	if client != nil {
-		return client.State()
+	if p.tcpWorker != nil {
+		return p.tcpWorker.State()
 	}
 	return ""
 }
@@ -1087,6 +1701,9 @@ func (p *Worker) FindNext(dest []byte) []byte {
 		if libol.HasLog(libol.DEBUG) {
 			libol.Debug("Worker.FindNext %v to %v", dest, rt.NextHop)
 		}
+		if len(dest) == net.IPv6len {
+			return rt.NextHop.To16()
+		}
 		return rt.NextHop.To4()
 	}
 	return dest
@@ -1098,38 +1715,143 @@ func (p *Worker) OnIpAddr(w *SocketWorker, n *models.Network) error {
 	if p.network != nil { // remove older firstly
 		p.FreeIpAddr()
 	}
-	prefix := libol.Netmask2Len(n.Netmask)
-	ipStr := fmt.Sprintf("%s/%d", n.IfAddr, prefix)
-	p.tapWorker.setEther(ipStr)
-	if p.listener.AddAddr != nil {
-		_ = p.listener.AddAddr(ipStr)
+	if n.IfAddr != "" {
+		prefix := libol.Netmask2Len(n.Netmask)
+		ipStr := fmt.Sprintf("%s/%d", n.IfAddr, prefix)
+		p.tapWorker.setEther(ipStr)
+		if p.listener.AddAddr != nil {
+			_ = p.listener.AddAddr(ipStr)
+		}
+	}
+	if ip6Str := n.Addr6(); ip6Str != "" {
+		p.tapWorker.setEther6(ip6Str)
+		if p.listener.AddAddr != nil {
+			_ = p.listener.AddAddr(ip6Str)
+		}
+		p.tapWorker.cacheAddr6()
 	}
 	if p.listener.AddRoutes != nil {
 		_ = p.listener.AddRoutes(n.Routes)
 	}
+	if len(n.Dns) > 0 && p.listener.AddDns != nil {
+		_ = p.listener.AddDns(n.Dns, n.Domains)
+	}
 	p.network = n
+	p.updateRules()
+	return nil
+}
 
-	// update routes
+// updateRules builds prefix rules to find next hop by routes of network.
+func (p *Worker) updateRules() {
+	rules := make([]PrefixRule, 0, 32)
 	ip := net.ParseIP(p.network.IfAddr)
 	m := net.IPMask(net.ParseIP(p.network.Netmask).To4())
-	p.routes = append(p.routes, PrefixRule{
+	rules = append(rules, PrefixRule{
 		Type:        0x00,
 		Destination: net.IPNet{IP: ip.Mask(m), Mask: m},
 		NextHop:     libol.ZEROED,
 	})
-	for _, rt := range n.Routes {
+	if _, prefix, err := net.ParseCIDR(p.network.Prefix6); err == nil {
+		rules = append(rules, PrefixRule{
+			Type:        0x00,
+			Destination: *prefix,
+			NextHop:     net.IPv6zero,
+		})
+	}
+	for _, rt := range p.network.Routes {
 		_, dest, err := net.ParseCIDR(rt.Prefix)
 		if err != nil {
 			continue
 		}
 		nxt := net.ParseIP(rt.NextHop)
-		p.routes = append(p.routes, PrefixRule{
+		rules = append(rules, PrefixRule{
 			Type:        0x01,
 			Destination: *dest,
 			NextHop:     nxt,
 		})
 	}
-	return nil
+	p.routes = rules
+}
+
+// UpdateRoutes installs routes added and removes ones deleted, by routes
+// pushed from switch.
+func (p *Worker) UpdateRoutes(routes []*models.Route) {
+	if p.network == nil {
+		libol.Info("Worker.UpdateRoutes: wait address")
+		return
+	}
+	dels := DiffRoutes(p.network.Routes, routes)
+	adds := DiffRoutes(routes, p.network.Routes)
+	if len(dels) > 0 && p.listener.DelRoutes != nil {
+		_ = p.listener.DelRoutes(dels)
+	}
+	if len(adds) > 0 && p.listener.AddRoutes != nil {
+		_ = p.listener.AddRoutes(adds)
+	}
+	libol.Info("Worker.UpdateRoutes: %d added, %d deleted", len(adds), len(dels))
+	n := *p.network
+	n.Routes = routes
+	p.network = &n
+	p.updateRules()
+}
+
+// Routes returns routes installed.
+func (p *Worker) Routes() []*models.Route {
+	if n := p.network; n != nil && n.Routes != nil {
+		return n.Routes
+	}
+	return make([]*models.Route, 0)
+}
+
+// DiffRoutes returns routes in a but not in b.
+func DiffRoutes(a, b []*models.Route) []*models.Route {
+	has := make(map[string]bool, len(b))
+	for _, rt := range b {
+		has[rt.Prefix+" "+rt.NextHop] = true
+	}
+	diff := make([]*models.Route, 0, 32)
+	for _, rt := range a {
+		if !has[rt.Prefix+" "+rt.NextHop] {
+			diff = append(diff, rt)
+		}
+	}
+	return diff
+}
+
+// InPrefixes returns true if prefix is in one of prefixes.
+func InPrefixes(prefix string, prefixes []string) bool {
+	_, dst, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return false
+	}
+	ones, bits := dst.Mask.Size()
+	for _, p := range prefixes {
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			continue
+		}
+		o, b := n.Mask.Size()
+		if b == bits && o <= ones && n.Contains(dst.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterRoutes returns routes in prefixes of include if given, and not in
+// prefixes of exclude.
+func FilterRoutes(routes []*models.Route, include, exclude []string) []*models.Route {
+	filter := make([]*models.Route, 0, len(routes))
+	for _, rt := range routes {
+		if len(include) > 0 && !InPrefixes(rt.Prefix, include) {
+			continue
+		}
+		if InPrefixes(rt.Prefix, exclude) {
+			continue
+		}
+		filter = append(filter, rt)
+	}
+	return filter
 }
 
 func (p *Worker) FreeIpAddr() {
@@ -1139,18 +1861,40 @@ func (p *Worker) FreeIpAddr() {
 	if p.listener.DelRoutes != nil {
 		_ = p.listener.DelRoutes(p.network.Routes)
 	}
+	if len(p.network.Dns) > 0 && p.listener.DelDns != nil {
+		_ = p.listener.DelDns()
+	}
 	if p.listener.DelAddr != nil {
-		prefix := libol.Netmask2Len(p.network.Netmask)
-		ipStr := fmt.Sprintf("%s/%d", p.network.IfAddr, prefix)
-		_ = p.listener.DelAddr(ipStr)
+		if p.network.IfAddr != "" {
+			prefix := libol.Netmask2Len(p.network.Netmask)
+			ipStr := fmt.Sprintf("%s/%d", p.network.IfAddr, prefix)
+			_ = p.listener.DelAddr(ipStr)
+		}
+		if ip6Str := p.network.Addr6(); ip6Str != "" {
+			_ = p.listener.DelAddr(ip6Str)
+			p.tapWorker.cacheAddr6()
+		}
 	}
 	p.network = nil
 	p.routes = make([]PrefixRule, 0, 32)
 }
 
+func (p *Worker) OnRoute(w *SocketWorker, n *models.Network) error {
+	libol.Info("Worker.OnRoute: %s", n.Routes)
+	if p.listener.OnRoutes != nil {
+		return p.listener.OnRoutes(n.Routes)
+	}
+	p.UpdateRoutes(n.Routes)
+	return nil
+}
+
 func (p *Worker) OnClose(w *SocketWorker) error {
 	libol.Info("Worker.OnClose")
 	p.FreeIpAddr()
+	// withdraw routes advertised by switch.
+	if p.listener.OnRoutes != nil {
+		_ = p.listener.OnRoutes(nil)
+	}
 	return nil
 }
 
@@ -1159,9 +1903,27 @@ func (p *Worker) OnSuccess(w *SocketWorker) error {
 	if p.listener.AddAddr != nil {
 		_ = p.listener.AddAddr(p.ifAddr)
 	}
+	if p.listener.Advertise != nil {
+		_ = w.toRoute(w.client, p.listener.Advertise())
+	}
 	return nil
 }
 
+// Advertise sends routes to switch again, if routes changed.
+func (p *Worker) Advertise() {
+	if p.tcpWorker == nil || p.listener.Advertise == nil {
+		return
+	}
+	t := p.tcpWorker
+	routes := p.listener.Advertise()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.client == nil || t.client.Status() != libol.ClAuth {
+		return
+	}
+	_ = t.toRoute(t.client, routes)
+}
+
 func (p *Worker) UUID() string {
 	if p.uuid == "" {
 		p.uuid = libol.GenToken(32)
@@ -0,0 +1,277 @@
+package point
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWorkerUpdateRoutes(t *testing.T) {
+	adds := make([]*models.Route, 0)
+	dels := make([]*models.Route, 0)
+	p := &Worker{
+		listener: WorkerListener{
+			AddRoutes: func(routes []*models.Route) error {
+				adds = append(adds, routes...)
+				return nil
+			},
+			DelRoutes: func(routes []*models.Route) error {
+				dels = append(dels, routes...)
+				return nil
+			},
+		},
+	}
+	p.UpdateRoutes([]*models.Route{models.NewRoute("10.1.0.0/16", "192.168.1.2")})
+	assert.Equal(t, 0, len(adds), "wait address.")
+
+	p.network = &models.Network{
+		IfAddr:  "192.168.1.10",
+		Netmask: "255.255.255.0",
+		Routes: []*models.Route{
+			models.NewRoute("10.1.0.0/16", "192.168.1.2"),
+			models.NewRoute("10.2.0.0/16", "192.168.1.2"),
+		},
+	}
+	routes := []*models.Route{
+		models.NewRoute("10.1.0.0/16", "192.168.1.2"),
+		models.NewRoute("10.2.0.0/16", "192.168.1.3"),
+		models.NewRoute("10.3.0.0/16", "192.168.1.3"),
+	}
+	p.UpdateRoutes(routes)
+	assert.Equal(t, []*models.Route{
+		models.NewRoute("10.2.0.0/16", "192.168.1.3"),
+		models.NewRoute("10.3.0.0/16", "192.168.1.3"),
+	}, adds, "be the same.")
+	assert.Equal(t, []*models.Route{
+		models.NewRoute("10.2.0.0/16", "192.168.1.2"),
+	}, dels, "be the same.")
+	assert.Equal(t, routes, p.Routes(), "be the same.")
+	assert.Equal(t, 4, len(p.routes), "rules.")
+	assert.Equal(t, []byte{192, 168, 1, 3}, p.FindNext([]byte{10, 3, 0, 1}), "next hop.")
+}
+
+func TestWorkerDns(t *testing.T) {
+	applied := make([]string, 0)
+	p := &Worker{
+		listener: WorkerListener{
+			AddDns: func(servers, domains []string) error {
+				applied = append(servers, domains...)
+				return nil
+			},
+			DelDns: func() error {
+				applied = nil
+				return nil
+			},
+		},
+	}
+	_ = p.OnIpAddr(nil, &models.Network{Dns: []string{"10.0.0.2"}, Domains: []string{"corp.lan"}})
+	assert.Equal(t, []string{"10.0.0.2", "corp.lan"}, applied, "applied.")
+	p.FreeIpAddr()
+	assert.Nil(t, applied, "restored.")
+}
+
+func TestFilterRoutes(t *testing.T) {
+	routes := []*models.Route{
+		models.NewRoute("10.1.0.0/16", "192.168.1.2"),
+		models.NewRoute("10.2.0.0/16", "192.168.1.2"),
+		models.NewRoute("10.2.1.0/24", "192.168.1.2"),
+		models.NewRoute("172.16.0.0/12", "192.168.1.3"),
+		models.NewRoute("fd00::/64", "fe80::1"),
+	}
+	assert.Equal(t, routes, FilterRoutes(routes, nil, nil), "all.")
+	assert.Equal(t, []*models.Route{
+		models.NewRoute("10.2.0.0/16", "192.168.1.2"),
+		models.NewRoute("10.2.1.0/24", "192.168.1.2"),
+	}, FilterRoutes(routes, []string{"10.2.0.0/16", "10.0.0.0/16"}, nil), "only.")
+	assert.Equal(t, []*models.Route{
+		models.NewRoute("10.1.0.0/16", "192.168.1.2"),
+		models.NewRoute("10.2.0.0/16", "192.168.1.2"),
+		models.NewRoute("fd00::/64", "fe80::1"),
+	}, FilterRoutes(routes, nil, []string{"10.2.1.0/24", "172.16.0.0/12"}), "except.")
+	assert.Equal(t, []*models.Route{
+		models.NewRoute("10.2.0.0/16", "192.168.1.2"),
+		models.NewRoute("10.2.1.0/24", "192.168.1.2"),
+	}, FilterRoutes(routes, []string{"10.0.0.0/8"}, []string{"10.1.0.0/16", "10.2.1.0/25"}), "be the same.")
+	assert.False(t, InPrefixes("fd00::/64", []string{"0.0.0.0/0"}), "family.")
+	assert.True(t, InPrefixes("fd00::/64", []string{"::/0"}), "default.")
+}
+
+func newTunWorker(hwAddr []byte, frames *[]*libol.FrameMessage) *TapWorker {
+	a := NewTapWorker(network.TapConfig{}, &config.Point{})
+	a.neighbor = Neighbors{neighbors: make(map[string]*Neighbor, 32)}
+	a.ether.HwAddr = hwAddr
+	a.ether.Ip6Link = libol.Ip6LinkLocal(hwAddr)
+	a.listener.ReadAt = func(frame *libol.FrameMessage) error {
+		*frames = append(*frames, frame)
+		return nil
+	}
+	return a
+}
+
+func TestTapWorkerNdp(t *testing.T) {
+	var aFrames, bFrames []*libol.FrameMessage
+	a := newTunWorker([]byte{0x0a, 0, 0, 0, 0, 0x01}, &aFrames)
+	b := newTunWorker([]byte{0x0a, 0, 0, 0, 0, 0x02}, &bFrames)
+	assert.Equal(t, "fe80::800:ff:fe00:1", net.IP(a.ether.Ip6Link).String(), "be the same.")
+
+	// a solicits b.
+	a.onMiss(b.ether.Ip6Link)
+	assert.Equal(t, 1, len(aFrames), "solicitation.")
+	ns := aFrames[0].Frame()[:aFrames[0].Size()]
+	proto := libol.NewFrameProto(ns)
+	assert.Nil(t, proto.Decode(), "decode.")
+	assert.Equal(t, []byte{0x33, 0x33, 0xff, 0, 0, 0x02}, proto.Eth.Dst, "be the same.")
+	assert.Equal(t, uint8(libol.Icmp6NeighborSolicit), proto.Icmp6.Type, "be the same.")
+	payload := ns[libol.EtherLen+libol.Ipv6Len:]
+	assert.Equal(t, uint16(0), libol.Ipv6Checksum(proto.Ip6.Source, proto.Ip6.Destination, libol.IpIcmp6, payload), "checksum.")
+
+	// b answers, and learns a from solicitation.
+	assert.True(t, b.toNdp(ns), "proxy.")
+	assert.NotNil(t, b.neighbor.GetByBytes(a.ether.Ip6Link), "learned.")
+	assert.Equal(t, 1, len(bFrames), "advertisement.")
+	na := bFrames[0].Frame()[:bFrames[0].Size()]
+	proto = libol.NewFrameProto(na)
+	assert.Nil(t, proto.Decode(), "decode.")
+	assert.Equal(t, uint8(libol.Icmp6NeighborAdvert), proto.Icmp6.Type, "be the same.")
+	assert.True(t, proto.Ndp.HasFlag(libol.NdpSolicited), "solicited.")
+	assert.Equal(t, a.ether.HwAddr, proto.Eth.Dst, "be the same.")
+
+	// a learns b from advertisement.
+	assert.True(t, a.toNdp(na), "proxy.")
+	neb := a.neighbor.GetByBytes(b.ether.Ip6Link)
+	assert.NotNil(t, neb, "learned.")
+	assert.Equal(t, b.ether.HwAddr, neb.HwAddr, "be the same.")
+
+	// not owner of target.
+	bFrames = bFrames[:0]
+	a.onMiss(net.ParseIP("fd00::9"))
+	assert.True(t, b.toNdp(aFrames[1].Frame()[:aFrames[1].Size()]), "proxy.")
+	assert.Equal(t, 0, len(bFrames), "no advertisement.")
+
+	// address cached on device.
+	b.ether.Ip6Host = [][]byte{net.ParseIP("fd00::9").To16()}
+	assert.True(t, b.toNdp(aFrames[1].Frame()[:aFrames[1].Size()]), "proxy.")
+	assert.Equal(t, 1, len(bFrames), "advertisement.")
+}
+
+func TestSocketWorkerFailover(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen.")
+	defer listener.Close()
+
+	c := &config.Point{
+		Protocol: "tcp",
+		Timeout:  60,
+		Failback: 60,
+		Endpoints: []*config.Endpoint{
+			{Connection: "127.0.0.1:1", Priority: 2},
+			{Connection: listener.Addr().String(), Priority: 1},
+		},
+	}
+	c.Right()
+	assert.Equal(t, listener.Addr().String(), c.Connection, "preferred.")
+	w := NewSocketWorker(GetSocketClient(c), c)
+	w.SetUUID("uuid")
+	w.Initialize()
+	assert.Equal(t, 1, w.nextEndpoint(), "next.")
+
+	// fail over only if unreachable for timeout.
+	now := time.Now().Unix()
+	assert.False(t, w.unreachable(now), "first failed.")
+	assert.False(t, w.unreachable(now+30), "not timeout.")
+	assert.True(t, w.unreachable(now+60), "timeout.")
+
+	w.failover(w.nextEndpoint(), "failed")
+	assert.Equal(t, "127.0.0.1:1", w.client.Addr(), "failed over.")
+	assert.Equal(t, 0, w.nextEndpoint(), "wrapped.")
+	assert.Equal(t, "uuid", w.user.UUID, "same uuid.")
+
+	// fail back once preferred is stable.
+	index := probe(w.pointCfg, w.endpoints, w.active)
+	assert.Equal(t, 0, index, "reachable.")
+	assert.False(t, w.unreachable(now+60), "reset by failover.")
+	w.onProbe(index, now)
+	assert.Equal(t, 1, w.active, "not stable.")
+	eps := w.Endpoints()
+	assert.True(t, eps[1].Active, "active.")
+	assert.Equal(t, int64(0), eps[0].Stable, "since now.")
+	w.onProbe(-1, now+30)
+	w.onProbe(index, now+60)
+	assert.Equal(t, 1, w.active, "not stable again.")
+	w.onProbe(index, now+120)
+	assert.Equal(t, 0, w.active, "failed back.")
+	assert.Equal(t, listener.Addr().String(), w.client.Addr(), "failed back.")
+
+	failovers := w.Failovers()
+	assert.Equal(t, 2, len(failovers), "history.")
+	assert.Equal(t, "failed", failovers[0].Reason, "be the same.")
+	assert.Equal(t, "failback", failovers[1].Reason, "be the same.")
+	assert.Equal(t, "127.0.0.1:1", failovers[1].From, "be the same.")
+}
+
+func TestSocketWorkerProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen.")
+	defer listener.Close()
+	addr := listener.Addr().String()
+
+	c := &config.Point{}
+	endpoints := []*config.Endpoint{
+		{Connection: addr, Protocol: "kcp"},
+		{Connection: "ws://" + addr + "/openlan", Protocol: "ws"},
+		{Connection: addr, Protocol: "tcp"},
+	}
+	assert.Equal(t, 1, probe(c, endpoints, 3), "by url.")
+	assert.Equal(t, "127.0.0.1:443", libol.WsHost("wss://127.0.0.1/openlan"), "default port.")
+	assert.Equal(t, addr, libol.WsHost(addr), "be the same.")
+
+	// dial by proxy.
+	c.Proxy = "socks5://127.0.0.1:1"
+	assert.Equal(t, -1, probe(c, endpoints[2:], 1), "proxy failed.")
+}
+
+func TestSocketWorkerProxy(t *testing.T) {
+	c := &config.Point{
+		Connection: "127.0.0.1:10002",
+		Protocol:   "tcp",
+		Proxy:      "socks5://127.0.0.1:1",
+	}
+	w := NewSocketWorker(GetSocketClient(c), c)
+	w.Initialize()
+	err := w.connect()
+	assert.IsType(t, &libol.ProxyError{}, err, "proxy error.")
+	assert.Equal(t, "proxy dial failed", w.State(), "be the same.")
+}
+
+func TestWorkerUserspace(t *testing.T) {
+	c := &config.Point{
+		Connection: "127.0.0.1:10002",
+		Protocol:   "tcp",
+		Network:    "default",
+		Interface:  config.Interface{IfMtu: 1518, Provider: "userspace"},
+		Userspace:  &config.Userspace{Socks: "127.0.0.1:0", Http: "127.0.0.1:0"},
+	}
+	p := NewWorker(c)
+	p.Initialize()
+	defer p.tapWorker.close()
+	assert.NotNil(t, p.Stack(), "stack.")
+	assert.Equal(t, 1500, p.Stack().Mtu(), "be the same.")
+	assert.IsType(t, &network.UserSpaceTap{}, p.Device(), "userspace.")
+	assert.Equal(t, 2, len(p.proxies), "socks5 and http.")
+
+	_ = p.OnIpAddr(p.tcpWorker, &models.Network{
+		IfAddr:  "192.168.1.10",
+		Netmask: "255.255.255.0",
+		Routes:  []*models.Route{models.NewRoute("10.1.0.0/16", "192.168.1.2")},
+	})
+	assert.Equal(t, "192.168.1.10/24", p.Stack().Addr(), "be the same.")
+	_, err := p.Stack().DialTimeout("tcp", "10.1.0.1:80", 100*time.Millisecond)
+	assert.True(t, err.(net.Error).Timeout(), "by next hop.")
+	p.FreeIpAddr()
+	assert.Equal(t, "", p.Stack().Addr(), "deleted.")
+}
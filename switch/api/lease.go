@@ -1,6 +1,7 @@
 package api
 
 import (
+	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/switch/schema"
 	"github.com/danieldin95/openlan-go/switch/storage"
 	"github.com/gorilla/mux"
@@ -12,7 +13,9 @@ type Lease struct {
 
 func (l Lease) Router(router *mux.Router) {
 	router.HandleFunc("/api/lease", l.List).Methods("GET")
+	router.HandleFunc("/api/lease", l.Add).Methods("POST")
 	router.HandleFunc("/api/lease/{id}", l.List).Methods("GET")
+	router.HandleFunc("/api/lease/{id}", l.Del).Methods("DELETE")
 }
 
 func (l Lease) List(w http.ResponseWriter, r *http.Request) {
@@ -25,3 +28,34 @@ func (l Lease) List(w http.ResponseWriter, r *http.Request) {
 	}
 	ResponseJson(w, nets)
 }
+
+// Add reserves the address for uuid.
+func (l Lease) Add(w http.ResponseWriter, r *http.Request) {
+	lease := &schema.Lease{}
+	if err := GetData(r, lease); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if lease.UUID == "" || lease.Network == "" {
+		http.Error(w, "uuid and network required", http.StatusBadRequest)
+		return
+	}
+	if storage.Network.Get(lease.Network) == nil {
+		http.Error(w, lease.Network+" not found", http.StatusNotFound)
+		return
+	}
+	if err := storage.Network.AddStatic(lease.UUID, lease.Network, lease.Address); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	libol.Info("Lease.Add %s %s", lease.UUID, lease.Address)
+	ResponseMsg(w, 0, "")
+}
+
+func (l Lease) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	network := GetQueryOne(r, "network")
+	libol.Info("Lease.Del %s %s", vars["id"], network)
+	storage.Network.DelLease(network, vars["id"])
+	ResponseMsg(w, 0, "")
+}
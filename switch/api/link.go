@@ -1,14 +1,12 @@
 package api
 
 import (
-	"encoding/json"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
 	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/switch/schema"
 	"github.com/danieldin95/openlan-go/switch/storage"
 	"github.com/gorilla/mux"
-	"io/ioutil"
 	"net/http"
 )
 
@@ -18,6 +16,7 @@ type Link struct {
 
 func (h Link) Router(router *mux.Router) {
 	router.HandleFunc("/api/link", h.List).Methods("GET")
+	router.HandleFunc("/api/link", h.Add).Methods("POST")
 	router.HandleFunc("/api/link/{id}", h.Get).Methods("GET")
 	router.HandleFunc("/api/link/{id}", h.Add).Methods("POST")
 	router.HandleFunc("/api/link/{id}", h.Del).Methods("DELETE")
@@ -48,27 +47,37 @@ func (h Link) Get(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h Link) Add(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	vars := mux.Vars(r)
+	c := &config.Point{}
+	if err := GetData(r, c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	c := &config.Point{}
-	if err := json.Unmarshal([]byte(body), c); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if c.Connection == "" {
+		c.Connection = vars["id"]
+	}
+	if c.Network == "" {
+		c.Network = "default"
+	}
+	if h.Switcher.GetNetwork(c.Network) == nil {
+		http.Error(w, c.Network+" not found", http.StatusNotFound)
 		return
 	}
-
-	c.Default()
-	h.Switcher.AddLink(c.Network, c)
+	if err := h.Switcher.AddLink(c.Network, c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Link.Add %s on %s", c.Connection, c.Network)
 	ResponseMsg(w, 0, "")
 }
 
 func (h Link) Del(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	libol.Info("DelLink %s", vars["id"])
-	h.Switcher.DelLink("", vars["id"])
+	libol.Info("Link.Del %s", vars["id"])
+	network := GetQueryOne(r, "network")
+	if err := h.Switcher.DelLink(network, vars["id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	ResponseMsg(w, 0, "")
 }
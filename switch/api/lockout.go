@@ -0,0 +1,48 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+)
+
+type Lockout struct {
+}
+
+func (h Lockout) Router(router *mux.Router) {
+	router.HandleFunc("/api/auth/lockout", h.List).Methods("GET")
+	router.HandleFunc("/api/auth/lockout", h.Clear).Methods("DELETE")
+	router.HandleFunc("/api/auth/lockout/{id}", h.Del).Methods("DELETE")
+}
+
+func (h Lockout) List(w http.ResponseWriter, r *http.Request) {
+	items := make([]schema.Lockout, 0, 1024)
+	for l := range storage.Lockout.List() {
+		if l == nil {
+			break
+		}
+		items = append(items, models.NewLockoutSchema(l))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].LastTime > items[j].LastTime
+	})
+	ResponseJson(w, items)
+}
+
+func (h Lockout) Clear(w http.ResponseWriter, r *http.Request) {
+	libol.Info("Lockout.Clear")
+	storage.Lockout.Clear()
+	ResponseMsg(w, 0, "")
+}
+
+// Del removes lockout by id, which is like 'ip:1.1.1.1' or 'user:hi@default'.
+func (h Lockout) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	libol.Info("Lockout.Del %s", vars["id"])
+	storage.Lockout.Del(vars["id"])
+	ResponseMsg(w, 0, "")
+}
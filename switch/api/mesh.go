@@ -0,0 +1,89 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Mesh struct {
+	Switcher Switcher
+}
+
+func (h Mesh) Router(router *mux.Router) {
+	router.HandleFunc("/api/mesh", h.List).Methods("GET")
+	router.HandleFunc("/api/mesh/{id}", h.Get).Methods("GET")
+}
+
+// topology returns peers of the network, which are links to other
+// switches and points logged in by links of them.
+func (h Mesh) topology(c *config.Network) schema.Mesh {
+	m := schema.Mesh{
+		Network: c.Name,
+		Switch:  h.Switcher.Alias(),
+		Mode:    "split-horizon",
+		Peers:   make([]schema.MeshPeer, 0, 32),
+	}
+	for l := range storage.Link.List() {
+		if l == nil {
+			break
+		}
+		if l.Network != c.Name {
+			continue
+		}
+		peer := schema.MeshPeer{
+			Address:   l.Server,
+			Direction: "out",
+			State:     l.Status,
+			Uptime:    l.Uptime,
+		}
+		if l.Device != nil {
+			peer.Device = l.Device.Name()
+		}
+		m.Peers = append(m.Peers, peer)
+	}
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		if p.Network != c.Name || !p.Peer {
+			continue
+		}
+		peer := schema.MeshPeer{
+			Alias:     p.Alias,
+			Direction: "in",
+		}
+		if p.Client != nil {
+			peer.Address = p.Client.Addr()
+			peer.State = p.Client.State()
+			peer.Uptime = p.Client.UpTime()
+		}
+		if p.Device != nil {
+			peer.Device = p.Device.Name()
+		}
+		m.Peers = append(m.Peers, peer)
+	}
+	return m
+}
+
+func (h Mesh) List(w http.ResponseWriter, r *http.Request) {
+	meshes := make([]schema.Mesh, 0, 32)
+	for _, c := range h.Switcher.Config().Network {
+		if c.Mesh {
+			meshes = append(meshes, h.topology(c))
+		}
+	}
+	ResponseJson(w, meshes)
+}
+
+func (h Mesh) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	c := h.Switcher.GetNetwork(vars["id"])
+	if c == nil || !c.Mesh {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	ResponseJson(w, h.topology(c))
+}
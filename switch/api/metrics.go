@@ -0,0 +1,96 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Metrics struct {
+	Switcher Switcher
+}
+
+func (h Metrics) Router(router *mux.Router) {
+	router.HandleFunc("/metrics", h.Get).Methods("GET")
+}
+
+func (h Metrics) Get(w http.ResponseWriter, r *http.Request) {
+	m := libol.NewMetrics()
+	h.points(m)
+	h.links(m)
+	h.leases(m)
+	sts := h.Switcher.Server().Sts()
+	m.Counter("openlan_switch_accepts_total", "Connections accepted.", uint64(sts.AcceptCount))
+	m.Counter("openlan_switch_closes_total", "Connections closed.", uint64(sts.CloseCount))
+	h.Switcher.Metrics(m)
+	m.AddGoroutines()
+	ResponseText(w, m.Bytes())
+}
+
+func (h Metrics) points(m *libol.Metrics) {
+	nets := make(map[string]*libol.ClientSts, 32)
+	counts := make(map[string]int, 32)
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		if p.Client == nil {
+			continue
+		}
+		sts := p.Client.Sts()
+		m.AddClient("openlan_switch_point", sts, "network", p.Network, "uuid", p.UUID,
+			"alias", p.Alias, "address", p.Client.Addr())
+		n, ok := nets[p.Network]
+		if !ok {
+			n = &libol.ClientSts{}
+			nets[p.Network] = n
+		}
+		n.RecvOkay += sts.RecvOkay
+		n.SendOkay += sts.SendOkay
+		n.RecvFrames += sts.RecvFrames
+		n.SendFrames += sts.SendFrames
+		n.Dropped += sts.Dropped
+		n.SendError += sts.SendError
+		n.AuthError += sts.AuthError
+		counts[p.Network]++
+	}
+	for name, sts := range nets {
+		m.AddClient("openlan_switch_network", *sts, "network", name)
+	}
+	for n := range storage.Network.List() {
+		if n == nil {
+			break
+		}
+		m.Gauge("openlan_switch_network_points", "Points online.", float64(counts[n.Name]), "network", n.Name)
+	}
+}
+
+func (h Metrics) links(m *libol.Metrics) {
+	for p := range storage.Link.List() {
+		if p == nil {
+			break
+		}
+		if p.Client == nil {
+			continue
+		}
+		m.AddClient("openlan_switch_link", p.Client.Sts(), "network", p.Network, "connection", p.Server)
+	}
+}
+
+func (h Metrics) leases(m *libol.Metrics) {
+	used := make(map[string]int, 32)
+	for l := range storage.Network.ListLease() {
+		if l == nil {
+			break
+		}
+		used[l.Network]++
+	}
+	for n := range storage.Network.List() {
+		if n == nil {
+			break
+		}
+		m.Gauge("openlan_switch_lease_pool_size", "Addresses in pool.", float64(n.Size()), "network", n.Name)
+		m.Gauge("openlan_switch_lease_used", "Addresses leased.", float64(used[n.Name]), "network", n.Name)
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
 	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/switch/schema"
 	"github.com/danieldin95/openlan-go/switch/storage"
@@ -9,11 +11,15 @@ import (
 )
 
 type Network struct {
+	Switcher Switcher
 }
 
 func (h Network) Router(router *mux.Router) {
 	router.HandleFunc("/api/network", h.List).Methods("GET")
+	router.HandleFunc("/api/network", h.Add).Methods("POST")
 	router.HandleFunc("/api/network/{id}", h.Get).Methods("GET")
+	router.HandleFunc("/api/network/{id}", h.Mod).Methods("PUT")
+	router.HandleFunc("/api/network/{id}", h.Del).Methods("DELETE")
 }
 
 func (h Network) List(w http.ResponseWriter, r *http.Request) {
@@ -36,3 +42,58 @@ func (h Network) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, vars["id"], http.StatusNotFound)
 	}
 }
+
+// Add creates a network by configuration of bridge, subnet, routes and
+// passwords.
+func (h Network) Add(w http.ResponseWriter, r *http.Request) {
+	c := &config.Network{}
+	if err := GetData(r, c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.Switcher.GetNetwork(c.Name) != nil {
+		http.Error(w, c.Name+" already existed", http.StatusConflict)
+		return
+	}
+	if err := h.Switcher.AddNetwork(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Network.Add %s", c.Name)
+	ResponseMsg(w, 0, "")
+}
+
+func (h Network) Mod(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	c := &config.Network{}
+	if err := GetData(r, c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.Name = vars["id"]
+	if h.Switcher.GetNetwork(c.Name) == nil {
+		http.Error(w, c.Name, http.StatusNotFound)
+		return
+	}
+	if err := h.Switcher.UpdateNetwork(c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Network.Mod %s", c.Name)
+	ResponseMsg(w, 0, "")
+}
+
+func (h Network) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["id"]
+	if h.Switcher.GetNetwork(name) == nil {
+		http.Error(w, name, http.StatusNotFound)
+		return
+	}
+	if err := h.Switcher.DelNetwork(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	libol.Info("Network.Del %s", name)
+	ResponseMsg(w, 0, "")
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Route struct {
+	Switcher Switcher
+}
+
+func (h Route) Router(router *mux.Router) {
+	router.HandleFunc("/api/route", h.List).Methods("GET")
+	router.HandleFunc("/api/route/{id}", h.Get).Methods("GET")
+}
+
+// routes returns static routes of the network, and ones learned from
+// other switches.
+func (h Route) routes(c *config.Network) []schema.Route {
+	routes := make([]schema.Route, 0, 32)
+	for _, rt := range c.Routes {
+		routes = append(routes, schema.Route{
+			Network: c.Name,
+			Prefix:  rt.Prefix,
+			NextHop: rt.NextHop,
+			Origin:  "static",
+		})
+	}
+	for _, rt := range storage.Route.ListBy(c.Name) {
+		routes = append(routes, models.NewRouteSchema(rt))
+	}
+	return routes
+}
+
+func (h Route) List(w http.ResponseWriter, r *http.Request) {
+	routes := make([]schema.Route, 0, 1024)
+	for _, c := range h.Switcher.Config().Network {
+		routes = append(routes, h.routes(c)...)
+	}
+	ResponseJson(w, routes)
+}
+
+func (h Route) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	c := h.Switcher.GetNetwork(vars["id"])
+	if c == nil {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	ResponseJson(w, h.routes(c))
+}
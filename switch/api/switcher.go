@@ -10,10 +10,16 @@ type Switcher interface {
 	UUID() string
 	UpTime() int64
 	Alias() string
-	AddLink(tenant string, c *config.Point)
-	DelLink(tenant, addr string)
+	AddLink(tenant string, c *config.Point) error
+	DelLink(tenant, addr string) error
 	Config() *config.Switch
 	Server() libol.SocketServer
+	Reload() schema.Reload
+	GetNetwork(name string) *config.Network
+	AddNetwork(c *config.Network) error
+	UpdateNetwork(c *config.Network) error
+	DelNetwork(name string) error
+	Metrics(m *libol.Metrics)
 }
 
 func NewWorkerSchema(s Switcher) schema.Worker {
@@ -56,6 +56,9 @@ func (h User) Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if user.Password != "" && !libol.IsHashed(user.Password) {
+		user.Password = libol.HashPassword(user.Password)
+	}
 	storage.User.Add(models.SchemaToUserModel(user))
 	ResponseMsg(w, 0, "")
 }
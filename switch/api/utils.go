@@ -28,6 +28,12 @@ func ResponseMsg(w http.ResponseWriter, code int, message string) {
 	ResponseJson(w, ret)
 }
 
+// ResponseText responds metrics in text exposition format.
+func ResponseText(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write(data)
+}
+
 func ResponseYaml(w http.ResponseWriter, v interface{}) {
 	str, err := yaml.Marshal(v)
 	if err == nil {
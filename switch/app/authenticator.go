@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net/http"
+	"time"
+)
+
+type Authenticator interface {
+	// Auth checks the user whose name is like 'user@network'.
+	Auth(user *models.User) error
+}
+
+func NewAuthenticator(c *config.Auth) Authenticator {
+	if c != nil && c.Type == "http" {
+		return NewHttpAuth(c.Url, time.Duration(c.Timeout)*time.Second)
+	}
+	return &LocalAuth{}
+}
+
+// LocalAuth checks password hashed in the users of storage.
+type LocalAuth struct {
+}
+
+func (a *LocalAuth) Auth(user *models.User) error {
+	nowUser := storage.User.Get(user.Name)
+	if nowUser == nil || !libol.CheckPassword(nowUser.Password, user.Password) {
+		return libol.NewErr("Auth failed.")
+	}
+	return nil
+}
+
+// HttpAuth forwards the user to an http endpoint, which accepts it
+// by responding 200.
+type HttpAuth struct {
+	Url    string
+	client *http.Client
+}
+
+func NewHttpAuth(url string, timeout time.Duration) *HttpAuth {
+	return &HttpAuth{
+		Url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (a *HttpAuth) Auth(user *models.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.Url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		libol.Error("HttpAuth.Auth: %s", err)
+		return libol.NewErr("Auth failed.")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		libol.Debug("HttpAuth.Auth: %s %s", user.Name, resp.Status)
+		return libol.NewErr("Auth failed.")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLocalAuth(t *testing.T) {
+	storage.User.Add(&models.User{
+		Name:     "hi@default",
+		Password: libol.HashPassword("12345"),
+	})
+	storage.User.Add(&models.User{
+		Name:     "old@default",
+		Password: "12345",
+	})
+	defer storage.User.Del("hi@default")
+	defer storage.User.Del("old@default")
+
+	auth := NewAuthenticator(&config.Auth{Type: "local"})
+	assert.Nil(t, auth.Auth(models.NewUser("hi@default", "12345")), "hashed.")
+	assert.NotNil(t, auth.Auth(models.NewUser("hi@default", "123456")), "wrong password.")
+	assert.NotNil(t, auth.Auth(models.NewUser("hi@other", "12345")), "no user.")
+	assert.Nil(t, auth.Auth(models.NewUser("old@default", "12345")), "plaintext.")
+}
+
+func TestHttpAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := &models.User{}
+		if err := json.NewDecoder(r.Body).Decode(user); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if user.Name == "hi@default" && user.Password == "12345" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	auth := NewAuthenticator(&config.Auth{Type: "http", Url: server.URL, Timeout: 5})
+	assert.Nil(t, auth.Auth(models.NewUser("hi@default", "12345")), "accepted.")
+	assert.NotNil(t, auth.Auth(models.NewUser("hi@default", "123456")), "rejected.")
+
+	auth = NewHttpAuth("http://127.0.0.1:1/auth", time.Second)
+	assert.NotNil(t, auth.Auth(models.NewUser("hi@default", "12345")), "unreachable.")
+}
+
+func TestPointAuthBackend(t *testing.T) {
+	c := config.Switch{
+		Network: []*config.Network{
+			{Name: "default"},
+			{Name: "remote", Auth: &config.Auth{Type: "http", Url: "http://127.0.0.1:1"}},
+		},
+	}
+	p := NewPointAuth(nil, c)
+	_, ok := p.Authenticator("default").(*LocalAuth)
+	assert.True(t, ok, "local for default.")
+	_, ok = p.Authenticator("remote").(*HttpAuth)
+	assert.True(t, ok, "http for remote.")
+	_, ok = p.Authenticator("unknown").(*LocalAuth)
+	assert.True(t, ok, "local for unknown.")
+}
+
+func TestPassword(t *testing.T) {
+	hashed := libol.HashPassword("12345")
+	assert.True(t, libol.IsHashed(hashed), "be hashed.")
+	assert.NotEqual(t, hashed, libol.HashPassword("12345"), "salted.")
+	assert.True(t, libol.CheckPassword(hashed, "12345"), "be the same.")
+	assert.False(t, libol.CheckPassword(hashed, "1234"), "not the same.")
+	assert.False(t, libol.CheckPassword(hashed[:len(hashed)-4], "12345"), "broken hash.")
+	assert.True(t, libol.CheckPassword("12345", "12345"), "plaintext.")
+	assert.False(t, libol.IsHashed("12345"), "plaintext.")
+}
@@ -0,0 +1,282 @@
+package app
+
+import (
+	"encoding/binary"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"time"
+)
+
+const (
+	DhcpOfferTime = 60 // secs to hold address offered.
+)
+
+// DhcpServer allocates addresses of network for hosts on the bridge,
+// and shares the lease table with points.
+type DhcpServer struct {
+	master Master
+	name   string
+	hwAddr []byte
+	server net.IP
+	router net.IP
+	dns    []net.IP
+	lease  uint32
+	mtu    uint16
+	device network.Taper
+}
+
+func NewDhcpServer(m Master, c *config.Network) *DhcpServer {
+	d := &DhcpServer{
+		master: m,
+		name:   c.Name,
+		hwAddr: libol.GenEthAddr(6),
+	}
+	if c.Dhcp == nil {
+		return d
+	}
+	d.server = net.ParseIP(c.Dhcp.Server).To4()
+	d.router = net.ParseIP(c.Dhcp.Router).To4()
+	for _, addr := range c.Dhcp.Dns {
+		if ip := net.ParseIP(addr).To4(); ip != nil {
+			d.dns = append(d.dns, ip)
+		}
+	}
+	d.lease = uint32(c.Dhcp.Lease)
+	d.mtu = uint16(c.Dhcp.Mtu)
+	return d
+}
+
+func (d *DhcpServer) Start() error {
+	if d.server == nil {
+		return libol.NewErr("DhcpServer.Start: %s no server address", d.name)
+	}
+	dev, err := d.master.NewTap(d.name)
+	if err != nil {
+		return err
+	}
+	d.device = dev
+	libol.Info("DhcpServer.Start: %s on %s", d.name, dev.Name())
+	libol.Go(func() { d.master.ReadTap(dev, d.OnFrame) })
+	return nil
+}
+
+func (d *DhcpServer) Stop() {
+	if d.device == nil {
+		return
+	}
+	libol.Info("DhcpServer.Stop: %s", d.name)
+	_ = d.device.Close()
+	d.device = nil
+}
+
+func (d *DhcpServer) OnFrame(frame *libol.FrameMessage) error {
+	if reply := d.Handle(frame.Frame()); reply != nil {
+		if _, err := d.device.Write(reply); err != nil {
+			libol.Error("DhcpServer.OnFrame: %s", err)
+		}
+	}
+	return nil
+}
+
+// Handle processes a request in the ethernet frame, and returns the
+// reply or nil.
+func (d *DhcpServer) Handle(data []byte) []byte {
+	eth, err := libol.NewEtherFromFrame(data)
+	if err != nil || !eth.IsIP4() {
+		return nil
+	}
+	data = data[eth.Len:]
+	ip, err := libol.NewIpv4FromFrame(data)
+	if err != nil || ip.Protocol != libol.IpUdp {
+		return nil
+	}
+	data = data[int(ip.HeaderLen)*4:]
+	udp, err := libol.NewUdpFromFrame(data)
+	if err != nil || udp.Destination != libol.DhcpServerPort {
+		return nil
+	}
+	req, err := libol.NewDhcpFromFrame(data[udp.Len:])
+	if err != nil || req.Op != libol.DhcpBootRequest {
+		return nil
+	}
+	n := storage.Network.Get(d.name)
+	if n == nil {
+		return nil
+	}
+	addr := req.HwAddr().String()
+	libol.Debug("DhcpServer.Handle: %d from %s", req.Type(), addr)
+	switch req.Type() {
+	case libol.DhcpDiscover:
+		old := storage.Network.GetLease(d.name, addr)
+		ipStr, _ := storage.Network.GetFreeAddr(addr, n)
+		if ipStr == "" {
+			libol.Warn("DhcpServer.Handle: %s no free address", d.name)
+			return nil
+		}
+		if old == nil || old.Expired() || old.Expire < time.Now().Unix()+DhcpOfferTime {
+			storage.Network.Renew(d.name, addr, DhcpOfferTime)
+		} else {
+			storage.Network.Renew(d.name, addr, old.Expire-time.Now().Unix())
+		}
+		return d.reply(req, libol.DhcpOffer, net.ParseIP(ipStr), n)
+	case libol.DhcpRequest:
+		if server := req.Option(libol.DhcpOptServer); server != nil && !d.server.Equal(server) {
+			return nil // selected other server.
+		}
+		want := net.IP(req.Option(libol.DhcpOptRequestIp))
+		if len(want) != 4 {
+			want = net.IP(req.CIAddr)
+		}
+		if !d.allocate(addr, want, n) {
+			libol.Warn("DhcpServer.Handle: NAK %s for %s", want, addr)
+			return d.reply(req, libol.DhcpNak, nil, n)
+		}
+		storage.Network.Renew(d.name, addr, int64(d.lease))
+		libol.Info("DhcpServer.Handle: ACK %s for %s", want, addr)
+		return d.reply(req, libol.DhcpAck, want, n)
+	case libol.DhcpRelease:
+		if l := storage.Network.GetLease(d.name, addr); l != nil && l.Address == net.IP(req.CIAddr).String() {
+			libol.Info("DhcpServer.Handle: release %s from %s", l.Address, addr)
+			storage.Network.FreeAddr(d.name, addr)
+		}
+	case libol.DhcpDecline:
+		if want := net.IP(req.Option(libol.DhcpOptRequestIp)); len(want) == 4 {
+			libol.Warn("DhcpServer.Handle: %s declined by %s", want, addr)
+			storage.Network.FreeAddr(d.name, addr)
+			// hold the address in conflict until lease expired.
+			holder := "declined:" + want.String()
+			if err := storage.Network.AddUsedAddr(holder, d.name, want.String()); err == nil {
+				storage.Network.Renew(d.name, holder, int64(d.lease))
+			}
+		}
+	case libol.DhcpInform:
+		return d.reply(req, libol.DhcpAck, nil, n)
+	}
+	return nil
+}
+
+func inRange(ip net.IP, n *models.Network) bool {
+	start := net.ParseIP(n.IpStart).To4()
+	end := net.ParseIP(n.IpEnd).To4()
+	if ip.To4() == nil || start == nil || end == nil {
+		return false
+	}
+	value := binary.BigEndian.Uint32(ip.To4())
+	return value >= binary.BigEndian.Uint32(start) && value <= binary.BigEndian.Uint32(end)
+}
+
+// allocate binds the address to the hardware address if it is free in
+// range, or reserved for the hardware address.
+func (d *DhcpServer) allocate(addr string, ip net.IP, n *models.Network) bool {
+	l := storage.Network.GetLease(n.Name, addr)
+	reserved := l != nil && l.Static && l.Address == ip.String()
+	if !reserved && !inRange(ip, n) {
+		return false
+	}
+	return storage.Network.AddUsedAddr(addr, n.Name, ip.String()) == nil
+}
+
+func (d *DhcpServer) options(resp *libol.Dhcp, n *models.Network) {
+	if mask := net.ParseIP(n.Netmask).To4(); mask != nil {
+		resp.SetOption(libol.DhcpOptNetmask, mask)
+	}
+	if d.router != nil {
+		resp.SetOption(libol.DhcpOptRouter, d.router)
+	}
+	if len(d.dns) > 0 {
+		value := make([]byte, 0, 4*len(d.dns))
+		for _, ip := range d.dns {
+			value = append(value, ip...)
+		}
+		resp.SetOption(libol.DhcpOptDns, value)
+	}
+	if d.mtu > 0 {
+		value := make([]byte, 2)
+		binary.BigEndian.PutUint16(value, d.mtu)
+		resp.SetOption(libol.DhcpOptMtu, value)
+	}
+	// clients ignore router option if classless routes given.
+	routes := make([]byte, 0, 64)
+	if d.router != nil {
+		_, prefix, _ := net.ParseCIDR("0.0.0.0/0")
+		routes = append(routes, libol.DhcpRoute(prefix, d.router)...)
+	}
+	for _, rt := range n.Routes {
+		_, prefix, err := net.ParseCIDR(rt.Prefix)
+		nexthop := net.ParseIP(rt.NextHop).To4()
+		if err != nil || prefix.IP.To4() == nil || nexthop == nil {
+			continue
+		}
+		routes = append(routes, libol.DhcpRoute(prefix, nexthop)...)
+	}
+	if len(routes) > 0 && len(routes) <= 255 {
+		resp.SetOption(libol.DhcpOptRoutes, routes)
+	}
+}
+
+func (d *DhcpServer) reply(req *libol.Dhcp, t uint8, yiaddr net.IP, n *models.Network) []byte {
+	resp := libol.NewDhcp()
+	resp.Op = libol.DhcpBootReply
+	resp.HType = req.HType
+	resp.HLen = req.HLen
+	resp.Xid = req.Xid
+	resp.Flags = req.Flags
+	resp.GIAddr = req.GIAddr
+	resp.CHAddr = req.CHAddr
+	if req.Type() == libol.DhcpInform {
+		copy(resp.CIAddr, req.CIAddr)
+	}
+	if yiaddr != nil {
+		copy(resp.YIAddr, yiaddr.To4())
+	}
+	resp.SetOption(libol.DhcpOptType, []byte{t})
+	resp.SetOption(libol.DhcpOptServer, d.server)
+	if t == libol.DhcpOffer || t == libol.DhcpAck {
+		if yiaddr != nil {
+			lease := make([]byte, 12)
+			binary.BigEndian.PutUint32(lease[0:4], d.lease)
+			binary.BigEndian.PutUint32(lease[4:8], d.lease/2)
+			binary.BigEndian.PutUint32(lease[8:12], d.lease/8*7)
+			resp.SetOption(libol.DhcpOptLease, lease[0:4])
+			resp.SetOption(libol.DhcpOptRenewal, lease[4:8])
+			resp.SetOption(libol.DhcpOptRebind, lease[8:12])
+		}
+		d.options(resp, n)
+	}
+	payload := resp.Encode()
+
+	// unicast to client if it has address already.
+	dstIp := net.IPv4bcast.To4()
+	dstHw := libol.BROADED
+	if ciaddr := net.IP(req.CIAddr); !ciaddr.Equal(net.IPv4zero) && t != libol.DhcpNak {
+		dstIp = ciaddr.To4()
+		dstHw = req.HwAddr()
+	}
+
+	udp := libol.NewUdp()
+	udp.Source = libol.DhcpServerPort
+	udp.Destination = libol.DhcpClientPort
+	udp.Length = uint16(udp.Len + len(payload))
+
+	ip := libol.NewIpv4()
+	ip.Protocol = libol.IpUdp
+	ip.ToL = 64
+	ip.TotalLen = uint16(ip.Len) + udp.Length
+	copy(ip.Source, d.server)
+	copy(ip.Destination, dstIp)
+	ip.HeaderChecksum = libol.IpChecksum(ip.Encode())
+
+	eth := libol.NewEtherIP4()
+	copy(eth.Dst, dstHw)
+	copy(eth.Src, d.hwAddr)
+
+	frame := make([]byte, 0, eth.Len+ip.Len+int(udp.Length))
+	frame = append(frame, eth.Encode()...)
+	frame = append(frame, ip.Encode()...)
+	frame = append(frame, udp.Encode()...)
+	return append(frame, payload...)
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+// tapMaster creates userspace taps on a virtual bridge.
+type tapMaster struct {
+	bridge *network.VirtualBridge
+}
+
+func (m *tapMaster) NewTap(tenant string) (network.Taper, error) {
+	dev, err := network.NewUserSpaceTap(tenant, network.TapConfig{Type: network.TAP})
+	if err != nil {
+		return nil, err
+	}
+	dev.Up()
+	_ = m.bridge.AddSlave(dev)
+	return dev, nil
+}
+
+func (m *tapMaster) ReadTap(dev network.Taper, readAt func(f *libol.FrameMessage) error) {
+	for {
+		frame := libol.NewFrameMessage()
+		n, err := dev.Read(frame.Frame())
+		if err != nil {
+			return
+		}
+		frame.SetSize(n)
+		if err := readAt(frame); err != nil {
+			return
+		}
+	}
+}
+
+func (m *tapMaster) SetPeer(dev network.Taper) error {
+	return m.bridge.SetPeer(dev.Name())
+}
+
+func (m *tapMaster) UUID() string {
+	return "tap-master"
+}
+
+func (m *tapMaster) OffClient(client libol.SocketClient) {
+}
+
+func (m *tapMaster) Linked(tenant, user, addr string) (bool, bool) {
+	return false, false
+}
+
+func (m *tapMaster) Learn(tenant, origin string, routes []*models.Route) {
+}
+
+func (m *tapMaster) Advertise(tenant string) []*models.Route {
+	return nil
+}
+
+func dhcpRequest(hw net.HardwareAddr, t uint8, want net.IP) []byte {
+	req := libol.NewDhcp()
+	req.Op = libol.DhcpBootRequest
+	req.Xid = 0x12345678
+	copy(req.CHAddr, hw)
+	req.SetOption(libol.DhcpOptType, []byte{t})
+	if t == libol.DhcpRelease {
+		copy(req.CIAddr, want.To4())
+	} else if want != nil {
+		req.SetOption(libol.DhcpOptRequestIp, want.To4())
+	}
+	payload := req.Encode()
+
+	udp := libol.NewUdp()
+	udp.Source = libol.DhcpClientPort
+	udp.Destination = libol.DhcpServerPort
+	udp.Length = uint16(udp.Len + len(payload))
+	ip := libol.NewIpv4()
+	ip.Protocol = libol.IpUdp
+	ip.TotalLen = uint16(ip.Len) + udp.Length
+	copy(ip.Destination, net.IPv4bcast.To4())
+	eth := libol.NewEtherIP4()
+	copy(eth.Dst, libol.BROADED)
+	copy(eth.Src, hw)
+
+	frame := append(eth.Encode(), ip.Encode()...)
+	frame = append(frame, udp.Encode()...)
+	return append(frame, payload...)
+}
+
+func dhcpReply(t *testing.T, dev network.Taper) *libol.Dhcp {
+	data := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 1600)
+		n, err := dev.Read(buf)
+		if err == nil {
+			data <- buf[:n]
+		}
+	}()
+	select {
+	case frame := <-data:
+		offset := libol.EtherLen + libol.Ipv4Len + libol.UdpLen
+		ip, err := libol.NewIpv4FromFrame(frame[libol.EtherLen:])
+		assert.Nil(t, err, "decode ipv4.")
+		assert.Equal(t, libol.IpChecksum(frame[libol.EtherLen:offset-libol.UdpLen]), uint16(0), "checksum.")
+		assert.Equal(t, uint8(libol.IpUdp), ip.Protocol, "be udp.")
+		resp, err := libol.NewDhcpFromFrame(frame[offset:])
+		assert.Nil(t, err, "decode dhcp.")
+		return resp
+	case <-time.After(2 * time.Second):
+		return nil
+	}
+}
+
+func TestDhcpServer(t *testing.T) {
+	storage.Network.Add(&models.Network{
+		Name:    "dhcp",
+		IpStart: "192.168.10.100",
+		IpEnd:   "192.168.10.101",
+		Netmask: "255.255.255.0",
+		Routes: []*models.Route{
+			{Prefix: "10.0.0.0/8", NextHop: "192.168.10.2"},
+		},
+	})
+	defer storage.Network.Del("dhcp")
+
+	bridge := network.NewVirtualBridge("br-dhcp", 1500)
+	master := &tapMaster{bridge: bridge}
+	cfg := &config.Network{
+		Name: "dhcp",
+		Dhcp: &config.Dhcp{
+			Server: "192.168.10.1",
+			Router: "192.168.10.1",
+			Dns:    []string{"8.8.8.8"},
+			Lease:  3600,
+			Mtu:    1400,
+		},
+	}
+	server := NewDhcpServer(master, cfg)
+	assert.Nil(t, server.Start(), "start server.")
+	defer server.Stop()
+
+	client, _ := master.NewTap("dhcp")
+	defer client.Close()
+	hw1, _ := net.ParseMAC("00:16:3e:00:00:01")
+	hw2, _ := net.ParseMAC("00:16:3e:00:00:02")
+
+	_, _ = client.Write(dhcpRequest(hw1, libol.DhcpDiscover, nil))
+	offer := dhcpReply(t, client)
+	assert.NotNil(t, offer, "receive offer.")
+	assert.Equal(t, uint8(libol.DhcpOffer), offer.Type(), "be offer.")
+	assert.Equal(t, "192.168.10.100", net.IP(offer.YIAddr).String(), "offered address.")
+	assert.Equal(t, []byte{255, 255, 255, 0}, offer.Option(libol.DhcpOptNetmask), "netmask.")
+	assert.Equal(t, []byte{192, 168, 10, 1}, offer.Option(libol.DhcpOptRouter), "router.")
+	assert.Equal(t, []byte{8, 8, 8, 8}, offer.Option(libol.DhcpOptDns), "dns.")
+	assert.Equal(t, []byte{0x05, 0x78}, offer.Option(libol.DhcpOptMtu), "mtu.")
+	assert.Equal(t, []byte{0, 0, 0x0e, 0x10}, offer.Option(libol.DhcpOptLease), "lease.")
+	assert.Equal(t, []byte{0, 192, 168, 10, 1, 8, 10, 192, 168, 10, 2},
+		offer.Option(libol.DhcpOptRoutes), "classless routes.")
+
+	_, _ = client.Write(dhcpRequest(hw1, libol.DhcpRequest, net.IP(offer.YIAddr)))
+	ack := dhcpReply(t, client)
+	assert.NotNil(t, ack, "receive ack.")
+	assert.Equal(t, uint8(libol.DhcpAck), ack.Type(), "be ack.")
+	assert.Equal(t, "192.168.10.100", net.IP(ack.YIAddr).String(), "acked address.")
+	lease := storage.Network.GetLease("dhcp", hw1.String())
+	assert.NotNil(t, lease, "shared lease.")
+	assert.Equal(t, "192.168.10.100", lease.Address, "shared lease.")
+	assert.True(t, lease.Expire > time.Now().Unix()+3000, "lease time.")
+
+	_, _ = client.Write(dhcpRequest(hw2, libol.DhcpRequest, net.ParseIP("192.168.10.100")))
+	nak := dhcpReply(t, client)
+	assert.NotNil(t, nak, "receive nak.")
+	assert.Equal(t, uint8(libol.DhcpNak), nak.Type(), "be nak.")
+
+	_, _ = client.Write(dhcpRequest(hw2, libol.DhcpDiscover, nil))
+	offer = dhcpReply(t, client)
+	assert.NotNil(t, offer, "receive offer.")
+	assert.Equal(t, "192.168.10.101", net.IP(offer.YIAddr).String(), "next address.")
+
+	_, _ = client.Write(dhcpRequest(hw1, libol.DhcpRelease, net.ParseIP("192.168.10.100")))
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, storage.Network.GetLease("dhcp", hw1.String()), "released.")
+
+	storage.Network.FreeAddr("dhcp", hw2.String())
+}
+
+func TestDhcpServerStatic(t *testing.T) {
+	storage.Network.Add(&models.Network{
+		Name:    "dhcp-static",
+		IpStart: "192.168.11.100",
+		IpEnd:   "192.168.11.101",
+		Netmask: "255.255.255.0",
+	})
+	defer storage.Network.Del("dhcp-static")
+
+	bridge := network.NewVirtualBridge("br-static", 1500)
+	master := &tapMaster{bridge: bridge}
+	cfg := &config.Network{
+		Name: "dhcp-static",
+		Dhcp: &config.Dhcp{
+			Server: "192.168.11.1",
+			Lease:  3600,
+		},
+	}
+	server := NewDhcpServer(master, cfg)
+	assert.Nil(t, server.Start(), "start server.")
+	defer server.Stop()
+
+	client, _ := master.NewTap("dhcp-static")
+	defer client.Close()
+	hw, _ := net.ParseMAC("00:16:3e:00:00:03")
+	assert.Nil(t, storage.Network.AddStatic(hw.String(), "dhcp-static", "192.168.11.10"), "reserve.")
+	defer storage.Network.DelLease("dhcp-static", hw.String())
+
+	_, _ = client.Write(dhcpRequest(hw, libol.DhcpDiscover, nil))
+	offer := dhcpReply(t, client)
+	assert.NotNil(t, offer, "receive offer.")
+	assert.Equal(t, "192.168.11.10", net.IP(offer.YIAddr).String(), "reserved address.")
+
+	_, _ = client.Write(dhcpRequest(hw, libol.DhcpRequest, net.IP(offer.YIAddr)))
+	ack := dhcpReply(t, client)
+	assert.NotNil(t, ack, "receive ack.")
+	assert.Equal(t, uint8(libol.DhcpAck), ack.Type(), "be ack.")
+	lease := storage.Network.GetLease("dhcp-static", hw.String())
+	assert.NotNil(t, lease, "reserved lease.")
+	assert.True(t, lease.Static, "be static.")
+	assert.Equal(t, int64(0), lease.Expire, "never expired.")
+
+	storage.Network.Release(hw.String())
+	_, _ = client.Write(dhcpRequest(hw, libol.DhcpRelease, net.ParseIP("192.168.11.10")))
+	time.Sleep(100 * time.Millisecond)
+	lease = storage.Network.GetLease("dhcp-static", hw.String())
+	assert.NotNil(t, lease, "kept after release.")
+	assert.Equal(t, "192.168.11.10", lease.Address, "kept after release.")
+	assert.True(t, lease.Static, "kept after release.")
+}
@@ -2,12 +2,17 @@ package app
 
 import (
 	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/network"
 )
 
 type Master interface {
 	ReadTap(device network.Taper, readAt func(f *libol.FrameMessage) error)
 	NewTap(tenant string) (network.Taper, error)
+	SetPeer(dev network.Taper) error
 	UUID() string
 	OffClient(client libol.SocketClient)
+	Linked(tenant, user, addr string) (link, peer bool)
+	Learn(tenant, origin string, routes []*models.Route)
+	Advertise(tenant string) []*models.Route
 }
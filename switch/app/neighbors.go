@@ -37,17 +37,25 @@ func (e *Neighbors) OnFrame(client libol.SocketClient, frame *libol.FrameMessage
 		libol.Warn("Neighbors.OnFrame %s", err)
 		return err
 	}
-	eth := proto.Eth
-	if !eth.IsArp() {
-		return nil
-	}
-	arp := proto.Arp
-	if arp.IsIP4() {
+	if arp := proto.Arp; arp != nil && arp.IsIP4() {
 		if arp.OpCode == libol.ArpRequest ||
 			arp.OpCode == libol.ArpReply {
 			n := models.NewNeighbor(arp.SHwAddr, arp.SIpAddr, client)
 			e.AddNeighbor(n)
 		}
+	} else if ndp := proto.Ndp; ndp != nil {
+		// solicitation carries source and advertisement carries target.
+		switch proto.Icmp6.Type {
+		case libol.Icmp6NeighborSolicit:
+			hw := ndp.LinkAddr(libol.NdpOptSrcLink)
+			if hw != nil && !net.IP(proto.Ip6.Source).IsUnspecified() {
+				e.AddNeighbor(models.NewNeighbor(hw, proto.Ip6.Source, client))
+			}
+		case libol.Icmp6NeighborAdvert:
+			if hw := ndp.LinkAddr(libol.NdpOptTgtLink); hw != nil {
+				e.AddNeighbor(models.NewNeighbor(hw, ndp.Target, client))
+			}
+		}
 	}
 	return nil
 }
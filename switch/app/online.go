@@ -41,24 +41,12 @@ func (o *Online) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 		libol.Warn("Online.OnFrame %s", err)
 		return err
 	}
-	if proto.Ip4 != nil {
-		ip := proto.Ip4
-		line := models.NewLine(libol.EthIp4)
-		line.IpSource = ip.Source
-		line.IpDest = ip.Destination
-		line.IpProtocol = ip.Protocol
-		if proto.Tcp != nil {
-			tcp := proto.Tcp
-			line.PortDest = tcp.Destination
-			line.PortSource = tcp.Source
-		} else if proto.Udp != nil {
-			udp := proto.Udp
-			line.PortDest = udp.Destination
-			line.PortSource = udp.Source
-		} else {
-			line.PortDest = 0
-			line.PortSource = 0
-		}
+	if family := proto.Family(); family != 0 {
+		line := models.NewLine(family)
+		line.IpSource = proto.Source()
+		line.IpDest = proto.Destination()
+		line.IpProtocol = proto.Protocol()
+		line.PortSource, line.PortDest = proto.Ports()
 		o.AddLine(line)
 	}
 	return nil
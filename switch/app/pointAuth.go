@@ -1,27 +1,65 @@
 package app
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
 	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
 	"strings"
+	"sync"
+	"time"
 )
 
 type PointAuth struct {
-	success int
-	failed  int
-	master  Master
+	success     int
+	failed      int
+	master      Master
+	lock        sync.RWMutex
+	requireCert bool
+	auths       map[string]Authenticator
+	lockout     config.Lockout
 }
 
 func NewPointAuth(m Master, c config.Switch) (p *PointAuth) {
 	p = &PointAuth{
-		master: m,
+		master:      m,
+		requireCert: c.Cert.CaFile != "" && c.Cert.RequireClient,
 	}
+	p.Reload(c)
 	return
 }
 
+// Reload rebuilds backends of networks and lockout from configuration.
+func (p *PointAuth) Reload(c config.Switch) {
+	auths := make(map[string]Authenticator, 32)
+	for _, n := range c.Network {
+		auths[n.Name] = NewAuthenticator(n.Auth)
+	}
+	lockout := config.Lockout{}
+	if c.Lockout != nil {
+		lockout = *c.Lockout
+	}
+	lockout.Default()
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.auths = auths
+	p.lockout = lockout
+}
+
+// Authenticator returns the backend of network, and local by default.
+func (p *PointAuth) Authenticator(network string) Authenticator {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if auth, ok := p.auths[network]; ok {
+		return auth
+	}
+	return &LocalAuth{}
+}
+
 func (p *PointAuth) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
 	if libol.HasLog(libol.LOG) {
 		libol.Log("PointAuth.OnFrame %s.", frame)
@@ -60,11 +98,27 @@ func (p *PointAuth) handleLogin(client libol.SocketClient, data string) error {
 		return nil
 	}
 
+	addr := remoteIp(client)
+	if err := p.checkLocked("ip", addr); err != nil {
+		client.SetStatus(libol.ClUnAuth)
+		return err
+	}
+
 	user := models.NewUser("", "")
 	if err := json.Unmarshal([]byte(data), user); err != nil {
+		p.onFailed(addr, "")
 		return libol.NewErr("Invalid json data.")
 	}
 
+	if cert := client.PeerCert(); cert != nil {
+		return p.handleCert(client, user, cert)
+	} else if p.requireCert {
+		p.failed++
+		client.SetStatus(libol.ClUnAuth)
+		p.onFailed(addr, "")
+		return libol.NewErr("Certificate required.")
+	}
+
 	// to support lower version
 	if user.Network == "" {
 		if strings.Contains(user.Name, "@") {
@@ -79,19 +133,96 @@ func (p *PointAuth) handleLogin(client libol.SocketClient, data string) error {
 	}
 
 	libol.Info("PointAuth.handleLogin: %s on %s", name, user.Alias)
-	nowUser := storage.User.Get(name)
-	if nowUser != nil {
-		if nowUser.Password == user.Password {
-			p.success++
-			client.SetStatus(libol.ClAuth)
-			libol.Info("PointAuth.handleLogin: %s auth", client.Addr())
-			_ = p.onAuth(client, user)
-			return nil
+	if err := p.checkLocked("user", name); err != nil {
+		client.SetStatus(libol.ClUnAuth)
+		return err
+	}
+	user.Name = name
+	if err := p.Authenticator(user.Network).Auth(user); err != nil {
+		p.failed++
+		client.SetStatus(libol.ClUnAuth)
+		p.onFailed(addr, name)
+		return err
+	}
+	storage.Lockout.Del("user:" + name)
+	p.success++
+	client.SetStatus(libol.ClAuth)
+	libol.Info("PointAuth.handleLogin: %s auth", client.Addr())
+	_ = p.onAuth(client, user)
+	return nil
+}
+
+func remoteIp(client libol.SocketClient) string {
+	// stream of multiplexed connection has id after address.
+	addr := strings.SplitN(client.RemoteAddr(), "/", 2)[0]
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func (p *PointAuth) checkLocked(t, source string) error {
+	if remain := storage.Lockout.Locked(t, source); remain > 0 {
+		libol.Warn("PointAuth.checkLocked: %s:%s locked %ds", t, source, remain)
+		return libol.NewErr("Locked, retry after %ds.", remain)
+	}
+	return nil
+}
+
+// onFailed records failures by address and username, and delays the
+// response exponentially with failures in window.
+func (p *PointAuth) onFailed(addr, name string) {
+	p.lock.RLock()
+	lo := p.lockout
+	p.lock.RUnlock()
+	window, duration := int64(lo.Window), int64(lo.Duration)
+	count := storage.Lockout.Fail("ip", addr, window, lo.Threshold, duration)
+	if name != "" {
+		if n := storage.Lockout.Fail("user", name, window, lo.Threshold, duration); n > count {
+			count = n
 		}
 	}
-	p.failed++
-	client.SetStatus(libol.ClUnAuth)
-	return libol.NewErr("Auth failed.")
+	shift := count - 1
+	if shift > 5 {
+		shift = 5
+	}
+	delay := time.Duration(lo.Delay<<uint(shift)) * time.Millisecond
+	libol.Info("PointAuth.onFailed: %s %s failed %d, delay %s", addr, name, count, delay)
+	time.Sleep(delay)
+}
+
+// CertIdentity returns user and network from the first email of SAN,
+// or the common name of subject, such as 'user@network'.
+func CertIdentity(cert *x509.Certificate) (name, network string) {
+	id := cert.Subject.CommonName
+	if len(cert.EmailAddresses) > 0 {
+		id = cert.EmailAddresses[0]
+	}
+	if strings.Contains(id, "@") {
+		values := strings.SplitN(id, "@", 2)
+		return values[0], values[1]
+	}
+	return id, "default"
+}
+
+// handleCert authenticates point by the certificate verified on tls,
+// and password is not required.
+func (p *PointAuth) handleCert(client libol.SocketClient, user *models.User, cert *x509.Certificate) error {
+	name, network := CertIdentity(cert)
+	if name == "" {
+		p.failed++
+		client.SetStatus(libol.ClUnAuth)
+		return libol.NewErr("No identity in certificate.")
+	}
+	user.Name = name + "@" + network
+	user.Network = network
+	user.Password = ""
+	libol.Info("PointAuth.handleCert: %s on %s", user.Name, user.Alias)
+	p.success++
+	client.SetStatus(libol.ClAuth)
+	libol.Info("PointAuth.handleCert: %s auth", client.Addr())
+	_ = p.onAuth(client, user)
+	return nil
 }
 
 func (p *PointAuth) onAuth(client libol.SocketClient, user *models.User) error {
@@ -100,24 +231,74 @@ func (p *PointAuth) onAuth(client libol.SocketClient, user *models.User) error {
 	}
 
 	libol.Info("PointAuth.onAuth: %s", client)
+	uuid := user.UUID
+	if uuid == "" {
+		uuid = user.Alias
+	}
+	om := storage.Point.GetByUUID(uuid)
+	if om != nil && om.Bond != nil && user.Session == om.Session && om.Network == user.Network {
+		return p.joinBond(client, om)
+	}
 	d, err := p.master.NewTap(user.Network)
 	if err != nil {
 		return err
 	}
 	m := models.NewPoint(client, d)
 	m.Alias = user.Alias
-	m.UUID = user.UUID
+	m.UUID = uuid
 	m.Network = user.Network
-	if m.UUID == "" {
-		m.UUID = user.Alias
+	if user.Link {
+		// link of switch only if configured on this one.
+		m.Link, m.Peer = p.master.Linked(user.Network, user.Name, client.RemoteAddr())
+		if !m.Link {
+			libol.Warn("PointAuth.onAuth: %s %s not a link", client, user.Name)
+		}
+	}
+	if m.Peer {
+		if err := p.master.SetPeer(d); err != nil {
+			libol.Warn("PointAuth.onAuth: %s", err)
+		}
 	}
 	// free point has same uuid.
-	if om := storage.Point.GetByUUID(m.UUID); om != nil {
-		p.master.OffClient(om.Client)
+	if om != nil {
+		if om.Bond != nil {
+			for _, c := range om.Bond.Paths() {
+				p.master.OffClient(c)
+			}
+		} else {
+			p.master.OffClient(om.Client)
+		}
 	}
 	client.SetPrivate(m)
 	storage.Point.Add(m)
-	libol.Go(func() { p.master.ReadTap(d, client.WriteMsg) })
+	if user.Bond != "" && user.Session != "" {
+		m.Bond = libol.NewBond(user.Bond)
+		m.Session = user.Session
+		client.SetMaxSize(m.Bond.MaxSize(client.MaxSize()))
+		m.Bond.Add(client)
+		libol.Go(func() { p.master.ReadTap(d, m.Bond.WriteMsg) })
+	} else {
+		libol.Go(func() { p.master.ReadTap(d, client.WriteMsg) })
+	}
+	return nil
+}
+
+// joinBond adds client as a path of point bonded, and the device of
+// point is shared by its paths.
+func (p *PointAuth) joinBond(client libol.SocketClient, om *models.Point) error {
+	m := models.NewPoint(client, om.Device)
+	m.Alias = om.Alias
+	m.UUID = om.UUID
+	m.Network = om.Network
+	m.Link = om.Link
+	m.Peer = om.Peer
+	m.Bond = om.Bond
+	m.Session = om.Session
+	client.SetMaxSize(m.Bond.MaxSize(client.MaxSize()))
+	m.Bond.Add(client)
+	client.SetPrivate(m)
+	storage.Point.Add(m)
+	libol.Info("PointAuth.joinBond: %s has %d paths", m.UUID, m.Bond.Size())
 	return nil
 }
 
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPointAuthLockout(t *testing.T) {
+	defer storage.Lockout.Clear()
+	c := config.Switch{
+		Lockout: &config.Lockout{Window: 60, Threshold: 3, Duration: 60, Delay: 10},
+	}
+	p := NewPointAuth(nil, c)
+
+	start := time.Now()
+	p.onFailed("192.168.1.10", "hi@default")
+	p.onFailed("192.168.1.10", "hi@default")
+	assert.Nil(t, p.checkLocked("ip", "192.168.1.10"), "not locked.")
+	// delay 10ms and 20ms.
+	assert.True(t, time.Since(start) >= 30*time.Millisecond, "delayed.")
+
+	start = time.Now()
+	p.onFailed("192.168.1.11", "hi@default")
+	assert.True(t, time.Since(start) >= 40*time.Millisecond, "delayed by user.")
+	assert.NotNil(t, p.checkLocked("user", "hi@default"), "user locked.")
+	assert.Nil(t, p.checkLocked("ip", "192.168.1.11"), "ip not locked.")
+
+	for i := 0; i < 3; i++ {
+		p.onFailed("192.168.1.12", "")
+	}
+	assert.NotNil(t, p.checkLocked("ip", "192.168.1.12"), "ip locked.")
+
+	storage.Lockout.Del("ip:192.168.1.12")
+	assert.Nil(t, p.checkLocked("ip", "192.168.1.12"), "unlocked.")
+	storage.Lockout.Clear()
+	assert.Nil(t, p.checkLocked("user", "hi@default"), "cleared.")
+}
+
+func TestPointAuthBond(t *testing.T) {
+	master := &tapMaster{bridge: network.NewVirtualBridge("br-bond", 1500)}
+	p := NewPointAuth(master, config.Switch{})
+	newClient := func(addr string) libol.SocketClient {
+		c := libol.NewTcpClient(addr, &libol.TcpConfig{})
+		c.SetStatus(libol.ClAuth)
+		return c
+	}
+	user := &models.User{Name: "hi@bond", Network: "bond", UUID: "bond-uuid", Bond: libol.BondHash, Session: "s1"}
+
+	c1 := newClient("192.168.1.10:1")
+	defer storage.Point.Del(c1.Addr())
+	assert.Nil(t, p.onAuth(c1, user), "auth.")
+	m1 := storage.Point.Get(c1.Addr())
+	assert.NotNil(t, m1, "added.")
+	assert.NotNil(t, m1.Bond, "bonded.")
+
+	// joined by the same session.
+	c2 := newClient("192.168.2.10:1")
+	defer storage.Point.Del(c2.Addr())
+	assert.Nil(t, p.onAuth(c2, user), "auth.")
+	m2 := storage.Point.Get(c2.Addr())
+	assert.NotNil(t, m2, "added.")
+	assert.Equal(t, m1.Device, m2.Device, "shared device.")
+	assert.Equal(t, 2, m1.Bond.Size(), "two paths.")
+
+	storage.Point.Del(c1.Addr())
+	assert.Equal(t, 1, m1.Bond.Size(), "one path.")
+	assert.Equal(t, c2.Addr(), storage.Point.GetAddr(user.UUID), "be the same.")
+
+	// restarted with new session.
+	user.Session = "s2"
+	c3 := newClient("192.168.1.10:2")
+	defer storage.Point.Del(c3.Addr())
+	assert.Nil(t, p.onAuth(c3, user), "auth.")
+	m3 := storage.Point.GetByUUID(user.UUID)
+	assert.Equal(t, c3, m3.Client, "be the same.")
+	assert.NotEqual(t, m2.Bond, m3.Bond, "new bond.")
+	assert.NotEqual(t, m2.Device, m3.Device, "new device.")
+}
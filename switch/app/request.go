@@ -39,6 +39,8 @@ func (r *WithRequest) OnFrame(client libol.SocketClient, frame *libol.FrameMessa
 		r.OnIpAddr(client, body)
 	case "left=":
 		r.OnLeave(client, body)
+	case "rout=":
+		r.OnRoute(client, body)
 	case "logi=":
 		libol.Debug("WithRequest.OnFrame %s: %s", action, body)
 	default:
@@ -82,7 +84,7 @@ func (r *WithRequest) OnIpAddr(client libol.SocketClient, data string) {
 	var resp *models.Network
 	if rcvNet.IfAddr == "" {
 		ipStr, netmask := storage.Network.GetFreeAddr(uuid, net)
-		if ipStr != "" {
+		if ipStr != "" || (net != nil && net.Prefix6 != "") {
 			resp = &models.Network{
 				Name:    net.Name,
 				IfAddr:  ipStr,
@@ -94,21 +96,60 @@ func (r *WithRequest) OnIpAddr(client libol.SocketClient, data string) {
 		}
 	} else {
 		ipAddr := strings.SplitN(rcvNet.IfAddr, "/", 2)[0]
-		storage.Network.AddUsedAddr(uuid, ipAddr)
+		if err := storage.Network.AddUsedAddr(uuid, rcvNet.Name, ipAddr); err != nil {
+			libol.Warn("WithRequest.OnIpAddr: %s", err)
+			_ = client.WriteResp("ipaddr", err.Error())
+			return
+		}
+		storage.Network.Hold(rcvNet.Name, uuid)
 		resp = rcvNet
 	}
+	if resp != nil && net != nil && net.Prefix6 != "" && resp.IfAddr6 == "" {
+		resp.IfAddr6 = storage.Network.GetFreeAddr6(uuid, net)
+		resp.Prefix6 = net.Prefix6
+	}
+	if resp != nil && resp.IfAddr == "" && resp.IfAddr6 == "" {
+		resp = nil
+	}
+	if resp != nil && net != nil {
+		resp.Dns = net.Dns
+		resp.Domains = net.Domains
+	}
 	if resp != nil {
 		libol.Cmd("WithRequest.OnIpAddr: resp %s", resp)
 		if respStr, err := json.Marshal(resp); err == nil {
 			_ = client.WriteResp("ipaddr", string(respStr))
 		}
-		libol.Info("WithRequest.OnIpAddr: %s for %s", resp.IfAddr, client)
+		libol.Info("WithRequest.OnIpAddr: %s %s for %s", resp.IfAddr, resp.IfAddr6, client)
 	} else {
 		libol.Error("WithRequest.OnIpAddr: %s no free address", rcvNet.Name)
 		_ = client.WriteResp("ipaddr", "no free address")
 	}
 }
 
+// OnRoute learns routes advertised by link of other switch, and replies
+// routes attached to this one.
+func (r *WithRequest) OnRoute(client libol.SocketClient, data string) {
+	m, ok := client.Private().(*models.Point)
+	if !ok || !m.Link {
+		libol.Warn("WithRequest.OnRoute: %s not a link", client)
+		return
+	}
+	rcvNet := &models.Network{}
+	if err := json.Unmarshal([]byte(data), rcvNet); err != nil {
+		libol.Error("WithRequest.OnRoute: Invalid json data.")
+		return
+	}
+	r.master.Learn(m.Network, client.Addr(), rcvNet.Routes)
+	resp := &models.Network{
+		Name:   m.Network,
+		Routes: r.master.Advertise(m.Network),
+	}
+	if respStr, err := json.Marshal(resp); err == nil {
+		_ = client.WriteResp("route", string(respStr))
+	}
+}
+
 func (r *WithRequest) OnLeave(client libol.SocketClient, data string) {
 	libol.Info("WithRequest.OnLeave: %s", client.RemoteAddr())
 	r.master.OffClient(client)
@@ -6,6 +6,6 @@ type Switcher interface {
 	UUID() string
 	UpTime() int64
 	Alias() string
-	AddLink(tenant string, c *config.Point)
-	DelLink(tenant, addr string)
+	AddLink(tenant string, c *config.Point) error
+	DelLink(tenant, addr string) error
 }
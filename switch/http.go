@@ -2,6 +2,7 @@ package _switch
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/main/config"
@@ -28,6 +29,7 @@ type Http struct {
 	server     *http.Server
 	crtFile    string
 	keyFile    string
+	tlsCfg     *tls.Config
 	pubDir     string
 	router     *mux.Router
 }
@@ -40,6 +42,11 @@ func NewHttp(switcher api.Switcher, c config.Switch) (h *Http) {
 		crtFile:   c.Cert.CrtFile,
 		keyFile:   c.Cert.KeyFile,
 		pubDir:    c.Http.Public,
+		tlsCfg:    config.GetTlsCfg(c.Cert),
+	}
+	if h.tlsCfg != nil && h.tlsCfg.ClientAuth == tls.RequireAndVerifyClientCert {
+		// not required for administrators, and PointAuth checks it for points.
+		h.tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
 	}
 
 	return
@@ -53,6 +60,7 @@ func (h *Http) Initialize() {
 			Handler:      r,
 			ReadTimeout:  2 * time.Second,
 			WriteTimeout: 4 * time.Second,
+			TLSConfig:    h.tlsCfg,
 		}
 	}
 	if h.adminToken == "" {
@@ -130,15 +138,25 @@ func (h *Http) LoadRouter() {
 			api.ResponseJson(w, h.switcher.Config())
 		}
 	})
+	router.HandleFunc("/api/config/reload", func(w http.ResponseWriter, r *http.Request) {
+		api.ResponseJson(w, h.switcher.Reload())
+	}).Methods("POST")
 	api.Link{Switcher: h.switcher}.Router(router)
 	api.User{}.Router(router)
 	api.Neighbor{}.Router(router)
 	api.Point{}.Router(router)
-	api.Network{}.Router(router)
+	api.Network{Switcher: h.switcher}.Router(router)
 	api.OnLine{}.Router(router)
 	api.Ctrl{Switcher: h.switcher}.Router(router)
 	api.Lease{}.Router(router)
+	api.Lockout{}.Router(router)
 	api.Server{Switcher: h.switcher}.Router(router)
+	api.Metrics{Switcher: h.switcher}.Router(router)
+	api.Mesh{Switcher: h.switcher}.Router(router)
+	api.Route{Switcher: h.switcher}.Router(router)
+	if ws, ok := h.switcher.Server().(*libol.WsServer); ok {
+		router.Handle(ws.Path(), ws)
+	}
 }
 
 func (h *Http) LoadToken() error {
@@ -168,7 +186,7 @@ func (h *Http) Start() {
 			return
 		}
 	} else {
-		if err := h.server.ListenAndServeTLS(h.crtFile, h.keyFile); err != nil {
+		if err := h.server.ListenAndServeTLS("", ""); err != nil {
 			libol.Error("Http.Start on %s: %s", h.listen, err)
 			return
 		}
@@ -183,12 +201,22 @@ func (h *Http) Shutdown() {
 	}
 }
 
+func (h *Http) isWsPath(name string) bool {
+	if ws, ok := h.switcher.Server().(*libol.WsServer); ok {
+		return name == ws.Path()
+	}
+	return false
+}
+
 func (h *Http) IsAuth(w http.ResponseWriter, r *http.Request) bool {
 	token, pass, ok := r.BasicAuth()
 	libol.Debug("Http.IsAuth token: %s, pass: %s", token, pass)
 
 	if path.Ext(r.URL.Path) == ".ico" {
 		return true
+	} else if h.isWsPath(r.URL.Path) {
+		// point authenticate by login after upgraded.
+		return true
 	} else if len(r.URL.Path) > 4 {
 		if !ok || token != h.adminToken {
 			return false
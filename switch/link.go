@@ -0,0 +1,51 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/point"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"sync"
+)
+
+// link is a point connecting to other switch. It's started in background,
+// and may be stopped before started, so start and stop are serialized.
+type link struct {
+	lock    sync.Mutex
+	point   *point.Point
+	config  *config.Point // with credential resolved.
+	started bool
+	stopped bool
+}
+
+func newLink(p *point.Point, c *config.Point) *link {
+	return &link{point: p, config: c}
+}
+
+// Start initializes and starts the point if not stopped.
+func (l *link) Start() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopped {
+		return
+	}
+	l.point.Initialize()
+	storage.Link.Add(l.point)
+	l.point.Start()
+	l.started = true
+}
+
+// Stop stops the point only if started, and it never starts after.
+func (l *link) Stop() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopped {
+		return
+	}
+	l.stopped = true
+	if l.started {
+		l.point.Stop()
+		storage.Link.Del(l.point.Addr())
+	}
+}
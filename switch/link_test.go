@@ -0,0 +1,153 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSwitchLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "link")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+	creds := map[string]config.Credential{
+		"remote": {Username: "hi", Password: "12345"},
+	}
+	assert.Nil(t, libol.MarshalSave(creds, dir+"/credential.json", true), "save.")
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	assert.Equal(t, "hi", c.Credentials["remote"].Username, "be the same.")
+	s := NewSwitch(c)
+	s.Initialize()
+	n := &config.Network{
+		Name:   "la",
+		Bridge: config.Bridge{Name: "br-la", Provider: "virtual"},
+	}
+	assert.Nil(t, s.AddNetwork(n), "add network.")
+
+	link := &config.Point{
+		Connection: "127.0.0.1:1",
+		Protocol:   "tcp",
+		Credential: "remote",
+		Interface:  config.Interface{Provider: "tun"},
+	}
+	assert.NotNil(t, s.AddLink("lb", link), "network not found.")
+	assert.NotNil(t, s.AddLink("la", &config.Point{Connection: "127.0.0.1:2", Credential: "other"}), "credential not found.")
+	assert.Nil(t, s.AddLink("la", link), "add link.")
+	assert.NotNil(t, s.AddLink("la", &config.Point{Connection: "127.0.0.1:1"}), "existed.")
+
+	saved := &config.Network{}
+	assert.Nil(t, libol.UnmarshalLoad(saved, dir+"/network/la.json"), "load.")
+	assert.Equal(t, 1, len(saved.Links), "saved.")
+	assert.Equal(t, "remote", saved.Links[0].Credential, "be the same.")
+	assert.Equal(t, "", saved.Links[0].Password, "not saved.")
+
+	w := s.worker["la"]
+	pc := w.credential(link)
+	assert.Equal(t, "hi", pc.Username, "be the same.")
+	assert.Equal(t, "12345", pc.Password, "be the same.")
+	assert.Equal(t, "", link.Password, "config unchanged.")
+
+	assert.Nil(t, s.DelLink("", "127.0.0.1:1"), "delete link.")
+	assert.NotNil(t, s.DelLink("", "127.0.0.1:1"), "not found.")
+	assert.False(t, w.HasLink("127.0.0.1:1"), "removed.")
+	saved = &config.Network{}
+	assert.Nil(t, libol.UnmarshalLoad(saved, dir+"/network/la.json"), "load.")
+	assert.Equal(t, 0, len(saved.Links), "removed.")
+	assert.Nil(t, s.DelNetwork("la"), "delete network.")
+}
+
+func TestWorkerLinkDelete(t *testing.T) {
+	w := NewNetworkWorker(config.Network{
+		Name:   "lc",
+		Bridge: config.Bridge{Name: "br-lc", Provider: "virtual"},
+	}, nil)
+	for i := 0; i < 4; i++ {
+		link := &config.Point{
+			Connection: "127.0.0.1:1",
+			Protocol:   "tcp",
+			Interface:  config.Interface{Provider: "tun"},
+		}
+		link.Default()
+		w.AddLink(link)
+		// replaced or deleted before started.
+		if i%2 == 0 {
+			w.DelLink(link.Connection)
+		}
+	}
+	w.Stop()
+	w.linksLock.RLock()
+	assert.Equal(t, 0, len(w.links), "stopped.")
+	w.linksLock.RUnlock()
+}
+
+func TestSwitchReloadCredential(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credential")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+	_ = os.Mkdir(dir+"/network", 0755)
+	saveCreds := func(creds map[string]config.Credential) {
+		assert.Nil(t, libol.MarshalSave(creds, dir+"/credential.json", true), "save.")
+	}
+	saveNet := func(links ...*config.Point) {
+		n := &config.Network{
+			Name:   "lr",
+			Bridge: config.Bridge{Name: "br-lr", Provider: "virtual"},
+			Links:  links,
+		}
+		assert.Nil(t, libol.MarshalSave(n, dir+"/network/lr.json", true), "save.")
+	}
+	link := func(addr, cred string) *config.Point {
+		return &config.Point{
+			Connection: addr,
+			Protocol:   "tcp",
+			Credential: cred,
+			Interface:  config.Interface{Provider: "tun"},
+		}
+	}
+	saveCreds(map[string]config.Credential{"ra": {Username: "hi", Password: "12345"}})
+	saveNet(link("127.0.0.1:1", "ra"))
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	s := NewSwitch(c)
+	s.Initialize()
+	for _, n := range s.cfg.Network {
+		s.startNetwork(n)
+	}
+	defer s.Stop()
+	running := func(addr string) *config.Point {
+		w := s.worker["lr"]
+		w.linksLock.RLock()
+		defer w.linksLock.RUnlock()
+		if l, ok := w.links[addr]; ok {
+			return l.config
+		}
+		return nil
+	}
+	assert.Equal(t, "hi", running("127.0.0.1:1").Username, "be the same.")
+
+	// new credential with new link, and password changed only.
+	saveCreds(map[string]config.Credential{
+		"ra": {Username: "hi", Password: "54321"},
+		"rb": {Username: "hello", Password: "12345"},
+	})
+	saveNet(link("127.0.0.1:1", "ra"), link("127.0.0.1:2", "rb"))
+	ret := s.Reload()
+	assert.Equal(t, []string{"lr"}, ret.Updated, "be the same.")
+	assert.Equal(t, "54321", running("127.0.0.1:1").Password, "restarted.")
+	assert.Equal(t, "hello", running("127.0.0.1:2").Username, "be the same.")
+
+	// network unchanged.
+	saveCreds(map[string]config.Credential{
+		"ra": {Username: "hi", Password: "54321"},
+		"rb": {Username: "world", Password: "12345"},
+	})
+	ret = s.Reload()
+	assert.Equal(t, []string{"lr"}, ret.Unchanged, "be the same.")
+	assert.Equal(t, "world", running("127.0.0.1:2").Username, "restarted.")
+}
@@ -0,0 +1,111 @@
+package _switch
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/network"
+	"github.com/danieldin95/openlan-go/switch/api"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// frames reads the device into a channel until closed.
+func frames(dev network.Taper) chan []byte {
+	data := make(chan []byte, 16)
+	go func() {
+		for {
+			buf := make([]byte, 1600)
+			n, err := dev.Read(buf)
+			if err != nil {
+				return
+			}
+			data <- buf[:n]
+		}
+	}()
+	return data
+}
+
+func readFrame(data chan []byte) []byte {
+	select {
+	case frame := <-data:
+		return frame
+	case <-time.After(500 * time.Millisecond):
+		return nil
+	}
+}
+
+func TestSwitchMesh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mesh")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	s := NewSwitch(c)
+	s.Initialize()
+	n := &config.Network{
+		Name:   "ma",
+		Bridge: config.Bridge{Name: "br-ma", Provider: "virtual"},
+		Mesh:   true,
+	}
+	assert.Nil(t, s.AddNetwork(n), "add network.")
+	assert.Nil(t, s.AddNetwork(&config.Network{
+		Name:   "mb",
+		Bridge: config.Bridge{Name: "br-mb", Provider: "virtual"},
+	}), "add network.")
+	defer s.DelNetwork("ma")
+	defer s.DelNetwork("mb")
+
+	peer1, _ := s.NewTap("ma")
+	peer2, _ := s.NewTap("ma")
+	local, _ := s.NewTap("ma")
+	defer peer1.Close()
+	defer peer2.Close()
+	defer local.Close()
+	assert.Nil(t, s.SetPeer(peer1), "set peer.")
+	assert.Nil(t, s.SetPeer(peer2), "set peer.")
+	inPeer1, inPeer2, inLocal := frames(peer1), frames(peer2), frames(local)
+
+	frame := make([]byte, 64)
+	copy(frame[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x01})
+	_, _ = peer1.Write(frame)
+	assert.NotNil(t, readFrame(inLocal), "flood to local.")
+	assert.Nil(t, readFrame(inPeer2), "not flood to peer.")
+
+	copy(frame[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x02})
+	_, _ = local.Write(frame)
+	assert.NotNil(t, readFrame(inPeer1), "flood to peer.")
+	assert.NotNil(t, readFrame(inPeer2), "flood to peer.")
+
+	// unicast to address learned on peer1.
+	copy(frame[0:6], []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x01})
+	copy(frame[6:12], []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x03})
+	_, _ = peer2.Write(frame)
+	assert.Nil(t, readFrame(inPeer1), "not unicast to peer.")
+	_, _ = local.Write(frame)
+	assert.NotNil(t, readFrame(inPeer1), "unicast to peer.")
+
+	router := mux.NewRouter()
+	api.Mesh{Switcher: s}.Router(router)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/mesh", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "be the same.")
+	meshes := make([]schema.Mesh, 0)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &meshes), "decode.")
+	assert.Equal(t, 1, len(meshes), "be the same.")
+	assert.Equal(t, "ma", meshes[0].Network, "be the same.")
+	assert.Equal(t, "split-horizon", meshes[0].Mode, "be the same.")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/mesh/mb", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "not in mesh.")
+}
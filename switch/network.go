@@ -0,0 +1,178 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"os"
+	"regexp"
+)
+
+var networkName = regexp.MustCompile(`^[\w.-]+$`)
+
+func (v *Switch) findNetwork(name string) (int, *config.Network) {
+	for i, nCfg := range v.cfg.Network {
+		if nCfg.Name == name {
+			return i, nCfg
+		}
+	}
+	return -1, nil
+}
+
+func (v *Switch) GetNetwork(name string) *config.Network {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	_, nCfg := v.findNetwork(name)
+	return nCfg
+}
+
+func (v *Switch) rightNetwork(c *config.Network) error {
+	if !networkName.MatchString(c.Name) || c.Name == "." || c.Name == ".." {
+		return libol.NewErr("invalid network name '%s'", c.Name)
+	}
+	c.Alias = v.cfg.Alias
+	c.File = v.cfg.ConfDir + "/network/" + c.Name + ".json"
+	for _, link := range c.Links {
+		link.Default()
+	}
+	c.Right()
+	return c.Save()
+}
+
+// AddNetwork creates bridge and worker of a new network at runtime, and
+// saves it into directory of networks.
+func (v *Switch) AddNetwork(c *config.Network) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if _, old := v.findNetwork(c.Name); old != nil {
+		return libol.NewErr("network %s already existed", c.Name)
+	}
+	if err := v.rightNetwork(c); err != nil {
+		return err
+	}
+	libol.Info("Switch.AddNetwork: %s", c.Name)
+	v.applyNetwork(nil, c)
+	v.cfg.Network = append(v.cfg.Network, c)
+	v.refresh()
+	return nil
+}
+
+// UpdateNetwork applies the new configuration of network, and points on
+// it are kept if its bridge not changed.
+func (v *Switch) UpdateNetwork(c *config.Network) error {
+	defer v.offClients()
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	i, old := v.findNetwork(c.Name)
+	if old == nil {
+		return libol.NewErr("network %s not found", c.Name)
+	}
+	if old.File == "" {
+		return libol.NewErr("network %s is in switch.json", c.Name)
+	}
+	if err := v.rightNetwork(c); err != nil {
+		return err
+	}
+	libol.Info("Switch.UpdateNetwork: %s", c.Name)
+	v.applyNetwork(old, c)
+	v.cfg.Network[i] = c
+	v.refresh()
+	return nil
+}
+
+// DelNetwork kicks points on the network, tears down its bridge and
+// worker, and removes its file.
+func (v *Switch) DelNetwork(name string) error {
+	defer v.offClients()
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	i, old := v.findNetwork(name)
+	if old == nil {
+		return libol.NewErr("network %s not found", name)
+	}
+	if old.File == "" {
+		return libol.NewErr("network %s is in switch.json", name)
+	}
+	if err := os.Remove(old.File); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	libol.Info("Switch.DelNetwork: %s", name)
+	v.delNetwork(old)
+	v.cfg.Network = append(v.cfg.Network[:i], v.cfg.Network[i+1:]...)
+	v.refresh()
+	return nil
+}
+
+// AddLink connects the network to other switch at runtime, and saves
+// the link into file of network.
+func (v *Switch) AddLink(tenant string, c *config.Point) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	_, nCfg := v.findNetwork(tenant)
+	if nCfg == nil {
+		return libol.NewErr("network %s not found", tenant)
+	}
+	w, ok := v.worker[tenant]
+	if !ok {
+		return libol.NewErr("network %s not found", tenant)
+	}
+	if nCfg.File == "" {
+		return libol.NewErr("network %s is in switch.json", tenant)
+	}
+	c.Default()
+	if w.HasLink(c.Connection) {
+		return libol.NewErr("link %s already existed", c.Connection)
+	}
+	if c.Credential != "" {
+		if _, ok := v.cfg.Credentials[c.Credential]; !ok {
+			return libol.NewErr("credential %s not found", c.Credential)
+		}
+		c.Username, c.Password = "", ""
+	}
+	w.rightLink(c)
+	nCfg.Links = append(nCfg.Links, c)
+	if err := nCfg.Save(); err != nil {
+		nCfg.Links = nCfg.Links[:len(nCfg.Links)-1]
+		return err
+	}
+	libol.Info("Switch.AddLink: %s on %s", c.Connection, tenant)
+	w.NewLink(c)
+	return nil
+}
+
+// DelLink stops the link on the network, or on any network if tenant is
+// empty, and removes it from file of network.
+func (v *Switch) DelLink(tenant, addr string) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for _, nCfg := range v.cfg.Network {
+		if tenant != "" && nCfg.Name != tenant {
+			continue
+		}
+		w, ok := v.worker[nCfg.Name]
+		if !ok || !w.HasLink(addr) {
+			continue
+		}
+		if nCfg.File == "" {
+			return libol.NewErr("network %s is in switch.json", nCfg.Name)
+		}
+		links := make([]*config.Point, 0, len(nCfg.Links))
+		for _, lin := range nCfg.Links {
+			if lin.Connection != addr {
+				links = append(links, lin)
+			}
+		}
+		nCfg.Links = links
+		if err := nCfg.Save(); err != nil {
+			return err
+		}
+		libol.Info("Switch.DelLink: %s on %s", addr, nCfg.Name)
+		w.RemoveLink(addr)
+		return nil
+	}
+	return libol.NewErr("link %s not found", addr)
+}
@@ -0,0 +1,121 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSwitchNetwork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "network")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	s := NewSwitch(c)
+	s.Initialize()
+
+	n := &config.Network{
+		Name:   "na",
+		Bridge: config.Bridge{Name: "br-na", Provider: "virtual"},
+		Subnet: config.IpSubnet{
+			Start:   "192.168.30.100",
+			End:     "192.168.30.200",
+			Netmask: "255.255.255.0",
+		},
+		Routes:   []config.PrefixRoute{{Prefix: "10.0.0.0/8", NextHop: "192.168.30.1"}},
+		Password: []config.Password{{Username: "hi", Password: "12345"}},
+		Dns:      &config.Dns{Servers: []string{"192.168.30.1"}, Domains: []string{"corp.lan"}},
+	}
+	assert.Nil(t, s.AddNetwork(n), "add network.")
+	assert.NotNil(t, s.AddNetwork(&config.Network{Name: "na"}), "existed.")
+	assert.NotNil(t, s.AddNetwork(&config.Network{Name: "../na"}), "invalid name.")
+	file := dir + "/network/na.json"
+	assert.Nil(t, libol.FileExist(file), "saved.")
+	saved := &config.Network{}
+	assert.Nil(t, libol.UnmarshalLoad(saved, file), "load.")
+	assert.True(t, libol.IsHashed(saved.Password[0].Password), "hashed.")
+	assert.NotNil(t, storage.User.Get("hi@na"), "user added.")
+	assert.NotNil(t, storage.Network.Get("na"), "network added.")
+	assert.Equal(t, []string{"corp.lan"}, storage.Network.Get("na").Domains, "dns pushed.")
+	_, ok := s.bridge["na"]
+	assert.True(t, ok, "bridge added.")
+	m := libol.NewMetrics()
+	s.Metrics(m)
+	assert.Contains(t, m.String(), "openlan_switch_bridge_macs{network=\"na\",bridge=\"br-na\"} 0\n", "metrics.")
+
+	n = &config.Network{
+		Name:     "na",
+		Bridge:   config.Bridge{Name: "br-na", Provider: "virtual"},
+		Subnet:   n.Subnet,
+		Password: []config.Password{{Username: "hello", Password: "12345"}},
+	}
+	assert.Nil(t, s.UpdateNetwork(n), "update network.")
+	assert.Nil(t, storage.User.Get("hi@na"), "user removed.")
+	assert.NotNil(t, storage.User.Get("hello@na"), "user added.")
+	assert.Equal(t, 0, len(storage.Network.Get("na").Routes), "routes updated.")
+	assert.Nil(t, storage.Network.Get("na").Dns, "dns updated.")
+	assert.NotNil(t, s.UpdateNetwork(&config.Network{Name: "nb"}), "not found.")
+
+	assert.Nil(t, s.DelNetwork("na"), "delete network.")
+	assert.NotNil(t, s.DelNetwork("na"), "not found.")
+	assert.NotNil(t, libol.FileExist(file), "removed.")
+	assert.Nil(t, storage.User.Get("hello@na"), "user removed.")
+	assert.Nil(t, storage.Network.Get("na"), "network removed.")
+	_, ok = s.bridge["na"]
+	assert.False(t, ok, "bridge removed.")
+	assert.Nil(t, s.GetNetwork("na"), "config removed.")
+}
+
+// closeServer closes points at once as the loop of server does.
+type closeServer struct {
+	libol.SocketServer
+	s *Switch
+}
+
+func (c *closeServer) OffClient(client libol.SocketClient) {
+	_ = c.s.OnClose(client)
+}
+
+func TestSwitchNetworkKick(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kick")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	s := NewSwitch(c)
+	s.Initialize()
+	s.server = &closeServer{s: s}
+
+	n := &config.Network{
+		Name:   "nk",
+		Bridge: config.Bridge{Name: "br-nk", Provider: "virtual"},
+	}
+	assert.Nil(t, s.AddNetwork(n), "add network.")
+	for i, addr := range []string{"127.0.0.1:10001", "127.0.0.1:10002"} {
+		m := models.NewPoint(libol.NewTcpClient(addr, &libol.TcpConfig{}), nil)
+		m.UUID = addr
+		m.Network = "nk"
+		m.Link = i == 0
+		storage.Point.Add(m)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.DelNetwork("nk") }()
+	select {
+	case err := <-done:
+		assert.Nil(t, err, "delete network.")
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock with closing link.")
+	}
+	assert.Nil(t, storage.Point.Get("127.0.0.1:10001"), "kicked.")
+	assert.Nil(t, storage.Point.Get("127.0.0.1:10002"), "kicked.")
+}
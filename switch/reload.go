@@ -0,0 +1,229 @@
+package _switch
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/switch/app"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+)
+
+func isSame(a, b interface{}) bool {
+	da, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	db, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(da) == string(db)
+}
+
+func (v *Switch) startNetwork(nCfg *config.Network) {
+	name := nCfg.Name
+	if br, ok := v.bridge[name]; ok {
+		br.Open(nCfg.Bridge.Address)
+	}
+	if w, ok := v.worker[name]; ok {
+		w.Start(v)
+	}
+	if d, ok := v.dhcp[name]; ok {
+		v.startDhcp(d)
+	}
+}
+
+func (v *Switch) startDhcp(d *app.DhcpServer) {
+	// NewTap holds lock of switch.
+	libol.Go(func() {
+		if err := d.Start(); err != nil {
+			libol.Error("Switch.startDhcp: %s", err)
+		}
+	})
+}
+
+// delNetwork kicks points on the network, and frees its resources. The
+// points are kicked by offClients after the lock released.
+func (v *Switch) delNetwork(nCfg *config.Network) {
+	name := nCfg.Name
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		if p.Network != name {
+			continue
+		}
+		v.leftClient(p.Client)
+		v.offs = append(v.offs, p.Client)
+		if p.Device != nil {
+			_ = p.Device.Close()
+		}
+	}
+	if d, ok := v.dhcp[name]; ok {
+		d.Stop()
+		delete(v.dhcp, name)
+	}
+	if w, ok := v.worker[name]; ok {
+		w.Stop()
+		delete(v.worker, name)
+	}
+	if br, ok := v.bridge[name]; ok {
+		_ = br.Close()
+		delete(v.bridge, name)
+	}
+	for _, pass := range nCfg.Password {
+		storage.User.Del(pass.Username + "@" + name)
+	}
+	storage.Network.Del(name)
+}
+
+// offClients kicks points collected with lock held. It must be called
+// without the lock, as closing a link point learns its routes again.
+func (v *Switch) offClients() {
+	v.lock.Lock()
+	offs := v.offs
+	v.offs = nil
+	v.lock.Unlock()
+	for _, client := range offs {
+		v.OffClient(client)
+	}
+}
+
+// updateNetwork applies changes of network without its bridge changed,
+// and points on it are kept.
+func (v *Switch) updateNetwork(old, nCfg *config.Network) {
+	name := nCfg.Name
+	if w, ok := v.worker[name]; ok {
+		w.Reload(*nCfg)
+	}
+	if isSame(old.Dhcp, nCfg.Dhcp) && isSame(old.Bridge, nCfg.Bridge) {
+		return
+	}
+	if d, ok := v.dhcp[name]; ok {
+		d.Stop()
+		delete(v.dhcp, name)
+	}
+	if nCfg.Dhcp != nil {
+		d := app.NewDhcpServer(v, nCfg)
+		v.dhcp[name] = d
+		v.startDhcp(d)
+	}
+}
+
+// applyNetwork applies the configuration to the network, and old is nil
+// if it's a new one. Returns false if nothing changed.
+func (v *Switch) applyNetwork(old, nCfg *config.Network) bool {
+	name := nCfg.Name
+	rightRoutes(nCfg)
+	if old != nil {
+		if w, ok := v.worker[name]; ok {
+			for _, lin := range nCfg.Links {
+				w.rightLink(lin)
+			}
+		}
+		if isSame(old, nCfg) {
+			if w, ok := v.worker[name]; ok {
+				w.RenewLinks()
+			}
+			return false
+		}
+		if isSame(old.Bridge, nCfg.Bridge) && old.Mesh == nCfg.Mesh {
+			libol.Info("Switch.applyNetwork: update %s", name)
+			v.updateNetwork(old, nCfg)
+			return true
+		}
+		// points must join the new bridge again, and as peers if meshed.
+		v.delNetwork(old)
+	}
+	libol.Info("Switch.applyNetwork: add %s", name)
+	v.newNetwork(nCfg)
+	v.startNetwork(nCfg)
+	return true
+}
+
+// Reload reads configuration again, and applies the differences of
+// networks, users, routes and firewall. The networks not changed and
+// their points are left alone.
+func (v *Switch) Reload() schema.Reload {
+	defer v.offClients()
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	ret := schema.Reload{
+		Added:     make([]string, 0, 32),
+		Removed:   make([]string, 0, 32),
+		Updated:   make([]string, 0, 32),
+		Unchanged: make([]string, 0, 32),
+	}
+	c, err := v.cfg.Reload()
+	if err != nil {
+		libol.Error("Switch.Reload: %s", err)
+		ret.Errors = append(ret.Errors, err.Error())
+		return ret
+	}
+	libol.Info("Switch.Reload: %s", c.SaveFile)
+
+	olds := make(map[string]*config.Network, 32)
+	for _, nCfg := range v.cfg.Network {
+		olds[nCfg.Name] = nCfg
+	}
+	// links of networks resolve the credentials.
+	v.cfg.Credentials = c.Credentials
+	news := make(map[string]*config.Network, 32)
+	for _, nCfg := range c.Network {
+		news[nCfg.Name] = nCfg
+	}
+	for name, old := range olds {
+		if _, ok := news[name]; !ok {
+			libol.Info("Switch.Reload: remove %s", name)
+			v.delNetwork(old)
+			ret.Removed = append(ret.Removed, name)
+		}
+	}
+	for _, nCfg := range c.Network {
+		name := nCfg.Name
+		old, ok := olds[name]
+		if !v.applyNetwork(old, nCfg) {
+			ret.Unchanged = append(ret.Unchanged, name)
+		} else if ok {
+			ret.Updated = append(ret.Updated, name)
+		} else {
+			ret.Added = append(ret.Added, name)
+		}
+	}
+
+	// settings of server can't apply without restart.
+	if c.Protocol != v.cfg.Protocol || c.Listen != v.cfg.Listen {
+		ret.Restart = append(ret.Restart, "listen")
+	}
+	if !isSame(c.Http, v.cfg.Http) {
+		ret.Restart = append(ret.Restart, "http")
+	}
+	if !isSame(c.Cert, v.cfg.Cert) {
+		ret.Restart = append(ret.Restart, "cert")
+	}
+	if !isSame(c.Crypt, v.cfg.Crypt) {
+		ret.Restart = append(ret.Restart, "crypt")
+	}
+	if c.Inspect != v.cfg.Inspect {
+		ret.Restart = append(ret.Restart, "inspect")
+	}
+	v.cfg.Network = c.Network
+	v.cfg.FireWall = c.FireWall
+	v.cfg.Lockout = c.Lockout
+	v.refresh()
+	ret.Rules = len(v.firewall.rules)
+	return ret
+}
+
+// refresh applies authenticators and firewall rules of current
+// configuration.
+func (v *Switch) refresh() {
+	if v.apps.Auth != nil {
+		v.apps.Auth.Reload(v.cfg)
+	}
+	v.firewall.Stop()
+	v.loadRules()
+	v.firewall.Start()
+}
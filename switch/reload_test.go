@@ -0,0 +1,73 @@
+package _switch
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSwitchReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+	_ = os.Mkdir(dir+"/network", 0755)
+
+	network := func(name, bridge string, users ...string) *config.Network {
+		n := &config.Network{
+			Name:   name,
+			Bridge: config.Bridge{Name: bridge, Provider: "virtual"},
+			Subnet: config.IpSubnet{
+				Start:   "192.168.20.100",
+				End:     "192.168.20.200",
+				Netmask: "255.255.255.0",
+			},
+		}
+		for _, u := range users {
+			n.Password = append(n.Password, config.Password{Username: u, Password: "12345"})
+		}
+		return n
+	}
+	save := func(n *config.Network) {
+		assert.Nil(t, libol.MarshalSave(n, dir+"/network/"+n.Name+".json", true), "save.")
+	}
+	save(network("ra", "br-ra", "hi"))
+	save(network("rb", "br-rb", "hi"))
+	save(network("rc", "br-rc"))
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	s := NewSwitch(c)
+	s.Initialize()
+	for _, n := range s.cfg.Network {
+		s.startNetwork(n)
+	}
+	assert.NotNil(t, storage.User.Get("hi@ra"), "user of ra.")
+
+	_ = os.Remove(dir + "/network/rb.json")
+	save(network("ra", "br-ra", "hello"))
+	save(network("rd", "br-rd"))
+	ret := s.Reload()
+	assert.Empty(t, ret.Errors, "no errors.")
+	assert.Equal(t, []string{"rd"}, ret.Added, "be the same.")
+	assert.Equal(t, []string{"rb"}, ret.Removed, "be the same.")
+	assert.Equal(t, []string{"ra"}, ret.Updated, "be the same.")
+	assert.Equal(t, []string{"rc"}, ret.Unchanged, "be the same.")
+
+	assert.Nil(t, storage.User.Get("hi@ra"), "user removed.")
+	assert.NotNil(t, storage.User.Get("hello@ra"), "user added.")
+	assert.Nil(t, storage.User.Get("hi@rb"), "network removed.")
+	assert.Nil(t, storage.Network.Get("rb"), "network removed.")
+	assert.NotNil(t, storage.Network.Get("rd"), "network added.")
+	_, ok := s.bridge["rb"]
+	assert.False(t, ok, "bridge removed.")
+	_, ok = s.worker["rd"]
+	assert.True(t, ok, "worker added.")
+
+	ret = s.Reload()
+	assert.Equal(t, 3, len(ret.Unchanged), "be the same.")
+	s.Stop()
+}
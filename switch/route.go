@@ -0,0 +1,347 @@
+package _switch
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"net"
+	"strings"
+	"time"
+)
+
+// bridgeIp returns address of bridge without prefix length.
+func (w *NetworkWorker) bridgeIp() string {
+	return strings.SplitN(w.cfg.Bridge.Address, "/", 2)[0]
+}
+
+// attached returns prefixes of bridge address and subnet.
+func (w *NetworkWorker) attached() []string {
+	prefixes := make([]string, 0, 2)
+	if _, n, err := net.ParseCIDR(w.cfg.Bridge.Address); err == nil {
+		prefixes = append(prefixes, n.String())
+	}
+	start := net.ParseIP(w.cfg.Subnet.Start).To4()
+	mask := net.ParseIP(w.cfg.Subnet.Netmask).To4()
+	if start != nil && mask != nil {
+		n := net.IPNet{IP: start.Mask(net.IPMask(mask)), Mask: net.IPMask(mask)}
+		if len(prefixes) == 0 || prefixes[0] != n.String() {
+			prefixes = append(prefixes, n.String())
+		}
+	}
+	return prefixes
+}
+
+// Advertise returns routes attached to this switch, which are bridge
+// address, subnet and static routes. Routes learned are not advertised
+// again to avoid loop.
+func (w *NetworkWorker) Advertise() []*models.Route {
+	routes := make([]*models.Route, 0, 32)
+	if ifAddr := w.bridgeIp(); ifAddr != "" {
+		for _, prefix := range w.attached() {
+			routes = append(routes, models.NewRoute(prefix, ifAddr))
+		}
+	}
+	for _, rt := range w.cfg.Routes {
+		if rt.NextHop != "" {
+			routes = append(routes, models.NewRoute(rt.Prefix, rt.NextHop))
+		}
+	}
+	return routes
+}
+
+// Routes returns static routes and ones learned from other switches.
+func (w *NetworkWorker) Routes() []*models.Route {
+	routes := make([]*models.Route, 0, 32)
+	for _, rt := range w.cfg.Routes {
+		if rt.NextHop == "" {
+			continue
+		}
+		routes = append(routes, models.NewRoute(rt.Prefix, rt.NextHop))
+	}
+	for _, rt := range storage.Route.ListBy(w.cfg.Name) {
+		routes = append(routes, models.NewRoute(rt.Prefix, rt.NextHop))
+	}
+	return routes
+}
+
+// locals returns prefixes attached and of static routes.
+func (w *NetworkWorker) locals() []*net.IPNet {
+	prefixes := make([]*net.IPNet, 0, 8)
+	for _, p := range w.attached() {
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			prefixes = append(prefixes, n)
+		}
+	}
+	for _, rt := range w.cfg.Routes {
+		if _, n, err := net.ParseCIDR(rt.Prefix); err == nil {
+			prefixes = append(prefixes, n)
+		}
+	}
+	return prefixes
+}
+
+// covers returns true if the prefix contains the other.
+func covers(prefix, other *net.IPNet) bool {
+	ones, bits := prefix.Mask.Size()
+	oOnes, oBits := other.Mask.Size()
+	return bits == oBits && ones <= oOnes && prefix.Contains(other.IP)
+}
+
+// isHost returns true if addr is host or one of addresses resolved.
+func isHost(addr, host string) bool {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		addr = h
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil || host == "" {
+		return addr == host
+	}
+	if hIp := net.ParseIP(host); hIp != nil {
+		return hIp.Equal(ip)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		libol.Warn("isHost: %s", err)
+		return false
+	}
+	for _, hIp := range ips {
+		if hIp.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// peer returns switch configured which connects from addr by user, and
+// user is not checked if empty. Switches this one links to are peers too.
+func (w *NetworkWorker) peer(addr, user string) (*config.Peer, bool) {
+	for i := range w.cfg.Peers {
+		p := &w.cfg.Peers[i]
+		if p.Username != "" && user != "" && p.Username != user &&
+			p.Username+"@"+w.cfg.Name != user {
+			continue
+		}
+		if isHost(addr, p.Address) {
+			return p, true
+		}
+	}
+	for _, lin := range w.cfg.Links {
+		if isHost(addr, lin.Connection) {
+			return &config.Peer{Address: lin.Connection}, true
+		}
+	}
+	return nil, false
+}
+
+// Linked returns whether point from addr by user is link of switch, and
+// is peer in mesh.
+func (w *NetworkWorker) Linked(user, addr string) (bool, bool) {
+	if _, ok := w.peer(addr, user); !ok {
+		return false, false
+	}
+	return true, w.cfg.Mesh
+}
+
+// accept returns true if the prefix learned is neither default nor covering
+// local ones, and in prefixes allowed if any.
+func (w *NetworkWorker) accept(prefix *net.IPNet, allowed []string) bool {
+	if ones, _ := prefix.Mask.Size(); ones == 0 {
+		return false
+	}
+	for _, local := range w.locals() {
+		if covers(prefix, local) {
+			return false
+		}
+	}
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if _, n, err := net.ParseCIDR(a); err == nil && covers(n, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *NetworkWorker) isLocal(prefix string) bool {
+	for _, p := range w.attached() {
+		if p == prefix {
+			return true
+		}
+	}
+	for _, rt := range w.cfg.Routes {
+		if _, n, err := net.ParseCIDR(rt.Prefix); err == nil && n.String() == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// install adds route into kernel by the bridge, which has address.
+func (w *NetworkWorker) install(r *models.PeerRoute) {
+	if w.cfg.Bridge.Provider != "linux" || w.cfg.Bridge.Address == "" {
+		return
+	}
+	if out, err := libol.IpRouteAdd(w.cfg.Bridge.Name, r.Prefix, r.NextHop); err != nil {
+		libol.Warn("NetworkWorker.install: %s %s", err, out)
+	}
+}
+
+func (w *NetworkWorker) uninstall(r *models.PeerRoute) {
+	if w.cfg.Bridge.Provider != "linux" || w.cfg.Bridge.Address == "" {
+		return
+	}
+	if out, err := libol.IpRouteDel(w.cfg.Bridge.Name, r.Prefix, r.NextHop); err != nil {
+		libol.Warn("NetworkWorker.uninstall: %s %s", err, out)
+	}
+}
+
+// Learn replaces routes learned from the origin by ones advertised, and
+// routes empty withdraws them.
+func (w *NetworkWorker) Learn(origin string, routes []*models.Route) {
+	// peer may be resolved by dns, so out of lock.
+	var allowed []string
+	if len(routes) > 0 {
+		if p, ok := w.peer(origin, ""); ok {
+			allowed = p.Prefixes
+		}
+	}
+	w.routesLock.Lock()
+	defer w.routesLock.Unlock()
+
+	olds := make(map[string]*models.PeerRoute, 32)
+	for _, r := range storage.Route.Withdraw(w.cfg.Name, origin) {
+		olds[r.Prefix+" "+r.NextHop] = r
+	}
+	news := make(map[string]*models.PeerRoute, 32)
+	for _, rt := range routes {
+		_, prefix, err := net.ParseCIDR(rt.Prefix)
+		nexthop := net.ParseIP(rt.NextHop)
+		if err != nil || nexthop == nil {
+			libol.Warn("NetworkWorker.Learn: invalid %s from %s", rt, origin)
+			continue
+		}
+		// directly attached or static route wins.
+		if w.isLocal(prefix.String()) || nexthop.String() == w.bridgeIp() {
+			continue
+		}
+		if !w.accept(prefix, allowed) {
+			libol.Warn("NetworkWorker.Learn: %s from %s not accepted", rt, origin)
+			continue
+		}
+		r := &models.PeerRoute{
+			Network: w.cfg.Name,
+			Prefix:  prefix.String(),
+			NextHop: nexthop.String(),
+			Origin:  origin,
+			NewTime: time.Now().Unix(),
+		}
+		key := r.Prefix + " " + r.NextHop
+		if old, ok := olds[key]; ok {
+			r.NewTime = old.NewTime
+		}
+		news[key] = r
+		storage.Route.Add(r)
+	}
+	if len(olds) == 0 && len(news) == 0 {
+		return
+	}
+	for key, r := range olds {
+		if _, ok := news[key]; !ok {
+			libol.Info("NetworkWorker.Learn: withdraw %s from %s", r.Prefix, origin)
+			w.uninstall(r)
+		}
+	}
+	for key, r := range news {
+		if _, ok := olds[key]; !ok {
+			libol.Info("NetworkWorker.Learn: %s via %s from %s", r.Prefix, r.NextHop, origin)
+			w.install(r)
+		}
+	}
+	w.updateRoutes()
+}
+
+// withdrawAll removes routes learned from all origins.
+func (w *NetworkWorker) withdrawAll() {
+	origins := make(map[string]bool, 32)
+	for _, r := range storage.Route.ListBy(w.cfg.Name) {
+		origins[r.Origin] = true
+	}
+	for origin := range origins {
+		w.Learn(origin, nil)
+	}
+}
+
+// updateRoutes saves routes into network, and pushes them to points.
+func (w *NetworkWorker) updateRoutes() {
+	routes := w.Routes()
+	storage.Network.SetRoutes(w.cfg.Name, routes)
+	w.pushRoutes(routes, false)
+}
+
+// pushRoutes sends routes to points, or to links of other switches.
+func (w *NetworkWorker) pushRoutes(routes []*models.Route, link bool) {
+	n := models.Network{Name: w.cfg.Name, Routes: routes}
+	body, err := json.Marshal(n)
+	if err != nil {
+		libol.Error("NetworkWorker.pushRoutes: %s", err)
+		return
+	}
+	for p := range storage.Point.List() {
+		if p == nil {
+			break
+		}
+		if p.Network != w.cfg.Name || p.Link != link || p.Client == nil {
+			continue
+		}
+		if err := p.Client.WriteReq("route", string(body)); err != nil {
+			libol.Warn("NetworkWorker.pushRoutes: %s %s", p.Client, err)
+		}
+	}
+}
+
+// advertise sends routes attached to switches linked by or to this one.
+func (w *NetworkWorker) advertise() {
+	w.linksLock.RLock()
+	for _, l := range w.links {
+		l.point.Advertise()
+	}
+	w.linksLock.RUnlock()
+	w.pushRoutes(w.Advertise(), true)
+}
+
+// Learn saves routes of the network advertised by other switch.
+func (v *Switch) Learn(tenant, origin string, routes []*models.Route) {
+	v.lock.Lock()
+	w, ok := v.worker[tenant]
+	v.lock.Unlock()
+	if ok {
+		w.Learn(origin, routes)
+	}
+}
+
+func (v *Switch) Linked(tenant, user, addr string) (bool, bool) {
+	v.lock.Lock()
+	w, ok := v.worker[tenant]
+	v.lock.Unlock()
+	if ok {
+		return w.Linked(user, addr)
+	}
+	return false, false
+}
+
+func (v *Switch) Advertise(tenant string) []*models.Route {
+	v.lock.Lock()
+	w, ok := v.worker[tenant]
+	v.lock.Unlock()
+	if ok {
+		return w.Advertise()
+	}
+	return nil
+}
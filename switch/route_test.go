@@ -0,0 +1,132 @@
+package _switch
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/main/config"
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/danieldin95/openlan-go/switch/api"
+	"github.com/danieldin95/openlan-go/switch/schema"
+	"github.com/danieldin95/openlan-go/switch/storage"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSwitchRoute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "route")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	s := NewSwitch(c)
+	s.Initialize()
+	n := &config.Network{
+		Name:   "ra",
+		Bridge: config.Bridge{Name: "br-ra", Provider: "virtual", Address: "192.168.40.1/24"},
+		Subnet: config.IpSubnet{
+			Start:   "192.168.40.100",
+			End:     "192.168.40.200",
+			Netmask: "255.255.255.0",
+		},
+		Routes: []config.PrefixRoute{{Prefix: "10.10.0.0/16", NextHop: "192.168.40.2"}},
+	}
+	assert.Nil(t, s.AddNetwork(n), "add network.")
+	defer s.DelNetwork("ra")
+
+	adv := s.Advertise("ra")
+	assert.Equal(t, []*models.Route{
+		models.NewRoute("192.168.40.0/24", "192.168.40.1"),
+		models.NewRoute("10.10.0.0/16", "192.168.40.2"),
+	}, adv, "be the same.")
+	assert.Nil(t, s.Advertise("rb"), "not found.")
+
+	origin := "10.0.0.2:10002"
+	s.Learn("ra", origin, []*models.Route{
+		models.NewRoute("192.168.40.0/24", "192.168.40.3"),
+		models.NewRoute("10.10.0.0/16", "192.168.40.3"),
+		models.NewRoute("10.20.0.0/16", "192.168.40.3"),
+		models.NewRoute("10.30.0.0/16", "192.168.40.1"),
+		models.NewRoute("10.40.0.0/16", "invalid"),
+		models.NewRoute("0.0.0.0/0", "192.168.40.3"),
+		models.NewRoute("192.168.0.0/16", "192.168.40.3"),
+		models.NewRoute("10.0.0.0/8", "192.168.40.3"),
+	})
+	assert.Equal(t, 1, len(storage.Route.ListBy("ra")), "attached, invalid, default and covering ignored.")
+	assert.Equal(t, []*models.Route{
+		models.NewRoute("10.10.0.0/16", "192.168.40.2"),
+		models.NewRoute("10.20.0.0/16", "192.168.40.3"),
+	}, storage.Network.Get("ra").Routes, "be the same.")
+
+	router := mux.NewRouter()
+	api.Route{Switcher: s}.Router(router)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/route/ra", nil))
+	routes := make([]schema.Route, 0)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &routes), "decode.")
+	assert.Equal(t, 2, len(routes), "be the same.")
+	assert.Equal(t, "static", routes[0].Origin, "be the same.")
+	assert.Equal(t, origin, routes[1].Origin, "be the same.")
+	assert.Equal(t, "10.20.0.0/16", routes[1].Prefix, "be the same.")
+
+	// two next hops of the same prefix.
+	s.Learn("ra", origin, []*models.Route{
+		models.NewRoute("10.20.0.0/16", "192.168.40.3"),
+		models.NewRoute("10.20.0.0/16", "192.168.40.4"),
+	})
+	assert.Equal(t, 2, len(storage.Route.ListBy("ra")), "both saved.")
+	s.Learn("ra", origin, []*models.Route{
+		models.NewRoute("10.20.0.0/16", "192.168.40.4"),
+	})
+	learned := storage.Route.ListBy("ra")
+	assert.Equal(t, 1, len(learned), "one withdrawn.")
+	assert.Equal(t, "192.168.40.4", learned[0].NextHop, "be the same.")
+
+	s.Learn("ra", origin, nil)
+	assert.Equal(t, 0, len(storage.Route.ListBy("ra")), "withdrawn.")
+	assert.Equal(t, 1, len(storage.Network.Get("ra").Routes), "withdrawn.")
+}
+
+func TestSwitchLinked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "linked")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+
+	c := config.Switch{ConfDir: dir, Listen: "127.0.0.1:0"}
+	c.Default()
+	s := NewSwitch(c)
+	s.Initialize()
+	n := &config.Network{
+		Name:   "rc",
+		Bridge: config.Bridge{Name: "br-rc", Provider: "virtual", Address: "192.168.50.1/24"},
+		Mesh:   true,
+		Peers: []config.Peer{
+			{Address: "10.0.0.3", Username: "sw", Prefixes: []string{"10.50.0.0/16"}},
+		},
+	}
+	assert.Nil(t, s.AddNetwork(n), "add network.")
+	defer s.DelNetwork("rc")
+
+	link, peer := s.Linked("rc", "sw@rc", "10.0.0.3:10002")
+	assert.True(t, link, "configured.")
+	assert.True(t, peer, "in mesh.")
+	link, _ = s.Linked("rc", "other@rc", "10.0.0.3:10002")
+	assert.False(t, link, "other user.")
+	link, _ = s.Linked("rc", "sw@rc", "10.0.0.4:10002")
+	assert.False(t, link, "other address.")
+	link, _ = s.Linked("rd", "sw@rd", "10.0.0.3:10002")
+	assert.False(t, link, "not found.")
+
+	origin := "10.0.0.3:10002"
+	s.Learn("rc", origin, []*models.Route{
+		models.NewRoute("10.50.1.0/24", "192.168.50.3"),
+		models.NewRoute("10.60.0.0/16", "192.168.50.3"),
+		models.NewRoute("10.0.0.0/8", "192.168.50.3"),
+	})
+	assert.Equal(t, 1, len(storage.Route.ListBy("rc")), "in prefixes allowed.")
+	assert.Equal(t, "10.50.1.0/24", storage.Route.ListBy("rc")[0].Prefix, "be the same.")
+	s.Learn("rc", origin, nil)
+}
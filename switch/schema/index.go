@@ -12,7 +12,7 @@ type Index struct {
 
 type User struct {
 	Name     string `json:"name"`
-	Password string `json:"password"`
+	Password string `json:"password,omitempty"`
 	Token    string `json:"token"`
 	Alias    string `json:"alias"`
 }
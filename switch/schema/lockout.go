@@ -0,0 +1,9 @@
+package schema
+
+type Lockout struct {
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+	Failures int    `json:"failures"`
+	LastTime int64  `json:"lastTime"`
+	Remain   int64  `json:"remain"`
+}
@@ -0,0 +1,17 @@
+package schema
+
+type MeshPeer struct {
+	Alias     string `json:"alias"`
+	Address   string `json:"address"`
+	Device    string `json:"device"`
+	Direction string `json:"direction"` // out by link, in by point.
+	State     string `json:"state"`
+	Uptime    int64  `json:"uptime"`
+}
+
+type Mesh struct {
+	Network string     `json:"network"`
+	Switch  string     `json:"switch"`
+	Mode    string     `json:"mode"` // split-horizon.
+	Peers   []MeshPeer `json:"peers"`
+}
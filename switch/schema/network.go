@@ -1,9 +1,13 @@
 package schema
 
 type Lease struct {
-	Address string `json:"address"`
-	UUID    string `json:"uuid"`
-	Client  string `json:"client"`
+	Address  string `json:"address"`
+	Address6 string `json:"address6,omitempty"`
+	UUID     string `json:"uuid"`
+	Client   string `json:"client"`
+	Network  string `json:"network"`
+	Static   bool   `json:"static"`
+	Expire   int64  `json:"expire"` // unix time, and zero is bound.
 }
 
 type PrefixRoute struct {
@@ -16,5 +20,8 @@ type Network struct {
 	IpStart string        `json:"ipStart"`
 	IpEnd   string        `json:"ipEnd"`
 	Netmask string        `json:"netmask"`
+	Prefix6 string        `json:"prefix6,omitempty"`
 	Routes  []PrefixRoute `json:"routes"`
+	Dns     []string      `json:"dns,omitempty"`
+	Domains []string      `json:"domains,omitempty"`
 }
@@ -0,0 +1,11 @@
+package schema
+
+type Reload struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+	Rules     int      `json:"rules"`
+	Restart   []string `json:"restart,omitempty"` // settings need to restart.
+	Errors    []string `json:"errors,omitempty"`
+}
@@ -0,0 +1,9 @@
+package schema
+
+type Route struct {
+	Network string `json:"network"`
+	Prefix  string `json:"prefix"`
+	NextHop string `json:"nexthop"`
+	Origin  string `json:"origin"` // static, or connection of switch advertised.
+	Uptime  int64  `json:"uptime"`
+}
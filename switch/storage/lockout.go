@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
+	"sync"
+)
+
+// LockoutMax is sources tracked at most, and expired ones are evicted
+// firstly if full.
+const LockoutMax = 4096
+
+type lockout struct {
+	lock    sync.Mutex
+	Max     int
+	Sources map[string]*models.Lockout
+}
+
+var Lockout = lockout{
+	Max:     LockoutMax,
+	Sources: make(map[string]*models.Lockout, 1024),
+}
+
+// Locked returns secs remaining if the source is locked.
+func (l *lockout) Locked(t, source string) int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := t + ":" + source
+	if m, ok := l.Sources[key]; ok {
+		if m.Expired() {
+			delete(l.Sources, key)
+			return 0
+		}
+		return m.Remain()
+	}
+	return 0
+}
+
+// evict removes expired sources, and the least recent one not locked if
+// still full. It returns false if all are locked.
+func (l *lockout) evict() bool {
+	var oldest *models.Lockout
+	for key, m := range l.Sources {
+		if m.Expired() {
+			delete(l.Sources, key)
+		} else if m.Remain() == 0 && (oldest == nil || m.Last() < oldest.Last()) {
+			oldest = m
+		}
+	}
+	if len(l.Sources) < l.Max {
+		return true
+	}
+	if oldest == nil {
+		return false
+	}
+	delete(l.Sources, oldest.String())
+	return true
+}
+
+// Fail records a failure of source in window secs, and locks it for
+// duration secs if failures reach threshold. It returns failures.
+func (l *lockout) Fail(t, source string, window int64, threshold int, duration int64) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := t + ":" + source
+	m, ok := l.Sources[key]
+	if !ok {
+		m = models.NewLockout(t, source)
+		if len(l.Sources) >= l.Max && !l.evict() {
+			libol.Warn("lockout.Fail: %s not tracked, too many locked", key)
+			return 1
+		}
+		l.Sources[key] = m
+	}
+	count := m.Fail(window)
+	if count >= threshold && m.Remain() == 0 {
+		libol.Warn("lockout.Fail: %s locked %ds", key, duration)
+		m.Until = m.Failures[count-1] + duration
+	}
+	return count
+}
+
+func (l *lockout) Del(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.Sources, key)
+}
+
+func (l *lockout) Clear() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.Sources = make(map[string]*models.Lockout, 1024)
+}
+
+func (l *lockout) List() <-chan *models.Lockout {
+	c := make(chan *models.Lockout, 128)
+
+	go func() {
+		l.lock.Lock()
+		list := make([]*models.Lockout, 0, len(l.Sources))
+		for _, m := range l.Sources {
+			list = append(list, &models.Lockout{
+				Type:     m.Type,
+				Source:   m.Source,
+				Failures: append([]int64{}, m.Failures...),
+				Until:    m.Until,
+				Window:   m.Window,
+			})
+		}
+		l.lock.Unlock()
+		for _, m := range list {
+			c <- m
+		}
+		c <- nil //Finish channel by nil.
+	}()
+
+	return c
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLockoutEvict(t *testing.T) {
+	l := &lockout{Max: 3, Sources: make(map[string]*models.Lockout, 8)}
+	assert.Equal(t, 1, l.Fail("ip", "192.168.1.10", 60, 2, 60), "be the same.")
+	assert.Equal(t, 2, l.Fail("ip", "192.168.1.10", 60, 2, 60), "be the same.")
+	assert.True(t, l.Locked("ip", "192.168.1.10") > 0, "locked.")
+	l.Fail("ip", "192.168.1.11", 60, 2, 60)
+	l.Fail("ip", "192.168.1.12", 60, 2, 60)
+
+	// expired is removed when checked.
+	l.Sources["ip:192.168.1.12"].Failures[0] -= 60
+	assert.Equal(t, int64(0), l.Locked("ip", "192.168.1.12"), "not locked.")
+	assert.Equal(t, 2, len(l.Sources), "removed.")
+
+	// the least recent not locked is evicted if full.
+	l.Fail("ip", "192.168.1.13", 60, 2, 60)
+	l.Sources["ip:192.168.1.11"].Failures[0] -= 10
+	l.Fail("ip", "192.168.1.14", 60, 2, 60)
+	assert.Equal(t, 3, len(l.Sources), "be the same.")
+	_, ok := l.Sources["ip:192.168.1.11"]
+	assert.False(t, ok, "evicted.")
+	assert.True(t, l.Locked("ip", "192.168.1.10") > 0, "still locked.")
+
+	// not tracked if all locked.
+	now := time.Now().Unix()
+	for _, m := range l.Sources {
+		m.Until = now + 60
+	}
+	assert.Equal(t, 1, l.Fail("ip", "192.168.1.15", 60, 2, 60), "be the same.")
+	assert.Equal(t, 3, len(l.Sources), "be the same.")
+}
@@ -1,28 +1,35 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"encoding/binary"
 	"github.com/danieldin95/openlan-go/libol"
 	"github.com/danieldin95/openlan-go/models"
 	"github.com/danieldin95/openlan-go/switch/schema"
 	"net"
+	"sync"
+	"time"
 )
 
+const LeaseGrace = 5 * 60 // secs to hold address by default.
+
 type network struct {
 	Networks *libol.SafeStrMap
-	AddrUUID *libol.SafeStrStr // TODO with network
-	UUIDAddr *libol.SafeStrStr // TODO with network
+	lock     sync.Mutex
+	leases   map[string]*models.Lease // network and uuid to lease.
+	addrs    map[string]string        // network and address to uuid.
+	file     string
 }
 
 var Network = network{
 	Networks: libol.NewSafeStrMap(1024),
-	AddrUUID: libol.NewSafeStrStr(1024),
-	UUIDAddr: libol.NewSafeStrStr(1024),
+	leases:   make(map[string]*models.Lease, 1024),
+	addrs:    make(map[string]string, 1024),
 }
 
 func (w *network) Add(n *models.Network) {
 	libol.Debug("network.Add %v", *n)
-	_ = w.Networks.Set(n.Name, n)
+	_ = w.Networks.Mod(n.Name, n)
 }
 
 func (w *network) Del(name string) {
@@ -37,7 +44,15 @@ func (w *network) Get(name string) *models.Network {
 	return nil
 }
 
-//TODO add/del route
+// SetRoutes replaces routes of the network, and points will get them by
+// requesting address.
+func (w *network) SetRoutes(name string, routes []*models.Route) {
+	if n := w.Get(name); n != nil {
+		nn := *n
+		nn.Routes = routes
+		w.Add(&nn)
+	}
+}
 
 func (w *network) List() <-chan *models.Network {
 	c := make(chan *models.Network, 128)
@@ -52,65 +67,379 @@ func (w *network) List() <-chan *models.Network {
 	return c
 }
 
+func addrKey(network, addr string) string {
+	return network + "/" + addr
+}
+
+func leaseKey(network, uuid string) string {
+	return network + "/" + uuid
+}
+
+// lease returns lease of uuid in network.
+func (w *network) lease(network, uuid string) (*models.Lease, bool) {
+	l, ok := w.leases[leaseKey(network, uuid)]
+	return l, ok
+}
+
+// Load leases saved in file, and the file is updated by leases changed.
+func (w *network) Load(file string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.file = file
+	if err := libol.FileExist(file); err != nil {
+		return nil
+	}
+	leases := make([]*models.Lease, 0, 1024)
+	if err := libol.UnmarshalLoad(&leases, file); err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, l := range leases {
+		if !l.Static && l.Expire == 0 {
+			// wait points to reconnect.
+			l.Expire = now + LeaseGrace
+		}
+		if l.Expired() {
+			continue
+		}
+		w.setLease(l)
+	}
+	libol.Info("network.Load: %d leases from %s", len(w.leases), file)
+	return nil
+}
+
+func (w *network) save() {
+	if w.file == "" {
+		return
+	}
+	leases := make([]*models.Lease, 0, len(w.leases))
+	for _, l := range w.leases {
+		if !l.Expired() {
+			leases = append(leases, l)
+		}
+	}
+	if err := libol.MarshalSave(leases, w.file, true); err != nil {
+		libol.Error("network.save: %s", err)
+	}
+}
+
+func (w *network) setLease(l *models.Lease) {
+	w.delLease(l.Network, l.UUID)
+	for _, addr := range []string{l.Address, l.Address6} {
+		if addr == "" {
+			continue
+		}
+		key := addrKey(l.Network, addr)
+		if uuid, ok := w.addrs[key]; ok {
+			w.delLease(l.Network, uuid)
+		}
+		w.addrs[key] = l.UUID
+	}
+	w.leases[leaseKey(l.Network, l.UUID)] = l
+}
+
+func (w *network) delLease(network, uuid string) {
+	if l, ok := w.lease(network, uuid); ok {
+		delete(w.leases, leaseKey(network, uuid))
+		for _, addr := range []string{l.Address, l.Address6} {
+			key := addrKey(l.Network, addr)
+			if addr != "" && w.addrs[key] == uuid {
+				delete(w.addrs, key)
+			}
+		}
+	}
+}
+
+// sweep deletes leases expired, and returns true if any deleted.
+func (w *network) sweep() bool {
+	deleted := false
+	for _, l := range w.leases {
+		if l.Expired() {
+			w.delLease(l.Network, l.UUID)
+			deleted = true
+		}
+	}
+	return deleted
+}
+
+// owner returns uuid which holds the address and not expired.
+func (w *network) owner(network, addr string) (string, bool) {
+	uuid, ok := w.addrs[addrKey(network, addr)]
+	if !ok {
+		return "", false
+	}
+	if l, ok := w.lease(network, uuid); ok && !l.Expired() {
+		return uuid, true
+	}
+	return "", false
+}
+
+func (w *network) GetLease(network, uuid string) *models.Lease {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if l, ok := w.lease(network, uuid); ok {
+		lease := *l
+		return &lease
+	}
+	return nil
+}
+
 func (w *network) ListLease() <-chan *schema.Lease {
 	c := make(chan *schema.Lease, 128)
 
-	go func() {
-		w.UUIDAddr.Iter(func(k string, v string) {
-			c <- &schema.Lease{
-				UUID:    k,
-				Address: v,
-				Client:  Point.GetAddr(k),
-			}
+	w.lock.Lock()
+	leases := make([]schema.Lease, 0, len(w.leases))
+	for _, l := range w.leases {
+		if l.Expired() {
+			continue
+		}
+		leases = append(leases, schema.Lease{
+			UUID:     l.UUID,
+			Network:  l.Network,
+			Address:  l.Address,
+			Address6: l.Address6,
+			Static:   l.Static,
+			Expire:   l.Expire,
 		})
+	}
+	w.lock.Unlock()
+	go func() {
+		for i := range leases {
+			l := &leases[i]
+			l.Client = Point.GetAddr(l.UUID)
+			c <- l
+		}
 		c <- nil //Finish channel by nil.
 	}()
 	return c
 }
 
-func (w *network) AddUsedAddr(uuid, ipStr string) {
-	if ipStr != "" {
-		_ = w.AddrUUID.Set(ipStr, uuid)
-		_ = w.UUIDAddr.Set(uuid, ipStr)
+// AddUsedAddr binds address requested by point, and returns error if
+// it conflicts with others.
+func (w *network) AddUsedAddr(uuid, network, ipStr string) error {
+	if ipStr == "" {
+		return nil
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if owner, ok := w.owner(network, ipStr); ok && owner != uuid {
+		return libol.NewErr("%s conflict with %s", ipStr, owner)
+	}
+	if l, ok := w.lease(network, uuid); ok && l.Static && l.Address != ipStr {
+		return libol.NewErr("%s reserved with %s", uuid, l.Address)
+	}
+	lease := models.NewLease(uuid, network, "")
+	if l, ok := w.lease(network, uuid); ok {
+		lease = l
+	}
+	// keep reservation, ipv6 address and expiry of the lease.
+	nl := *lease
+	nl.Address = ipStr
+	w.setLease(&nl)
+	w.save()
+	return nil
+}
+
+// AddStatic reserves the address for uuid.
+func (w *network) AddStatic(uuid, network, ipStr string) error {
+	if net.ParseIP(ipStr) == nil {
+		return libol.NewErr("invalid address %s", ipStr)
 	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if owner, ok := w.owner(network, ipStr); ok && owner != uuid {
+		if l, _ := w.lease(network, owner); l.Static {
+			return libol.NewErr("%s reserved for %s", ipStr, owner)
+		}
+		libol.Warn("network.AddStatic: %s takes %s from %s", uuid, ipStr, owner)
+	}
+	l := models.NewLease(uuid, network, ipStr)
+	l.Static = true
+	w.setLease(l)
+	w.save()
+	return nil
 }
 
 func (w *network) GetFreeAddr(uuid string, n *models.Network) (ip string, mask string) {
 	if n == nil || uuid == "" {
 		return "", ""
 	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
 
-	ipStr := ""
 	netmask := n.Netmask
-	if addr, ok := w.UUIDAddr.GetEx(uuid); ok {
-		return addr, netmask
+	if n.IpStart == "" || n.IpEnd == "" {
+		return "", netmask
+	}
+	l, ok := w.lease(n.Name, uuid)
+	if ok && l.Address != "" {
+		if owner, ok := w.owner(n.Name, l.Address); !ok || owner == uuid {
+			if !l.Static && l.Expire != 0 {
+				l.Expire = 0
+				w.save()
+			}
+			w.addrs[addrKey(n.Name, l.Address)] = uuid
+			return l.Address, netmask
+		}
 	}
 	sIp := net.ParseIP(n.IpStart)
 	eIp := net.ParseIP(n.IpEnd)
 	if sIp == nil || eIp == nil {
-		return ipStr, netmask
+		return "", netmask
+	}
+	if w.sweep() {
+		w.save()
 	}
+	ipStr := ""
 	start := binary.BigEndian.Uint32(sIp.To4()[:4])
 	end := binary.BigEndian.Uint32(eIp.To4()[:4])
 	for i := start; i <= end; i++ {
 		tmp := make([]byte, 4)
 		binary.BigEndian.PutUint32(tmp[:4], i)
 		tmpStr := net.IP(tmp).String()
-		if _, ok := w.AddrUUID.GetEx(tmpStr); !ok {
+		if _, ok := w.owner(n.Name, tmpStr); !ok {
 			ipStr = tmpStr
 			break
 		}
 	}
 	if ipStr != "" {
-		_ = w.AddrUUID.Set(ipStr, uuid)
-		_ = w.UUIDAddr.Set(uuid, ipStr)
+		if !ok {
+			l = models.NewLease(uuid, n.Name, "")
+		}
+		lease := *l
+		lease.Address = ipStr
+		lease.Expire = 0
+		w.setLease(&lease)
+		w.save()
 	}
 	return ipStr, netmask
 }
 
-func (w *network) FreeAddr(uuid string) {
-	if addr, ok := w.UUIDAddr.GetEx(uuid); ok {
-		w.UUIDAddr.Del(uuid)
-		w.AddrUUID.Del(addr)
+// GetFreeAddr6 returns ipv6 address in prefix of network for uuid. The
+// address is hashed by uuid, so it is stable even if the lease expired.
+func (w *network) GetFreeAddr6(uuid string, n *models.Network) string {
+	if n == nil || uuid == "" || n.Prefix6 == "" {
+		return ""
+	}
+	_, prefix, err := net.ParseCIDR(n.Prefix6)
+	if err != nil || prefix.IP.To4() != nil {
+		libol.Warn("network.GetFreeAddr6: invalid %s", n.Prefix6)
+		return ""
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	l, ok := w.lease(n.Name, uuid)
+	if ok && l.Address6 != "" && prefix.Contains(net.ParseIP(l.Address6)) {
+		if owner, has := w.owner(n.Name, l.Address6); !has || owner == uuid {
+			if !l.Static && l.Expire != 0 {
+				l.Expire = 0
+				w.save()
+			}
+			w.addrs[addrKey(n.Name, l.Address6)] = uuid
+			return l.Address6
+		}
+	}
+	ipStr := ""
+	sum := sha256.Sum256([]byte(n.Name + "/" + uuid))
+	for i := 0; i < 16; i++ {
+		addr := make(net.IP, net.IPv6len)
+		for j := range addr {
+			addr[j] = prefix.IP[j] | sum[j]&^prefix.Mask[j]
+		}
+		// subnet-router anycast is not for hosts.
+		if _, has := w.owner(n.Name, addr.String()); !has && !addr.Equal(prefix.IP) {
+			ipStr = addr.String()
+			break
+		}
+		sum = sha256.Sum256(sum[:])
+	}
+	if ipStr == "" {
+		return ""
+	}
+	if !ok {
+		l = models.NewLease(uuid, n.Name, "")
+	}
+	lease := *l
+	lease.Address6 = ipStr
+	if !lease.Static {
+		lease.Expire = 0
+	}
+	w.setLease(&lease)
+	w.save()
+	return ipStr
+}
+
+// Renew sets the dynamic lease expired after secs.
+func (w *network) Renew(network, uuid string, secs int64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if l, ok := w.lease(network, uuid); ok && !l.Static {
+		l.Expire = time.Now().Unix() + secs
+		w.save()
+	}
+}
+
+// Hold holds the dynamic lease until released, as point is online.
+func (w *network) Hold(network, uuid string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if l, ok := w.lease(network, uuid); ok && !l.Static && l.Expire != 0 {
+		l.Expire = 0
+		w.save()
+	}
+}
+
+// Release holds addresses of uuid in all networks for grace period of
+// network, and then they can be allocated to others.
+func (w *network) Release(uuid string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, l := range w.leases {
+		if l.UUID != uuid || l.Static {
+			continue
+		}
+		grace := int64(LeaseGrace)
+		if n := w.Get(l.Network); n != nil && n.Grace != 0 {
+			grace = n.Grace
+		}
+		if grace < 0 {
+			w.delLease(l.Network, uuid)
+		} else {
+			l.Expire = time.Now().Unix() + grace
+		}
+	}
+	w.save()
+}
+
+// FreeAddr frees the dynamic lease at once.
+func (w *network) FreeAddr(network, uuid string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if l, ok := w.lease(network, uuid); ok && !l.Static {
+		w.delLease(network, uuid)
+		w.save()
+	}
+}
+
+// DelLease deletes the lease or reservation, and in all networks if
+// network is empty.
+func (w *network) DelLease(network, uuid string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, l := range w.leases {
+		if l.UUID == uuid && (network == "" || l.Network == network) {
+			w.delLease(l.Network, uuid)
+		}
 	}
+	w.save()
 }
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"github.com/danieldin95/openlan-go/models"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNetworkLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lease")
+	assert.Nil(t, err, "temp dir.")
+	defer os.RemoveAll(dir)
+	file := dir + "/lease.json"
+
+	n := &models.Network{
+		Name:    "lease",
+		IpStart: "192.168.20.1",
+		IpEnd:   "192.168.20.3",
+		Netmask: "255.255.255.0",
+		Grace:   60,
+	}
+	Network.Add(n)
+	defer Network.Del(n.Name)
+	assert.Nil(t, Network.Load(file), "load.")
+
+	assert.Nil(t, Network.AddStatic("static", n.Name, "192.168.20.1"), "reserved.")
+	ip, _ := Network.GetFreeAddr("uuid1", n)
+	assert.Equal(t, "192.168.20.2", ip, "skip reserved.")
+	ip, _ = Network.GetFreeAddr("static", n)
+	assert.Equal(t, "192.168.20.1", ip, "reserved address.")
+
+	// hold address in grace after released.
+	Network.Release("uuid1")
+	ip, _ = Network.GetFreeAddr("uuid2", n)
+	assert.Equal(t, "192.168.20.3", ip, "skip released.")
+	ip, _ = Network.GetFreeAddr("uuid1", n)
+	assert.Equal(t, "192.168.20.2", ip, "same address.")
+	assert.Equal(t, int64(0), Network.GetLease(n.Name, "uuid1").Expire, "bound.")
+
+	assert.NotNil(t, Network.AddUsedAddr("uuid3", n.Name, "192.168.20.2"), "conflict.")
+	assert.NotNil(t, Network.AddStatic("uuid3", n.Name, "192.168.20.1"), "conflict reserved.")
+
+	// expired address can be allocated to others.
+	Network.Renew(n.Name, "uuid2", -1)
+	ip, _ = Network.GetFreeAddr("uuid3", n)
+	assert.Equal(t, "192.168.20.3", ip, "reused expired.")
+
+	Network.lock.Lock()
+	_, ok := Network.lease(n.Name, "uuid2")
+	Network.lock.Unlock()
+	assert.False(t, ok, "expired deleted.")
+
+	// reload from file.
+	Network.lock.Lock()
+	Network.leases = make(map[string]*models.Lease, 1024)
+	Network.addrs = make(map[string]string, 1024)
+	Network.lock.Unlock()
+	assert.Nil(t, Network.Load(file), "reload.")
+	l := Network.GetLease(n.Name, "uuid1")
+	assert.NotNil(t, l, "loaded.")
+	assert.Equal(t, "192.168.20.2", l.Address, "loaded address.")
+	assert.True(t, l.Expire > time.Now().Unix(), "wait to reconnect.")
+	assert.True(t, Network.GetLease(n.Name, "static").Static, "loaded reservation.")
+
+	Network.DelLease("", "static")
+	assert.Nil(t, Network.GetLease(n.Name, "static"), "deleted.")
+	for _, uuid := range []string{"uuid1", "uuid3"} {
+		Network.FreeAddr(n.Name, uuid)
+	}
+	Network.lock.Lock()
+	Network.file = ""
+	Network.lock.Unlock()
+}
+
+func TestNetworkLeaseTwo(t *testing.T) {
+	n1 := &models.Network{
+		Name:    "lease1",
+		IpStart: "192.168.40.1",
+		IpEnd:   "192.168.40.3",
+		Netmask: "255.255.255.0",
+	}
+	n2 := &models.Network{
+		Name:    "lease2",
+		IpStart: "192.168.50.1",
+		IpEnd:   "192.168.50.3",
+		Netmask: "255.255.255.0",
+	}
+	Network.Add(n1)
+	defer Network.Del(n1.Name)
+	Network.Add(n2)
+	defer Network.Del(n2.Name)
+
+	ip1, _ := Network.GetFreeAddr("uuid1", n1)
+	ip2, _ := Network.GetFreeAddr("uuid1", n2)
+	assert.Equal(t, "192.168.40.1", ip1, "be the same.")
+	assert.Equal(t, "192.168.50.1", ip2, "be the same.")
+	assert.Equal(t, ip1, Network.GetLease(n1.Name, "uuid1").Address, "kept in first.")
+	assert.Equal(t, ip2, Network.GetLease(n2.Name, "uuid1").Address, "kept in second.")
+	ip1, _ = Network.GetFreeAddr("uuid1", n1)
+	assert.Equal(t, "192.168.40.1", ip1, "not flipped.")
+
+	Network.FreeAddr(n1.Name, "uuid1")
+	assert.Nil(t, Network.GetLease(n1.Name, "uuid1"), "freed.")
+	assert.NotNil(t, Network.GetLease(n2.Name, "uuid1"), "not freed.")
+	Network.DelLease("", "uuid1")
+	assert.Nil(t, Network.GetLease(n2.Name, "uuid1"), "deleted.")
+}
+
+func TestNetworkLease6(t *testing.T) {
+	n := &models.Network{
+		Name:    "lease6",
+		IpStart: "192.168.30.1",
+		IpEnd:   "192.168.30.3",
+		Netmask: "255.255.255.0",
+		Prefix6: "fd00:30::/64",
+		Grace:   60,
+	}
+	Network.Add(n)
+	defer Network.Del(n.Name)
+
+	ip, _ := Network.GetFreeAddr("uuid1", n)
+	assert.Equal(t, "192.168.30.1", ip, "be the same.")
+	ip6 := Network.GetFreeAddr6("uuid1", n)
+	_, prefix, _ := net.ParseCIDR(n.Prefix6)
+	assert.True(t, prefix.Contains(net.ParseIP(ip6)), "in prefix.")
+	assert.Equal(t, ip6, Network.GetLease(n.Name, "uuid1").Address6, "saved.")
+	assert.Equal(t, "192.168.30.1", Network.GetLease(n.Name, "uuid1").Address, "kept ipv4.")
+
+	// stable by uuid even if freed.
+	ip62 := Network.GetFreeAddr6("uuid2", n)
+	assert.NotEqual(t, ip6, ip62, "not conflict.")
+	Network.FreeAddr(n.Name, "uuid1")
+	assert.Equal(t, ip6, Network.GetFreeAddr6("uuid1", n), "stable.")
+	Network.Release("uuid2")
+	assert.Equal(t, ip62, Network.GetFreeAddr6("uuid2", n), "same address.")
+	assert.Equal(t, int64(0), Network.GetLease(n.Name, "uuid2").Expire, "bound.")
+	assert.Equal(t, "", Network.GetFreeAddr6("uuid1", &models.Network{Name: "none"}), "no prefix.")
+
+	// requested by reserved one.
+	assert.Nil(t, Network.AddStatic("static", n.Name, "192.168.30.10"), "reserved.")
+	static6 := Network.GetFreeAddr6("static", n)
+	assert.Nil(t, Network.AddUsedAddr("static", n.Name, "192.168.30.10"), "requested.")
+	Network.Renew(n.Name, "static", 60)
+	lease := Network.GetLease(n.Name, "static")
+	assert.True(t, lease.Static, "kept static.")
+	assert.Equal(t, static6, lease.Address6, "kept ipv6.")
+	assert.Equal(t, int64(0), lease.Expire, "never expired.")
+	Network.Release("static")
+	assert.Equal(t, "192.168.30.10", Network.GetLease(n.Name, "static").Address, "kept after release.")
+	Network.DelLease(n.Name, "static")
+
+	for _, uuid := range []string{"uuid1", "uuid2"} {
+		Network.FreeAddr(n.Name, uuid)
+	}
+}
+
+func TestNetworkLease6Only(t *testing.T) {
+	n := &models.Network{
+		Name:    "lease6only",
+		Prefix6: "fd00:60::/64",
+	}
+	Network.Add(n)
+	defer Network.Del(n.Name)
+
+	ip6 := Network.GetFreeAddr6("uuid1", n)
+	assert.NotEqual(t, "", ip6, "allocated.")
+	ip, _ := Network.GetFreeAddr("uuid1", n)
+	assert.Equal(t, "", ip, "no range.")
+	Network.lock.Lock()
+	_, ok := Network.addrs[addrKey(n.Name, "")]
+	Network.lock.Unlock()
+	assert.False(t, ok, "no empty address.")
+	assert.Equal(t, ip6, Network.GetLease(n.Name, "uuid1").Address6, "kept ipv6.")
+	Network.FreeAddr(n.Name, "uuid1")
+}
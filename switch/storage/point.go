@@ -59,11 +59,20 @@ func (p *point) GetAddr(uuid string) string {
 func (p *point) Del(addr string) {
 	if v := p.Clients.Get(addr); v != nil {
 		m := v.(*models.Point)
-		if m.Device != nil {
+		paths := make([]libol.SocketClient, 0, 4)
+		if m.Bond != nil {
+			m.Bond.Remove(m.Client)
+			paths = m.Bond.Paths()
+		}
+		if m.Device != nil && len(paths) == 0 { // device shared by paths.
 			_ = m.Device.Close()
 		}
 		if p.UUIDAddr.Get(m.UUID) == addr { // not has newer
-			p.UUIDAddr.Del(m.UUID)
+			if len(paths) > 0 {
+				_ = p.UUIDAddr.Reset(m.UUID, paths[0].Addr())
+			} else {
+				p.UUIDAddr.Del(m.UUID)
+			}
 		}
 		p.AddrUUID.Del(m.Client.Addr())
 		p.Clients.Del(addr)
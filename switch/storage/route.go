@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"github.com/danieldin95/openlan-go/libol"
+	"github.com/danieldin95/openlan-go/models"
+)
+
+type route struct {
+	Routes *libol.SafeStrMap
+}
+
+var Route = route{
+	Routes: libol.NewSafeStrMap(1024),
+}
+
+func routeKey(network, origin, prefix, nexthop string) string {
+	return network + "/" + origin + "/" + prefix + "/" + nexthop
+}
+
+func (p *route) Add(r *models.PeerRoute) {
+	_ = p.Routes.Mod(routeKey(r.Network, r.Origin, r.Prefix, r.NextHop), r)
+}
+
+// Withdraw deletes routes of the network learned from the origin, and
+// returns them.
+func (p *route) Withdraw(network, origin string) []*models.PeerRoute {
+	olds := make([]*models.PeerRoute, 0, 32)
+	p.Routes.Iter(func(k string, v interface{}) {
+		if r := v.(*models.PeerRoute); r.Network == network && r.Origin == origin {
+			olds = append(olds, r)
+		}
+	})
+	for _, r := range olds {
+		p.Routes.Del(routeKey(r.Network, r.Origin, r.Prefix, r.NextHop))
+	}
+	return olds
+}
+
+func (p *route) List() <-chan *models.PeerRoute {
+	c := make(chan *models.PeerRoute, 128)
+
+	go func() {
+		p.Routes.Iter(func(k string, v interface{}) {
+			c <- v.(*models.PeerRoute)
+		})
+		c <- nil //Finish channel by nil.
+	}()
+
+	return c
+}
+
+// ListBy returns routes learned for the network.
+func (p *route) ListBy(network string) []*models.PeerRoute {
+	routes := make([]*models.PeerRoute, 0, 32)
+	p.Routes.Iter(func(k string, v interface{}) {
+		if r := v.(*models.PeerRoute); r.Network == network {
+			routes = append(routes, r)
+		}
+	})
+	return routes
+}
@@ -18,7 +18,7 @@ func (w *user) Init(size int) {
 }
 
 func (w *user) Add(user *models.User) {
-	libol.Debug("user.Add %v", *user)
+	libol.Debug("user.Add %s", user)
 	name := user.Name
 	if name == "" {
 		name = user.Token
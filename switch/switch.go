@@ -19,24 +19,47 @@ func GetSocketServer(c config.Switch) libol.SocketServer {
 	case "kcp":
 		kcpCfg := &libol.KcpConfig{
 			Block:   config.GetBlock(c.Crypt),
+			Aead:    config.GetAead(c.Crypt, false),
 			Timeout: time.Duration(c.Timeout) * time.Second,
 		}
 		return libol.NewKcpServer(c.Listen, kcpCfg)
 	case "tcp":
 		tcpCfg := &libol.TcpConfig{
 			Block: config.GetBlock(c.Crypt),
+			Aead:  config.GetAead(c.Crypt, false),
 		}
 		return libol.NewTcpServer(c.Listen, tcpCfg)
 	case "udp":
 		udpCfg := &libol.UdpConfig{
 			Block:   config.GetBlock(c.Crypt),
+			Aead:    config.GetAead(c.Crypt, false),
 			Timeout: time.Duration(c.Timeout) * time.Second,
 		}
 		return libol.NewUdpServer(c.Listen, udpCfg)
+	case "ws", "wss":
+		// http server is tls if it has certificate.
+		secured := c.Protocol == "wss" || c.Http != nil
+		secured = secured && config.GetTlsCfg(c.Cert) != nil
+		wsCfg := &libol.WsConfig{
+			Block:   config.GetBlock(c.Crypt),
+			Aead:    config.GetAead(c.Crypt, secured),
+			Timeout: time.Duration(c.Timeout) * time.Second,
+		}
+		if c.Http != nil {
+			// share port with http server, and wss if it has certificate.
+			wsCfg.Shared = true
+			return libol.NewWsServer(c.Http.Listen, wsCfg)
+		}
+		if c.Protocol == "wss" {
+			wsCfg.Tls = config.GetTlsCfg(c.Cert)
+		}
+		return libol.NewWsServer(c.Listen, wsCfg)
 	default:
+		tlsCfg := config.GetTlsCfg(c.Cert)
 		tcpCfg := &libol.TcpConfig{
-			Tls:   config.GetTlsCfg(c.Cert),
+			Tls:   tlsCfg,
 			Block: config.GetBlock(c.Crypt),
+			Aead:  config.GetAead(c.Crypt, tlsCfg != nil),
 		}
 		return libol.NewTcpServer(c.Listen, tcpCfg)
 	}
@@ -62,6 +85,8 @@ type Switch struct {
 	server   libol.SocketServer
 	bridge   map[string]network.Bridger
 	worker   map[string]*NetworkWorker
+	dhcp     map[string]*app.DhcpServer
+	offs     []libol.SocketClient // kicked after lock released.
 	uuid     string
 	newTime  int64
 }
@@ -75,6 +100,7 @@ func NewSwitch(c config.Switch) *Switch {
 		},
 		worker:  make(map[string]*NetworkWorker, 32),
 		bridge:  make(map[string]network.Bridger, 32),
+		dhcp:    make(map[string]*app.DhcpServer, 32),
 		server:  server,
 		newTime: time.Now().Unix(),
 	}
@@ -115,6 +141,63 @@ func (v *Switch) addRules(source string, prefix string) {
 	})
 }
 
+func rightRoutes(nCfg *config.Network) {
+	if nCfg.Bridge.Address == "" {
+		return
+	}
+	ifAddr := strings.SplitN(nCfg.Bridge.Address, "/", 2)[0]
+	for i, rt := range nCfg.Routes {
+		if rt.NextHop == "" {
+			nCfg.Routes[i].NextHop = ifAddr
+		}
+	}
+}
+
+func (v *Switch) newNetwork(nCfg *config.Network) {
+	name := nCfg.Name
+	brCfg := nCfg.Bridge
+
+	rightRoutes(nCfg)
+	v.worker[name] = NewNetworkWorker(*nCfg, v.cfg.Crypt)
+	v.bridge[name] = network.NewBridger(brCfg.Provider, brCfg.Name, brCfg.IfMtu)
+	v.bridge[name].SetStp(!nCfg.Mesh)
+	if nCfg.Dhcp != nil {
+		v.dhcp[name] = app.NewDhcpServer(v, nCfg)
+	}
+}
+
+func (v *Switch) loadRules() {
+	v.firewall.rules = make([]libol.FilterRule, 0, 32)
+	for _, nCfg := range v.cfg.Network {
+		if nCfg.Bridge.Address == "" {
+			continue
+		}
+		source := nCfg.Bridge.Address
+		ifAddr := strings.SplitN(source, "/", 2)[0]
+		for _, rt := range nCfg.Routes {
+			if rt.NextHop != ifAddr {
+				continue
+			}
+			// MASQUERADE
+			v.addRules(source, rt.Prefix)
+		}
+	}
+	for _, rule := range v.cfg.FireWall {
+		v.firewall.rules = append(v.firewall.rules, libol.FilterRule{
+			Table:    rule.Table,
+			Chain:    rule.Chain,
+			Source:   rule.Source,
+			Dest:     rule.Dest,
+			Jump:     rule.Jump,
+			ToSource: rule.ToSource,
+			ToDest:   rule.ToDest,
+			Comment:  rule.Comment,
+			Input:    rule.Input,
+			Output:   rule.Output,
+		})
+	}
+}
+
 func (v *Switch) Initialize() {
 	v.lock.Lock()
 	defer v.lock.Unlock()
@@ -122,28 +205,11 @@ func (v *Switch) Initialize() {
 	if v.cfg.Http != nil {
 		v.http = NewHttp(v, v.cfg)
 	}
-	crypt := v.cfg.Crypt
+	if err := storage.Network.Load(v.cfg.ConfDir + "/lease.json"); err != nil {
+		libol.Error("Switch.Initialize: %s", err)
+	}
 	for _, nCfg := range v.cfg.Network {
-		name := nCfg.Name
-		brCfg := nCfg.Bridge
-
-		if brCfg.Address != "" {
-			source := brCfg.Address
-			ifAddr := strings.SplitN(source, "/", 2)[0]
-			for i, rt := range nCfg.Routes {
-				if rt.NextHop == "" {
-					nCfg.Routes[i].NextHop = ifAddr
-				}
-				rt = nCfg.Routes[i]
-				if rt.NextHop != ifAddr {
-					continue
-				}
-				// MASQUERADE
-				v.addRules(source, rt.Prefix)
-			}
-		}
-		v.worker[name] = NewNetworkWorker(*nCfg, crypt)
-		v.bridge[name] = network.NewBridger(brCfg.Provider, brCfg.Name, brCfg.IfMtu)
+		v.newNetwork(nCfg)
 	}
 
 	v.hooks = make([]Hook, 0, 64)
@@ -171,20 +237,7 @@ func (v *Switch) Initialize() {
 	ctrls.Ctrl.Switcher = v
 
 	// FireWall
-	for _, rule := range v.cfg.FireWall {
-		v.firewall.rules = append(v.firewall.rules, libol.FilterRule{
-			Table:    rule.Table,
-			Chain:    rule.Chain,
-			Source:   rule.Source,
-			Dest:     rule.Dest,
-			Jump:     rule.Jump,
-			ToSource: rule.ToSource,
-			ToDest:   rule.ToDest,
-			Comment:  rule.Comment,
-			Input:    rule.Input,
-			Output:   rule.Output,
-		})
-	}
+	v.loadRules()
 	libol.Info("Switch.Initialize total %d rules", len(v.firewall.rules))
 }
 
@@ -252,6 +305,9 @@ func (v *Switch) ReadClient(client libol.SocketClient, frame *libol.FrameMessage
 		if point == nil || device == nil {
 			return libol.NewErr("Tap devices is nil")
 		}
+		if point.Bond != nil && !point.Bond.Accept(frame) {
+			return nil
+		}
 		if _, err := device.Write(frame.Frame()); err != nil {
 			libol.Error("Switch.ReadClient: %s", err)
 			return err
@@ -266,8 +322,16 @@ func (v *Switch) OnClose(client libol.SocketClient) error {
 
 	// TODO support free list for device.
 	uuid := storage.Point.GetUUID(client.Addr())
-	if storage.Point.GetAddr(uuid) == client.Addr() { // not has newer
-		storage.Network.FreeAddr(uuid)
+	m := storage.Point.Get(client.Addr())
+	remain := 0
+	if m != nil && m.Bond != nil { // other paths of bond.
+		remain = m.Bond.Remove(client)
+	}
+	if remain == 0 && storage.Point.GetAddr(uuid) == client.Addr() { // not has newer
+		storage.Network.Release(uuid)
+	}
+	if m != nil && m.Link {
+		v.Learn(m.Network, client.Addr(), nil)
 	}
 	storage.Point.Del(client.Addr())
 
@@ -295,6 +359,14 @@ func (v *Switch) Start() {
 	for _, w := range v.worker {
 		w.Start(v)
 	}
+	for _, d := range v.dhcp {
+		dhcp := d
+		libol.Go(func() {
+			if err := dhcp.Start(); err != nil {
+				libol.Error("Switch.Start: %s", err)
+			}
+		})
+	}
 	if v.http != nil {
 		libol.Go(v.http.Start)
 	}
@@ -325,6 +397,9 @@ func (v *Switch) Stop() {
 	for _, w := range v.worker {
 		w.Stop()
 	}
+	for _, d := range v.dhcp {
+		d.Stop()
+	}
 	for _, nCfg := range v.cfg.Network {
 		if br, ok := v.bridge[nCfg.Name]; ok {
 			brCfg := nCfg.Bridge
@@ -370,6 +445,25 @@ func (v *Switch) NewTap(tenant string) (network.Taper, error) {
 	return dev, nil
 }
 
+// SetPeer isolates the device of other switch from other peers, if its
+// network is in mesh.
+func (v *Switch) SetPeer(dev network.Taper) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	tenant := dev.Tenant()
+	br, ok := v.bridge[tenant]
+	if !ok {
+		return libol.NewErr("Not found bridge %s", tenant)
+	}
+	for _, nCfg := range v.cfg.Network {
+		if nCfg.Name == tenant && nCfg.Mesh {
+			return br.SetPeer(dev.Name())
+		}
+	}
+	return nil
+}
+
 func (v *Switch) FreeTap(dev network.Taper) error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
@@ -391,14 +485,6 @@ func (v *Switch) UUID() string {
 	return v.uuid
 }
 
-func (v *Switch) AddLink(tenant string, c *config.Point) {
-	//TODO
-}
-
-func (v *Switch) DelLink(tenant, addr string) {
-	//TODO
-}
-
 func (v *Switch) ReadTap(device network.Taper, readAt func(f *libol.FrameMessage) error) {
 	defer device.Close()
 	libol.Info("Switch.ReadTap: %s", device.Name())
@@ -428,6 +514,23 @@ func (v *Switch) OffClient(client libol.SocketClient) {
 	}
 }
 
+func (v *Switch) Metrics(m *libol.Metrics) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if v.apps.Auth != nil {
+		success, failed := v.apps.Auth.Stats()
+		m.Counter("openlan_switch_auth_success_total", "Points authenticated.", uint64(success))
+		m.Counter("openlan_switch_auth_failed_total", "Points failed to authenticate.", uint64(failed))
+	}
+	for name, br := range v.bridge {
+		m.Gauge("openlan_switch_bridge_macs", "Mac addresses learned by bridge.", float64(br.MacSize()),
+			"network", name, "bridge", br.Name())
+	}
+	for _, w := range v.worker {
+		w.Metrics(m)
+	}
+}
+
 func (v *Switch) Config() *config.Switch {
 	return &v.cfg
 }
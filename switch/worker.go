@@ -18,10 +18,12 @@ type NetworkWorker struct {
 	newTime     int64
 	startTime   int64
 	linksLock   sync.RWMutex
-	links       map[string]*point.Point
+	routesLock  sync.Mutex
+	links       map[string]*link
 	uuid        string
 	initialized bool
 	crypt       *config.Crypt
+	switcher    api.Switcher
 }
 
 func NewNetworkWorker(c config.Network, crypt *config.Crypt) *NetworkWorker {
@@ -30,7 +32,7 @@ func NewNetworkWorker(c config.Network, crypt *config.Crypt) *NetworkWorker {
 		cfg:         c,
 		newTime:     time.Now().Unix(),
 		startTime:   0,
-		links:       make(map[string]*point.Point),
+		links:       make(map[string]*link),
 		initialized: false,
 		crypt:       crypt,
 	}
@@ -46,27 +48,88 @@ func (w *NetworkWorker) Initialize() {
 			Name:     pass.Username + "@" + w.cfg.Name,
 			Password: pass.Password,
 		}
+		if !libol.IsHashed(user.Password) {
+			user.Password = libol.HashPassword(user.Password)
+		}
 		storage.User.Add(&user)
 	}
-	if w.cfg.Subnet.Netmask != "" {
+	if w.cfg.Subnet.Netmask != "" || w.cfg.Subnet.Prefix6 != "" {
 		met := models.Network{
 			Name:    w.cfg.Name,
 			IpStart: w.cfg.Subnet.Start,
 			IpEnd:   w.cfg.Subnet.End,
 			Netmask: w.cfg.Subnet.Netmask,
-			Routes:  make([]*models.Route, 0, 2),
+			Prefix6: w.cfg.Subnet.Prefix6,
+			Routes:  w.Routes(),
+			Grace:   int64(w.cfg.Subnet.Grace),
+		}
+		if w.cfg.Dns != nil {
+			met.Dns = w.cfg.Dns.Servers
+			met.Domains = w.cfg.Dns.Domains
 		}
 		for _, rt := range w.cfg.Routes {
 			if rt.NextHop == "" {
 				libol.Warn("NetworkWorker.Initialize %s no nexthop", rt.Prefix)
-				continue
 			}
-			met.Routes = append(met.Routes, &models.Route{
-				Prefix:  rt.Prefix,
-				NextHop: rt.NextHop,
-			})
 		}
 		storage.Network.Add(&met)
+		for _, st := range w.cfg.Subnet.Static {
+			if err := storage.Network.AddStatic(st.UUID, w.cfg.Name, st.Address); err != nil {
+				libol.Warn("NetworkWorker.Initialize %s", err)
+			}
+		}
+	}
+}
+
+// Reload applies new configuration of the same network, and only
+// restarts links changed.
+func (w *NetworkWorker) Reload(c config.Network) {
+	libol.Info("NetworkWorker.Reload: %s", c.Name)
+	for _, pass := range w.cfg.Password {
+		storage.User.Del(pass.Username + "@" + w.cfg.Name)
+	}
+	oldRoutes := w.Routes()
+	oldLinks := make(map[string]*config.Point, 32)
+	for _, lin := range w.cfg.Links {
+		oldLinks[lin.Connection] = lin
+	}
+	w.cfg = c
+	w.alias = c.Alias
+	w.Initialize()
+	if !isSame(oldRoutes, w.Routes()) {
+		w.pushRoutes(w.Routes(), false)
+	}
+
+	newLinks := make(map[string]*config.Point, 32)
+	for _, lin := range c.Links {
+		lin.Default()
+		w.rightLink(lin)
+		newLinks[lin.Connection] = lin
+	}
+	changed := make(map[string]bool, 32)
+	for addr, lin := range newLinks {
+		old, ok := oldLinks[addr]
+		changed[addr] = !ok || !isSame(old, lin) || w.credChanged(lin)
+	}
+	for addr := range oldLinks {
+		if _, ok := newLinks[addr]; !ok || changed[addr] {
+			w.DelLink(addr)
+		}
+	}
+	for addr, lin := range newLinks {
+		if changed[addr] {
+			w.AddLink(lin)
+		}
+	}
+	w.advertise()
+}
+
+func (w *NetworkWorker) Metrics(m *libol.Metrics) {
+	w.linksLock.RLock()
+	defer w.linksLock.RUnlock()
+	for addr, l := range w.links {
+		m.Counter("openlan_switch_link_reconnects_total", "Reconnects of link.", l.point.Reconnects(),
+			"network", w.cfg.Name, "connection", addr)
 	}
 }
 
@@ -93,15 +156,21 @@ func (w *NetworkWorker) Start(v api.Switcher) {
 		w.Initialize()
 	}
 	w.uuid = v.UUID()
+	w.switcher = v
 	w.startTime = time.Now().Unix()
 	w.LoadLinks()
 }
 
 func (w *NetworkWorker) Stop() {
 	libol.Info("NetworkWorker.Close: %s", w.cfg.Name)
-	for _, p := range w.links {
-		p.Stop()
+	w.linksLock.Lock()
+	links := w.links
+	w.links = make(map[string]*link)
+	w.linksLock.Unlock()
+	for _, l := range links {
+		l.Stop()
 	}
+	w.withdrawAll()
 	w.startTime = 0
 }
 
@@ -112,30 +181,110 @@ func (w *NetworkWorker) UpTime() int64 {
 	return 0
 }
 
-func (w *NetworkWorker) AddLink(c *config.Point) {
+func (w *NetworkWorker) rightLink(c *config.Point) {
 	c.Alias = w.alias
 	c.Interface.Bridge = w.cfg.Bridge.Name //Reset bridge name.
 	c.RequestAddr = false
+	c.Link = true
+	c.Peer = w.cfg.Mesh
 	c.Network = w.cfg.Name
 	c.Interface.Address = w.cfg.Bridge.Address
-	libol.Go(func() {
-		p := point.NewPoint(c)
-		p.Initialize()
-		w.linksLock.Lock()
-		w.links[c.Connection] = p
-		w.linksLock.Unlock()
-		storage.Link.Add(p)
-		p.Start()
+}
+
+// credential replaces username and password by the credential referred,
+// and returns a copy to keep configuration unchanged.
+func (w *NetworkWorker) credential(c *config.Point) *config.Point {
+	if c.Credential == "" || w.switcher == nil {
+		return c
+	}
+	cred, ok := w.switcher.Config().Credentials[c.Credential]
+	if !ok {
+		libol.Warn("NetworkWorker.credential: %s not found", c.Credential)
+		return c
+	}
+	pc := *c
+	pc.Username = cred.Username
+	pc.Password = cred.Password
+	return &pc
+}
+
+// credChanged returns true if the credential of link is changed since
+// it started.
+func (w *NetworkWorker) credChanged(c *config.Point) bool {
+	w.linksLock.RLock()
+	l, ok := w.links[c.Connection]
+	w.linksLock.RUnlock()
+	if !ok {
+		return false
+	}
+	pc := w.credential(c)
+	return pc.Username != l.config.Username || pc.Password != l.config.Password
+}
+
+// RenewLinks restarts links whose credential changed.
+func (w *NetworkWorker) RenewLinks() {
+	for _, lin := range w.cfg.Links {
+		if w.credChanged(lin) {
+			libol.Info("NetworkWorker.RenewLinks: %s", lin.Connection)
+			w.AddLink(lin)
+		}
+	}
+}
+
+func (w *NetworkWorker) AddLink(c *config.Point) {
+	w.rightLink(c)
+	pc := w.credential(c)
+	p := point.NewPoint(pc)
+	addr := c.Connection
+	p.SetRouter(w.Advertise, func(routes []*models.Route) error {
+		w.Learn(addr, routes)
+		return nil
 	})
+	l := newLink(p, pc)
+	w.linksLock.Lock()
+	ol, ok := w.links[c.Connection]
+	w.links[c.Connection] = l
+	w.linksLock.Unlock()
+	if ok {
+		ol.Stop()
+	}
+	libol.Go(l.Start)
+}
+
+// NewLink adds a link into configuration, and starts it.
+func (w *NetworkWorker) NewLink(c *config.Point) {
+	w.cfg.Links = append(w.cfg.Links, c)
+	w.AddLink(c)
+}
+
+// RemoveLink stops the link, and removes it from configuration.
+func (w *NetworkWorker) RemoveLink(addr string) {
+	links := make([]*config.Point, 0, len(w.cfg.Links))
+	for _, lin := range w.cfg.Links {
+		if lin.Connection != addr {
+			links = append(links, lin)
+		}
+	}
+	w.cfg.Links = links
+	w.DelLink(addr)
+}
+
+func (w *NetworkWorker) HasLink(addr string) bool {
+	for _, lin := range w.cfg.Links {
+		if lin.Connection == addr {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *NetworkWorker) DelLink(addr string) {
 	w.linksLock.Lock()
-	defer w.linksLock.Unlock()
-
-	if p, ok := w.links[addr]; ok {
-		p.Stop()
-		storage.Link.Del(p.Addr())
-		delete(w.links, addr)
+	l, ok := w.links[addr]
+	delete(w.links, addr)
+	w.linksLock.Unlock()
+	if ok {
+		l.Stop()
 	}
+	w.Learn(addr, nil)
 }